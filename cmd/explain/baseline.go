@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// baseline is the stored outcome of `explain update`, check compares a run with it.
+// Server is the version of the Postgres that produced the plans, only update fills it
+type baseline struct {
+	Server  string            `json:"server"`
+	Dataset dataset           `json:"dataset"`
+	Queries map[string]result `json:"queries"`
+}
+
+func readBaseline(path string) (baseline, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return baseline{}, fmt.Errorf("%s not found, record one with `explain update`", path)
+		}
+		return baseline{}, err
+	}
+	var b baseline
+	if err = json.Unmarshal(content, &b); err != nil {
+		return baseline{}, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if len(b.Server) == 0 {
+		return baseline{}, fmt.Errorf("%s was not recorded by `explain update`, record it again", path)
+	}
+	return b, nil
+}
+
+func writeBaseline(path string, b baseline) error {
+	content, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(content, '\n'), 0o644)
+}
+
+// compare returns the problems of every query that regressed, keyed by query name.
+// Cost and buffers are allowed to grow by tolerance, times by tolerance plus slack ms
+func compare(base, current map[string]result, tolerance, slack float64) map[string][]string {
+	problems := make(map[string][]string)
+	for name, cur := range current {
+		var found []string
+		if required, ok := requiredIndexes[name]; ok && !usesAny(cur.Indexes, required) {
+			found = append(found, fmt.Sprintf("does not use %s", strings.Join(required, " or ")))
+		}
+
+		prev, ok := base[name]
+		if !ok {
+			found = append(found, "not in the baseline")
+			problems[name] = found
+			continue
+		}
+		for _, index := range prev.Indexes {
+			if !slices.Contains(cur.Indexes, index) && !sameIndex(index, cur.Indexes) {
+				found = append(found, fmt.Sprintf("stopped using %s", index))
+			}
+		}
+		if cur.Cost > prev.Cost*(1+tolerance) {
+			found = append(found, fmt.Sprintf("cost %.2f, baseline %.2f", cur.Cost, prev.Cost))
+		}
+		if float64(cur.Buffers) > float64(prev.Buffers)*(1+tolerance) {
+			found = append(found, fmt.Sprintf("buffers %d, baseline %d", cur.Buffers, prev.Buffers))
+		}
+		if cur.PlanningMs > prev.PlanningMs*(1+tolerance)+slack {
+			found = append(found, fmt.Sprintf("planning %.3fms, baseline %.3fms", cur.PlanningMs, prev.PlanningMs))
+		}
+		if cur.ExecutionMs > prev.ExecutionMs*(1+tolerance)+slack {
+			found = append(found, fmt.Sprintf("execution %.3fms, baseline %.3fms", cur.ExecutionMs, prev.ExecutionMs))
+		}
+		if len(found) > 0 {
+			problems[name] = found
+		}
+	}
+	return problems
+}
+
+func usesAny(used, wanted []string) bool {
+	for _, index := range wanted {
+		if slices.Contains(used, index) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameIndex tells if the plan switched between two equivalent indexes
+func sameIndex(index string, used []string) bool {
+	return slices.Contains(idIndexes, index) && usesAny(used, idIndexes)
+}
+
+func printResults(results map[string]result, problems map[string][]string) {
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Printf("%-30s%12s%12s%12s%10s  %-40s%s\n", "query", "planning", "execution", "cost", "buffers", "indexes", "status")
+	for _, name := range names {
+		res := results[name]
+		status := "ok"
+		if found, ok := problems[name]; ok {
+			status = "REGRESSED: " + strings.Join(found, "; ")
+		}
+		fmt.Printf("%-30s%10.3fms%10.3fms%12.2f%10d  %-40s%s\n",
+			name, res.PlanningMs, res.ExecutionMs, res.Cost, res.Buffers, strings.Join(res.Indexes, ","), status)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"homework/internal/storage/db"
+	"os"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	base := result{PlanningMs: 0.1, ExecutionMs: 1, Cost: 100, Buffers: 10, Indexes: []string{"orders_pkey"}}
+	with := func(change func(*result)) result {
+		res := base
+		res.Indexes = append([]string(nil), base.Indexes...)
+		change(&res)
+		return res
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		current result
+		want    []string
+	}{
+		{name: "unchanged", query: "getUser", current: base},
+		{name: "within tolerance", query: "getUser", current: with(func(r *result) {
+			r.Cost, r.Buffers, r.ExecutionMs = 150, 15, 1.5
+		})},
+		{name: "cost over tolerance", query: "getUser", current: with(func(r *result) { r.Cost = 151 }),
+			want: []string{"cost 151.00, baseline 100.00"}},
+		{name: "buffers over tolerance", query: "getUser", current: with(func(r *result) { r.Buffers = 16 }),
+			want: []string{"buffers 16, baseline 10"}},
+		{name: "noise within slack", query: "getUser", current: with(func(r *result) { r.PlanningMs = 0.24 })},
+		{name: "time over tolerance and slack", query: "getUser", current: with(func(r *result) { r.ExecutionMs = 1.61 }),
+			want: []string{"execution 1.610ms, baseline 1.000ms"}},
+		{name: "index lost", query: "getUser", current: with(func(r *result) { r.Indexes = nil }),
+			want: []string{"stopped using orders_pkey"}},
+		{name: "equivalent id index", query: "getOrder", current: with(func(r *result) { r.Indexes = []string{"id_asc"} })},
+		{name: "required index missing", query: "getOrder", current: with(func(r *result) { r.Indexes = []string{"user_id_storage_asc"} }),
+			want: []string{"does not use id_asc or orders_pkey", "stopped using orders_pkey"}},
+		{name: "new query", query: "newQuery", current: base, want: []string{"not in the baseline"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := compare(map[string]result{"getUser": base, "getOrder": base}, map[string]result{tt.query: tt.current}, 0.5, 0.1)
+
+			got := problems[tt.query]
+			if len(got) != len(tt.want) {
+				t.Fatalf("problems = %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("problem %d = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// TestBaselineCoversQueries keeps the committed baseline usable by `make explain`, a query added
+// to the repository has to be recorded too. The baseline only comes from a database,
+// until make explain-baseline is run there is nothing to check
+func TestBaselineCoversQueries(t *testing.T) {
+	if _, err := os.Stat("baseline.json"); os.IsNotExist(err) {
+		t.Skip("baseline.json is not recorded, run make explain-baseline")
+	}
+	b, err := readBaseline("baseline.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The dataset of EXPLAIN_FLAGS in the Makefile with the default shares
+	if want := (dataset{Orders: 100000, Users: 1000, Issued: 0.5, Returned: 0.1}); b.Dataset != want {
+		t.Errorf("baseline dataset = %+v, want %+v", b.Dataset, want)
+	}
+	for _, query := range db.Queries() {
+		if _, ok := b.Queries[query.Name]; !ok {
+			t.Errorf("%s is not in the baseline, record it with make explain-baseline", query.Name)
+		}
+	}
+}
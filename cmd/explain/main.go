@@ -0,0 +1,179 @@
+// Command explain seeds a throwaway schema, runs EXPLAIN (ANALYZE, BUFFERS) for every query of db.Repository
+// and compares the plans with a stored baseline.
+//
+//	explain [config flags] check|update [-orders=N] [-users=N] [-runs=N] [-baseline=path] ...
+//
+// check exits with status 1 when a query got slower than the baseline allows or stopped using its index,
+// update records the current plans as the new baseline
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"homework/internal/storage/db"
+	"homework/internal/util"
+	"homework/migrations"
+	"log/slog"
+	"os"
+)
+
+type options struct {
+	dataset
+	runs      int
+	baseline  string
+	schema    string
+	keep      bool
+	tolerance float64
+	slack     float64
+}
+
+func main() {
+	ctx := context.Background()
+	cfg, args, err := util.LoadConfig(os.Args[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		fatal("loading config", err)
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})))
+
+	command := "check"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+	if command != "check" && command != "update" {
+		fatal("explain", fmt.Errorf("unknown command %q, use check or update", command))
+	}
+
+	var opts options
+	fs := flag.NewFlagSet("explain "+command, flag.ContinueOnError)
+	fs.IntVar(&opts.Orders, "orders", 100000, "orders to seed")
+	fs.IntVar(&opts.Users, "users", 1000, "customers the orders are spread over")
+	fs.Float64Var(&opts.Issued, "issued", 0.5, "share of issued orders")
+	fs.Float64Var(&opts.Returned, "returned", 0.1, "share of returned orders, a part of the issued ones")
+	fs.IntVar(&opts.runs, "runs", 20, "times every query is explained, the median time is compared")
+	fs.StringVar(&opts.baseline, "baseline", "cmd/explain/baseline.json", "baseline file")
+	fs.StringVar(&opts.schema, "schema", "explain", "schema the dataset is seeded into, it is dropped first")
+	fs.BoolVar(&opts.keep, "keep", false, "keep the seeded schema after the run")
+	fs.Float64Var(&opts.tolerance, "tolerance", 0.5, "allowed relative growth of time, cost and buffers")
+	fs.Float64Var(&opts.slack, "slack", 0.1, "allowed absolute growth of time in ms, it hides noise on fast queries")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		os.Exit(2)
+	}
+	if err := opts.validate(); err != nil {
+		fatal("explain", err)
+	}
+
+	// The tables are created in their own schema, so the tool never touches the data of the pickup point
+	pool, err := db.Connect(ctx, cfg)
+	if err != nil {
+		fatal("connecting", err)
+	}
+	defer pool.Close()
+	if err := resetSchema(ctx, pool, opts.schema); err != nil {
+		fatal("creating schema", err)
+	}
+	if !opts.keep {
+		defer dropSchema(ctx, pool, opts.schema)
+	}
+
+	schemaCfg := *cfg
+	schemaCfg.DBSchema = opts.schema
+	schemaPool, err := db.Connect(ctx, &schemaCfg)
+	if err != nil {
+		fatal("connecting", err)
+	}
+	defer schemaPool.Close()
+
+	migrator, err := db.NewMigrator(schemaPool, migrations.FS)
+	if err != nil {
+		fatal("loading migrations", err)
+	}
+	if err := migrator.Up(ctx); err != nil {
+		fatal("migrating", err)
+	}
+
+	fmt.Printf("seeding %d orders of %d users into %s\n", opts.Orders, opts.Users, opts.schema)
+	samples, err := seed(ctx, schemaPool, opts.dataset)
+	if err != nil {
+		fatal("seeding", err)
+	}
+
+	results, err := explainAll(ctx, schemaPool, db.Queries(), samples, opts.runs)
+	if err != nil {
+		fatal("explaining", err)
+	}
+
+	if command == "update" {
+		var server string
+		if err := pool.QueryRow(ctx, "SHOW server_version").Scan(&server); err != nil {
+			fatal("reading server version", err)
+		}
+		if err := writeBaseline(opts.baseline, baseline{Server: server, Dataset: opts.dataset, Queries: results}); err != nil {
+			fatal("writing baseline", err)
+		}
+		printResults(results, nil)
+		fmt.Printf("baseline written to %s\n", opts.baseline)
+		return
+	}
+
+	base, err := readBaseline(opts.baseline)
+	if err != nil {
+		fatal("reading baseline", err)
+	}
+	if base.Dataset != opts.dataset {
+		fatal("comparing", fmt.Errorf("baseline was recorded for %+v, the run used %+v", base.Dataset, opts.dataset))
+	}
+	problems := compare(base.Queries, results, opts.tolerance, opts.slack)
+	printResults(results, problems)
+	if len(problems) > 0 {
+		fmt.Printf("%d queries regressed\n", len(problems))
+		dropIfTemporary(ctx, pool, opts)
+		os.Exit(1)
+	}
+	fmt.Println("no regressions")
+}
+
+func (o options) validate() error {
+	var errs []error
+	if o.Orders < 1 {
+		errs = append(errs, fmt.Errorf("-orders must be > 0, got %d", o.Orders))
+	}
+	if o.Users < 1 {
+		errs = append(errs, fmt.Errorf("-users must be > 0, got %d", o.Users))
+	}
+	if o.Issued < 0 || o.Issued > 1 {
+		errs = append(errs, fmt.Errorf("-issued must be between 0 and 1, got %v", o.Issued))
+	}
+	if o.Returned < 0 || o.Returned > o.Issued {
+		errs = append(errs, fmt.Errorf("-returned must be between 0 and -issued, got %v", o.Returned))
+	}
+	if o.runs < 1 {
+		errs = append(errs, fmt.Errorf("-runs must be > 0, got %d", o.runs))
+	}
+	if o.tolerance < 0 || o.slack < 0 {
+		errs = append(errs, errors.New("-tolerance and -slack can't be negative"))
+	}
+	if len(o.schema) == 0 || o.schema == "public" {
+		errs = append(errs, fmt.Errorf("-schema %q would drop the real tables", o.schema))
+	}
+	return errors.Join(errs...)
+}
+
+// dropIfTemporary cleans up before os.Exit skips the deferred calls
+func dropIfTemporary(ctx context.Context, pool schemaExecer, opts options) {
+	if !opts.keep {
+		dropSchema(ctx, pool, opts.schema)
+	}
+}
+
+func fatal(msg string, err error) {
+	fmt.Fprintf(os.Stderr, "%s: %v\n", msg, err)
+	os.Exit(1)
+}
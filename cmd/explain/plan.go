@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"homework/internal/models"
+	"homework/internal/storage/db"
+	"sort"
+	"time"
+)
+
+// idIndexes are the btrees over orders.id, the primary key and id_asc are the same index to the planner,
+// it may pick either of them
+var idIndexes = []string{"id_asc", "orders_pkey"}
+
+// requiredIndexes are the indexes a query must keep using, whatever the baseline says
+var requiredIndexes = map[string][]string{
+	"releaseCell":   idIndexes,
+	"returnOrder":   idIndexes,
+	"issueOrder":    idIndexes,
+	"deleteOrder":   idIndexes,
+	"getOrder":      idIndexes,
+	"getReturns":    idIndexes,
+	"lockOrders":    idIndexes,
+	"getOrders":     {"user_id_storage_asc"},
+	"getUserOrders": {"user_id_storage_asc"},
+}
+
+// queryArgs builds the arguments of every query in db.Queries from the seeded rows
+func queryArgs(s samples) map[string][]any {
+	now := time.Now()
+	return map[string][]any{
+		"allocateCell": {1.0, "box"},
+		"ensureUser":   {s.userID},
+		"insertOrder": {"explain", s.userID, now.Add(7 * 24 * time.Hour), false, nil, false,
+			100.0, 1.0, "box", 20.0, "hash", "C-01", s.courierID},
+		"audit":                        {s.pendingID, "accept", "operator-0"},
+		"enqueue":                      {s.pendingID, models.OrderAccepted, "{}"},
+		"releaseCell":                  {s.pendingID},
+		"returnOrder":                  {true, s.issuedID},
+		"issueOrder":                   {true, now, s.pendingID},
+		"insertPayment":                {s.userID, "operator-0", now, models.PaymentCash, 100.0, 0.0, 100.0, 150.0, 50.0, "[]", []string{s.issuedID}},
+		"getOrderPayment":              {s.issuedID},
+		"insertRefund":                 {int64(0), s.returnedID, s.userID, "operator-0", now, models.PaymentCash, 80.0, 0.0, 0.0, 80.0},
+		"getRefunds":                   {0, 10},
+		"getUserRefunds":               {s.userID, 0, 10},
+		"deleteOrder":                  {s.returnedID},
+		"getOrder":                     {s.pendingID},
+		"getReturns":                   {0, 10},
+		"getOrders":                    {s.userID, 0, 10},
+		"findByUser":                   {s.userID, now, now.Add(30 * 24 * time.Hour), 0, 10},
+		"findByPrefix":                 {s.pendingID[:1] + "%", 10.0, 1000.0, 0, 10},
+		"getExpiring":                  {now, now.Add(24 * time.Hour)},
+		"getCells":                     {},
+		"insertUser":                   {"explain", "Explain", "+70000000000", now},
+		"getUser":                      {s.userID},
+		"getUserOrders":                {s.userID},
+		"insertCourier":                {"explain", "Explain", "+70000000000", now},
+		"getCourier":                   {s.courierID},
+		"getCourierReturnable":         {s.courierID, now},
+		"deleteReturnable":             {s.returnedID, s.courierID, now},
+		"insertManifest":               {s.courierID, "operator-0", now, "[]"},
+		"dropHold":                     {s.pendingID, "operator-1", now},
+		"placeHold":                    {s.pendingID, "operator-1", now, now.Add(10 * time.Minute)},
+		"releaseHolds":                 {[]string{s.pendingID, s.issuedID}, "operator-1"},
+		"heldByOthers":                 {[]string{s.pendingID, s.issuedID}, "operator-1", now},
+		"lockOrders":                   {[]string{s.pendingID, s.issuedID}},
+		"getHolds":                     {now},
+		"getOrderHolds":                {[]string{s.pendingID, s.issuedID}, now},
+		"insertOperator":               {"explain", "clerk", "hash", "explain-key"},
+		"getOperator":                  {"operator-1"},
+		"getOperatorByKey":             {"key-1"},
+		"claimNotification":            {s.pendingID, s.userID, models.EventAccepted, "file", "accepted", models.NotificationPending, models.NotificationFailed, (5 * time.Minute).Milliseconds()},
+		"updateNotification":           {models.NotificationSent, "", now, s.pendingID, models.EventAccepted, "file"},
+		"getNotifications":             {s.pendingID},
+		"getFailedNotifications":       {models.NotificationFailed, 5, models.NotificationPending, (5 * time.Minute).Milliseconds(), 100},
+		"outboxLock":                   {int64(1)},
+		"unpublishedOutbox":            {100},
+		"outboxPublished":              {int64(1)},
+		"outboxFailed":                 {int64(1), "explain"},
+		"claimIdempotencyKey":          {"operator-1", "key-1", "accept", "fingerprint", models.IdempotencyPending, now.Add(time.Hour), time.Minute.Milliseconds()},
+		"getIdempotencyKey":            {"operator-1", "key-1"},
+		"completeIdempotencyKey":       {models.IdempotencyDone, "{}", "", "", "", "operator-1", "key-1", now, models.IdempotencyPending},
+		"releaseIdempotencyKey":        {"operator-1", "key-1", now, models.IdempotencyPending},
+		"deleteExpiredIdempotencyKeys": {},
+	}
+}
+
+// result is what the baseline keeps for a query: median times of the runs, the planner's cost,
+// the blocks the plan touched and the indexes it used
+type result struct {
+	PlanningMs  float64  `json:"planning_ms"`
+	ExecutionMs float64  `json:"execution_ms"`
+	Cost        float64  `json:"cost"`
+	Buffers     int64    `json:"buffers"`
+	Indexes     []string `json:"indexes"`
+}
+
+// planNode is the part of EXPLAIN (FORMAT JSON) output the tool looks at
+type planNode struct {
+	IndexName  string     `json:"Index Name"`
+	TotalCost  float64    `json:"Total Cost"`
+	SharedHit  int64      `json:"Shared Hit Blocks"`
+	SharedRead int64      `json:"Shared Read Blocks"`
+	Plans      []planNode `json:"Plans"`
+}
+
+type explainOutput struct {
+	Plan          planNode `json:"Plan"`
+	PlanningTime  float64  `json:"Planning Time"`
+	ExecutionTime float64  `json:"Execution Time"`
+}
+
+func explainAll(ctx context.Context, pool *pgxpool.Pool, queries []db.Query, s samples, runs int) (map[string]result, error) {
+	args := queryArgs(s)
+	for _, query := range queries {
+		if _, ok := args[query.Name]; !ok {
+			return nil, fmt.Errorf("query %s has no explain arguments, add them to queryArgs", query.Name)
+		}
+	}
+
+	results := make(map[string]result, len(queries))
+	for _, query := range queries {
+		res, err := explainQuery(ctx, pool, query.SQL, args[query.Name], runs)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", query.Name, err)
+		}
+		results[query.Name] = res
+	}
+	return results, nil
+}
+
+// explainQuery runs the query runs times, every run is rolled back so the writes don't change the dataset
+func explainQuery(ctx context.Context, pool *pgxpool.Pool, sql string, args []any, runs int) (result, error) {
+	planning := make([]float64, 0, runs)
+	execution := make([]float64, 0, runs)
+	var last explainOutput
+	for i := 0; i < runs; i++ {
+		out, err := explainOnce(ctx, pool, sql, args)
+		if err != nil {
+			return result{}, err
+		}
+		planning = append(planning, out.PlanningTime)
+		execution = append(execution, out.ExecutionTime)
+		last = out
+	}
+
+	return result{
+		PlanningMs:  median(planning),
+		ExecutionMs: median(execution),
+		Cost:        last.Plan.TotalCost,
+		Buffers:     last.Plan.SharedHit + last.Plan.SharedRead,
+		Indexes:     indexes(last.Plan),
+	}, nil
+}
+
+func explainOnce(ctx context.Context, pool *pgxpool.Pool, sql string, args []any) (explainOutput, error) {
+	tx, err := pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return explainOutput{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	var raw []byte
+	if err = tx.QueryRow(ctx, "EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON) "+sql, args...).Scan(&raw); err != nil {
+		return explainOutput{}, err
+	}
+	var out []explainOutput
+	if err = json.Unmarshal(raw, &out); err != nil {
+		return explainOutput{}, fmt.Errorf("parsing plan: %w", err)
+	}
+	if len(out) != 1 {
+		return explainOutput{}, fmt.Errorf("expected one plan, got %d", len(out))
+	}
+	return out[0], nil
+}
+
+// indexes returns the sorted names of the indexes scanned anywhere in the plan
+func indexes(node planNode) []string {
+	seen := make(map[string]struct{})
+	var walk func(planNode)
+	walk = func(node planNode) {
+		if len(node.IndexName) > 0 {
+			seen[node.IndexName] = struct{}{}
+		}
+		for _, child := range node.Plans {
+			walk(child)
+		}
+	}
+	walk(node)
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	n := len(values)
+	if n%2 == 0 {
+		return (values[n/2-1] + values[n/2]) / 2
+	}
+	return values[n/2]
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"homework/internal/models"
+	"log/slog"
+)
+
+// dataset is what the schema is seeded with, a baseline is only comparable with a run on the same dataset
+type dataset struct {
+	Orders   int     `json:"orders"`
+	Users    int     `json:"users"`
+	Issued   float64 `json:"issued"`
+	Returned float64 `json:"returned"`
+}
+
+// samples are rows of the seeded data the queries are explained with
+type samples struct {
+	userID     string
+	courierID  string
+	pendingID  string
+	issuedID   string
+	returnedID string
+}
+
+// seedCouriers is the number of couriers, a pickup point works with a handful of them whatever its size
+const seedCouriers = 10
+
+type schemaExecer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+func resetSchema(ctx context.Context, pool schemaExecer, schema string) error {
+	name := pgx.Identifier{schema}.Sanitize()
+	if _, err := pool.Exec(ctx, "DROP SCHEMA IF EXISTS "+name+" CASCADE"); err != nil {
+		return err
+	}
+	_, err := pool.Exec(ctx, "CREATE SCHEMA "+name)
+	return err
+}
+
+func dropSchema(ctx context.Context, pool schemaExecer, schema string) {
+	if _, err := pool.Exec(ctx, "DROP SCHEMA IF EXISTS "+pgx.Identifier{schema}.Sanitize()+" CASCADE"); err != nil {
+		slog.Error("dropping schema", "schema", schema, "err", err)
+	}
+}
+
+// seed fills the tables with data shaped like a busy pickup point: orders spread over the users and a month
+// of storage dates, the customers they belong to and the couriers who brought them, the payments of the issued ones and the refunds
+// of the returned ones, with the notifications, outbox events and idempotency keys they leave behind
+func seed(ctx context.Context, pool *pgxpool.Pool, d dataset) (samples, error) {
+	// Every order n is issued when n % 1000 < issued, and also returned when n % 1000 < returned
+	issued, returned := int(d.Issued*1000), int(d.Returned*1000)
+	statements := []struct {
+		sql  string
+		args []any
+	}{
+		{`
+		INSERT INTO users (id, name, phone)
+		SELECT 'user-' || n, 'Customer ' || n, '+7' || lpad(n::text, 10, '0') FROM generate_series(0, $1 - 1) AS n
+		`, []any{d.Users}},
+		{`
+		INSERT INTO couriers (id, name, phone)
+		SELECT 'courier-' || n, 'Courier ' || n, '+7' || lpad(n::text, 10, '9') FROM generate_series(0, $1 - 1) AS n
+		`, []any{seedCouriers}},
+		{`
+		INSERT INTO orders (id, user_id, storage_until, issued, issued_at, returned, order_price, weight, package_type, package_price, hash, courier_id)
+		SELECT n::text, 'user-' || (n % $2), now() + ((n % 30) - 10) * interval '1 day',
+			n % 1000 < $3, CASE WHEN n % 1000 < $3 THEN now() - (n % 10) * interval '1 day' END,
+			n % 1000 < $4, 100 + n % 900, 1 + n % 25,
+			(ARRAY['film', 'packet', 'box'])[1 + n % 3], (ARRAY[1, 5, 20])[1 + n % 3], md5(n::text), 'courier-' || (n % $5)
+		FROM generate_series(1, $1) AS n
+		`, []any{d.Orders, d.Users, issued, returned, seedCouriers}},
+		// Every issued order was paid for on its own by card, the payment takes the id of the order
+		{`
+		INSERT INTO payments (id, user_id, operator, created_at, method, total, prepaid, due, paid, change_given, lines)
+		SELECT id::bigint, user_id, 'operator-' || (id::bigint % 50), issued_at, 'card', order_price, 0, order_price, order_price, 0, '[]'
+		FROM orders WHERE issued
+		`, nil},
+		{`SELECT setval('payments_id_seq', (SELECT COALESCE(max(id), 0) + 1 FROM payments), false)`, nil},
+		{`INSERT INTO payment_orders (payment_id, order_id) SELECT id::bigint, id FROM orders WHERE issued`, nil},
+		{`
+		INSERT INTO refunds (payment_id, order_id, user_id, operator, created_at, method, goods, package_price, withheld, amount)
+		SELECT CASE WHEN issued THEN id::bigint END, id, user_id, 'operator-' || (id::bigint % 50), COALESCE(issued_at, now()) + interval '1 day',
+			'card', order_price - package_price, 0, 0, order_price - package_price
+		FROM orders WHERE returned
+		`, nil},
+		{`
+		INSERT INTO order_audit (order_id, action, operator)
+		SELECT n::text, 'accept', 'operator-' || (n % 50) FROM generate_series(1, $1) AS n
+		`, []any{d.Orders}},
+		{`
+		INSERT INTO operators (login, role, password_hash, api_key_hash)
+		SELECT 'operator-' || n, 'clerk', md5(n::text), 'key-' || n FROM generate_series(0, 49) AS n
+		`, nil},
+		{`
+		INSERT INTO notifications (order_id, user_id, event, channel, message, status, sent_at)
+		SELECT n::text, 'user-' || (n % $2), $3, 'file', 'accepted',
+			CASE WHEN n % 100 = 0 THEN $4 ELSE $5 END, CASE WHEN n % 100 = 0 THEN NULL ELSE now() END
+		FROM generate_series(1, $1) AS n
+		`, []any{d.Orders, d.Users, models.EventAccepted, models.NotificationFailed, models.NotificationSent}},
+		{`
+		INSERT INTO outbox (order_id, event_type, payload, published_at, attempts)
+		SELECT n::text, $2, '{}', CASE WHEN n > $1 - $1 / 100 THEN NULL ELSE now() END, 1
+		FROM generate_series(1, $1) AS n
+		`, []any{d.Orders, models.OrderAccepted}},
+		{`
+		INSERT INTO idempotency_keys (operator, key, command, fingerprint, status, response, expires_at)
+		SELECT 'operator-' || (n % 50), 'key-' || n, 'accept', md5(n::text), $2, '{}', now() + ((n % 48) - 24) * interval '1 hour'
+		FROM generate_series(1, $1) AS n
+		`, []any{d.Orders, models.IdempotencyDone}},
+		{`ANALYZE`, nil},
+	}
+	for _, statement := range statements {
+		if _, err := pool.Exec(ctx, statement.sql, statement.args...); err != nil {
+			return samples{}, err
+		}
+	}
+
+	var s samples
+	err := pool.QueryRow(ctx, `SELECT id, user_id, courier_id FROM orders WHERE NOT issued ORDER BY id DESC LIMIT 1`).Scan(&s.pendingID, &s.userID, &s.courierID)
+	if err != nil {
+		return samples{}, fmt.Errorf("no pending orders in the dataset: %w", err)
+	}
+	// The update queries need orders in the right state, with no share of them they are explained on the pending one
+	s.issuedID, s.returnedID = s.pendingID, s.pendingID
+	_ = pool.QueryRow(ctx, `SELECT id FROM orders WHERE issued AND NOT returned ORDER BY id DESC LIMIT 1`).Scan(&s.issuedID)
+	_ = pool.QueryRow(ctx, `SELECT id FROM orders WHERE returned ORDER BY id DESC LIMIT 1`).Scan(&s.returnedID)
+	return s, nil
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"homework/internal/auth"
+	"homework/internal/logger"
+	"homework/internal/models"
+	"homework/internal/service"
+	"io"
+	"math/rand"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// orderRef is an order created by the run, waiting for the next step of its workflow
+type orderRef struct {
+	id     string
+	userID string
+}
+
+// generator runs the operations of the mix, the orders move through accept, issue and return
+// so the later steps always have something to work on
+type generator struct {
+	orders      service.OrderService
+	validations service.ValidationService
+	payments    service.PaymentService
+	refunds     service.RefundService
+	opts        options
+	stats       *stats
+	nextID      atomic.Int64
+
+	mu       sync.Mutex
+	accepted []orderRef
+	issued   []orderRef
+}
+
+func newGenerator(orders service.OrderService, validations service.ValidationService, payments service.PaymentService, refunds service.RefundService, opts options) *generator {
+	g := &generator{
+		orders:      orders,
+		validations: validations,
+		payments:    payments,
+		refunds:     refunds,
+		opts:        opts,
+		stats:       newStats(),
+	}
+	g.nextID.Store(opts.idStart)
+	return g
+}
+
+// run keeps the operators busy until ctx is done and returns how long they worked
+func (g *generator) run(ctx context.Context) time.Duration {
+	tokens := g.throttle(ctx)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < g.opts.concurrency; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			operatorCtx := auth.WithOperator(ctx, models.Operator{Login: fmt.Sprintf("loadgen-%d", worker), Role: models.RoleClerk})
+			rnd := rand.New(rand.NewSource(time.Now().UnixNano() + int64(worker)))
+			for {
+				if tokens != nil {
+					select {
+					case <-tokens:
+					case <-ctx.Done():
+						return
+					}
+				}
+				if ctx.Err() != nil {
+					return
+				}
+				g.do(logger.WithCorrelationID(operatorCtx, logger.NewCorrelationID()), g.opts.mix.pick(rnd), rnd)
+			}
+		}(i)
+	}
+	wg.Wait()
+	return time.Since(start)
+}
+
+// throttle hands out rate tokens a second, nil means the operators don't wait
+func (g *generator) throttle(ctx context.Context) <-chan struct{} {
+	if g.opts.rate == 0 {
+		return nil
+	}
+	tokens := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / g.opts.rate))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				// A token nobody is free to take is dropped, the rate is an upper bound
+				select {
+				case tokens <- struct{}{}:
+				default:
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return tokens
+}
+
+func (g *generator) do(ctx context.Context, op operation, rnd *rand.Rand) {
+	var (
+		fn  func(ctx context.Context) error
+		ref orderRef
+		ok  = true
+	)
+	switch op {
+	case opAccept:
+		fn = func(ctx context.Context) error {
+			return g.accept(ctx, rnd)
+		}
+	case opIssue:
+		if ref, ok = g.take(&g.accepted, rnd); ok {
+			fn = func(ctx context.Context) error {
+				return g.issue(ctx, ref)
+			}
+		}
+	case opReturn:
+		if ref, ok = g.take(&g.issued, rnd); ok {
+			fn = func(ctx context.Context) error {
+				return g.acceptReturn(ctx, ref)
+			}
+		}
+	case opList:
+		fn = func(ctx context.Context) error {
+			return g.list(ctx, g.user(rnd))
+		}
+	case opReturns:
+		fn = g.listReturns
+	}
+	if !ok {
+		g.stats.skip(op)
+		return
+	}
+
+	start := time.Now()
+	err := fn(ctx)
+	// The operations cut short by the end of the run say nothing about the service
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+	g.stats.record(op, time.Since(start), err)
+}
+
+func (g *generator) accept(ctx context.Context, rnd *rand.Rand) error {
+	id := strconv.FormatInt(g.nextID.Add(1), 10)
+	userID := g.user(rnd)
+	packageType, weight := randomPackage(rnd)
+	storageUntil := time.Now().Add(7 * 24 * time.Hour).Format(time.DateOnly)
+	price := strconv.Itoa(100 + rnd.Intn(5000))
+
+	order, err := g.validations.ValidateAccept(ctx, id, userID, storageUntil, price, weight, packageType, "")
+	if err != nil {
+		return err
+	}
+	if err = g.orders.Accept(ctx, order, packageType, io.Discard); err != nil {
+		return err
+	}
+	g.put(&g.accepted, orderRef{id: id, userID: userID})
+	return nil
+}
+
+func (g *generator) issue(ctx context.Context, ref orderRef) error {
+	orders, err := g.validations.ValidateIssue(ctx, []string{ref.id})
+	if err != nil {
+		return err
+	}
+	// Customers pay by card, the terminal takes the amount due
+	payment, err := g.payments.Charge(*orders, models.Tender{Method: models.PaymentCard})
+	if err != nil {
+		return err
+	}
+	if err = g.orders.Issue(ctx, orders, &payment); err != nil {
+		return err
+	}
+	g.put(&g.issued, ref)
+	return nil
+}
+
+func (g *generator) acceptReturn(ctx context.Context, ref orderRef) error {
+	order, err := g.validations.ValidateAcceptReturn(ctx, ref.id, ref.userID)
+	if err != nil {
+		return err
+	}
+	refund, err := g.refunds.Calculate(ctx, *order)
+	if err != nil {
+		return err
+	}
+	return g.orders.Return(ctx, order, &refund)
+}
+
+func (g *generator) list(ctx context.Context, userID string) error {
+	offset, limit, err := g.validations.ValidateList("0", "20")
+	if err != nil {
+		return err
+	}
+	_, err = g.orders.ListOrders(ctx, userID, offset, limit)
+	return err
+}
+
+func (g *generator) listReturns(ctx context.Context) error {
+	offset, limit, err := g.validations.ValidateList("0", "20")
+	if err != nil {
+		return err
+	}
+	_, err = g.orders.ListReturns(ctx, offset, limit)
+	return err
+}
+
+func (g *generator) user(rnd *rand.Rand) string {
+	return "user-" + strconv.Itoa(rnd.Intn(g.opts.users))
+}
+
+// take removes a random order from the queue, false means there is nothing to work on yet
+func (g *generator) take(queue *[]orderRef, rnd *rand.Rand) (orderRef, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	n := len(*queue)
+	if n == 0 {
+		return orderRef{}, false
+	}
+	i := rnd.Intn(n)
+	ref := (*queue)[i]
+	(*queue)[i] = (*queue)[n-1]
+	*queue = (*queue)[:n-1]
+	return ref, true
+}
+
+func (g *generator) put(queue *[]orderRef, ref orderRef) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	*queue = append(*queue, ref)
+}
+
+// randomPackage picks a package and a weight within its limit
+func randomPackage(rnd *rand.Rand) (string, string) {
+	switch rnd.Intn(3) {
+	case 0:
+		return "packet", strconv.FormatFloat(0.5+rnd.Float64()*9, 'f', 1, 64)
+	case 1:
+		return "box", strconv.FormatFloat(0.5+rnd.Float64()*29, 'f', 1, 64)
+	}
+	return "film", strconv.FormatFloat(0.5+rnd.Float64()*40, 'f', 1, 64)
+}
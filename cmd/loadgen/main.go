@@ -0,0 +1,155 @@
+// Command loadgen drives the order workflow with many concurrent operators and reports throughput,
+// latency percentiles and errors by their domain type.
+//
+//	loadgen [config flags] memory|postgres [-duration=30s] [-concurrency=100] [-rate=0] [-mix=accept=4,issue=3,return=1,list=2]
+//
+// memory runs against the in-memory repository, postgres against the database from the config,
+// the orders it creates there are left in place
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"homework/internal/service"
+	pkg "homework/internal/service/package"
+	"homework/internal/storage"
+	"homework/internal/storage/db"
+	"homework/internal/storage/memory"
+	"homework/internal/util"
+	"homework/pkg/clock"
+	"log/slog"
+	"os"
+	"os/signal"
+	"time"
+)
+
+type options struct {
+	duration    time.Duration
+	concurrency int
+	rate        float64
+	mix         mix
+	users       int
+	cells       int
+	idStart     int64
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cfg, args, err := util.LoadConfig(os.Args[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		fatal("loading config", err)
+	}
+	// The services log every order, only problems are worth seeing under load
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError})))
+
+	backend := "memory"
+	if len(args) > 0 {
+		backend, args = args[0], args[1:]
+	}
+
+	opts := options{mix: defaultMix()}
+	fs := flag.NewFlagSet("loadgen "+backend, flag.ContinueOnError)
+	fs.DurationVar(&opts.duration, "duration", 30*time.Second, "how long to generate load")
+	fs.IntVar(&opts.concurrency, "concurrency", 100, "operators working at once")
+	fs.Float64Var(&opts.rate, "rate", 0, "operations per second over all operators, 0 for as fast as they can")
+	fs.Var(&opts.mix, "mix", "weights of the operations: accept, issue, return, list, returns")
+	fs.IntVar(&opts.users, "users", 100, "customers the orders are spread over")
+	fs.IntVar(&opts.cells, "cells", 20, "cells per shelf of the in-memory repository")
+	fs.Int64Var(&opts.idStart, "id_start", 0, "first order id, by default derived from the current time so runs don't collide")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		os.Exit(2)
+	}
+	if err := opts.validate(); err != nil {
+		fatal("loadgen", err)
+	}
+	if opts.idStart == 0 {
+		opts.idStart = time.Now().UnixMilli() * 1000
+	}
+
+	var repository storage.Storage
+	switch backend {
+	case "memory":
+		repository = memory.NewRepository(memory.DefaultCells(opts.cells))
+	case "postgres":
+		sqlRepository, err := db.NewSQLRepository(ctx, cfg)
+		if err != nil {
+			fatal("opening repository", err)
+		}
+		defer sqlRepository.Close()
+		repository = sqlRepository
+	default:
+		fatal("loadgen", fmt.Errorf("unknown backend %q, use memory or postgres", backend))
+	}
+
+	packageService := pkg.NewPackageService()
+	// Without notifiers the notification service does nothing, the load stays on the order workflow
+	notificationService := service.NewNotificationService(repository, clock.Real{}, cfg.NotifyExpiryWindow, cfg.NotifySweepInterval)
+	gen := newGenerator(
+		service.NewOrderService(repository, packageService, notificationService, clock.Real{}),
+		// The generated customers are new to the pickup point, accept adds them like AUTO_CREATE_USERS does
+		service.NewValidationService(repository, packageService, clock.Real{}, true),
+		service.NewPaymentService(),
+		service.NewRefundService(repository, clock.Real{}, cfg.StorageFeePerDay, service.RefundRules{
+			RefundPackage: cfg.RefundPackage,
+			WithholdFees:  cfg.RefundWithholdFees,
+		}),
+		opts,
+	)
+
+	fmt.Printf("generating load on %s for %s: %d operators, rate %s, mix %s\n", backend, opts.duration, opts.concurrency, rateString(opts.rate), opts.mix.String())
+
+	// Accept prints its progress to stdout, thousands of operators would bury the report
+	stdout := os.Stdout
+	if devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0); err == nil {
+		os.Stdout = devNull
+		defer devNull.Close()
+	}
+	runCtx, cancel := context.WithTimeout(ctx, opts.duration)
+	defer cancel()
+	elapsed := gen.run(runCtx)
+	os.Stdout = stdout
+
+	gen.stats.print(stdout, elapsed)
+}
+
+func (o options) validate() error {
+	var errs []error
+	if o.duration <= 0 {
+		errs = append(errs, fmt.Errorf("-duration must be positive, got %s", o.duration))
+	}
+	if o.concurrency < 1 {
+		errs = append(errs, fmt.Errorf("-concurrency must be > 0, got %d", o.concurrency))
+	}
+	if o.rate < 0 {
+		errs = append(errs, fmt.Errorf("-rate can't be negative, got %v", o.rate))
+	}
+	if o.users < 1 {
+		errs = append(errs, fmt.Errorf("-users must be > 0, got %d", o.users))
+	}
+	if o.cells < 1 {
+		errs = append(errs, fmt.Errorf("-cells must be > 0, got %d", o.cells))
+	}
+	return errors.Join(errs...)
+}
+
+func rateString(rate float64) string {
+	if rate == 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%g/s", rate)
+}
+
+func fatal(msg string, err error) {
+	fmt.Fprintf(os.Stderr, "%s: %v\n", msg, err)
+	os.Exit(1)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+type operation string
+
+const (
+	opAccept  operation = "accept"
+	opIssue   operation = "issue"
+	opReturn  operation = "return"
+	opList    operation = "list"
+	opReturns operation = "returns"
+)
+
+var operations = []operation{opAccept, opIssue, opReturn, opList, opReturns}
+
+// mix is the share of every operation in the load, it's set with -mix=accept=4,issue=3
+type mix map[operation]int
+
+func defaultMix() mix {
+	return mix{opAccept: 4, opIssue: 3, opReturn: 1, opList: 1, opReturns: 1}
+}
+
+func (m mix) String() string {
+	parts := make([]string, 0, len(operations))
+	for _, op := range operations {
+		if m[op] > 0 {
+			parts = append(parts, fmt.Sprintf("%s=%d", op, m[op]))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// Set replaces the default mix, operations left out are not run
+func (m *mix) Set(value string) error {
+	parsed := make(mix)
+	total := 0
+	for _, part := range strings.Split(value, ",") {
+		name, weightStr, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return fmt.Errorf("%q: expected operation=weight", part)
+		}
+		op := operation(name)
+		if !op.valid() {
+			return fmt.Errorf("unknown operation %q, use accept, issue, return, list or returns", name)
+		}
+		weight, err := strconv.Atoi(weightStr)
+		if err != nil || weight < 0 {
+			return fmt.Errorf("%s: invalid weight %q", name, weightStr)
+		}
+		parsed[op] = weight
+		total += weight
+	}
+	if total == 0 {
+		return fmt.Errorf("the weights add up to 0, nothing would run")
+	}
+	*m = parsed
+	return nil
+}
+
+func (op operation) valid() bool {
+	for _, known := range operations {
+		if op == known {
+			return true
+		}
+	}
+	return false
+}
+
+// pick draws an operation with the probability of its weight
+func (m mix) pick(rnd *rand.Rand) operation {
+	total := 0
+	for _, op := range operations {
+		total += m[op]
+	}
+	n := rnd.Intn(total)
+	for _, op := range operations {
+		if n < m[op] {
+			return op
+		}
+		n -= m[op]
+	}
+	return opList
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"homework/internal/util"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+type opStats struct {
+	ok        int
+	failed    int
+	skipped   int
+	latencies []time.Duration
+}
+
+// stats collects the outcome of every operation, errors are counted by the util.Err* sentinel they derive from
+type stats struct {
+	mu     sync.Mutex
+	ops    map[operation]*opStats
+	errors map[*util.Error]map[operation]int
+}
+
+func newStats() *stats {
+	s := &stats{
+		ops:    make(map[operation]*opStats, len(operations)),
+		errors: make(map[*util.Error]map[operation]int),
+	}
+	for _, op := range operations {
+		s.ops[op] = &opStats{}
+	}
+	return s
+}
+
+func (s *stats) record(op operation, latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.ops[op]
+	st.latencies = append(st.latencies, latency)
+	if err == nil {
+		st.ok++
+		return
+	}
+	st.failed++
+	kind := util.AsError(err).Kind()
+	if s.errors[kind] == nil {
+		s.errors[kind] = make(map[operation]int)
+	}
+	s.errors[kind][op]++
+}
+
+// skip counts an operation that had no order to work on
+func (s *stats) skip(op operation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ops[op].skipped++
+}
+
+func (s *stats) print(w io.Writer, elapsed time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seconds := elapsed.Seconds()
+	fmt.Fprintf(w, "\n%-10s%10s%10s%10s%10s%12s%12s%12s%12s\n", "operation", "ok", "errors", "skipped", "ops/s", "p50", "p90", "p99", "max")
+	var all []time.Duration
+	var ok, failed, skipped int
+	for _, op := range operations {
+		st := s.ops[op]
+		if st.ok+st.failed+st.skipped == 0 {
+			continue
+		}
+		printRow(w, string(op), st.ok, st.failed, st.skipped, seconds, st.latencies)
+		all = append(all, st.latencies...)
+		ok += st.ok
+		failed += st.failed
+		skipped += st.skipped
+	}
+	printRow(w, "total", ok, failed, skipped, seconds, all)
+
+	if len(s.errors) == 0 {
+		fmt.Fprintf(w, "\nno errors in %s\n", elapsed.Round(time.Millisecond))
+		return
+	}
+
+	kinds := make([]*util.Error, 0, len(s.errors))
+	for kind := range s.errors {
+		kinds = append(kinds, kind)
+	}
+	sort.Slice(kinds, func(i, k int) bool {
+		return total(s.errors[kinds[i]]) > total(s.errors[kinds[k]])
+	})
+	fmt.Fprintf(w, "\n%-22s%8s  %-30s%s\n", "code", "count", "operations", "error")
+	for _, kind := range kinds {
+		fmt.Fprintf(w, "%-22s%8d  %-30s%s\n", kind.Code, total(s.errors[kind]), byOperation(s.errors[kind]), kind.Message)
+	}
+}
+
+func printRow(w io.Writer, name string, ok, failed, skipped int, seconds float64, latencies []time.Duration) {
+	sort.Slice(latencies, func(i, k int) bool {
+		return latencies[i] < latencies[k]
+	})
+	fmt.Fprintf(w, "%-10s%10d%10d%10d%10.1f%12s%12s%12s%12s\n", name, ok, failed, skipped, float64(ok+failed)/seconds,
+		percentile(latencies, 0.5), percentile(latencies, 0.9), percentile(latencies, 0.99), percentile(latencies, 1))
+}
+
+// percentile expects sorted latencies, it uses the nearest rank
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(p*float64(len(sorted)) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1].Round(time.Microsecond)
+}
+
+func total(counts map[operation]int) int {
+	n := 0
+	for _, count := range counts {
+		n += count
+	}
+	return n
+}
+
+func byOperation(counts map[operation]int) string {
+	parts := ""
+	for _, op := range operations {
+		if counts[op] == 0 {
+			continue
+		}
+		if len(parts) > 0 {
+			parts += ","
+		}
+		parts += fmt.Sprintf("%s=%d", op, counts[op])
+	}
+	return parts
+}
@@ -2,26 +2,221 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"homework/internal/health"
+	"homework/internal/i18n"
+	"homework/internal/logger"
+	"homework/internal/metrics"
+	"homework/internal/models"
+	"homework/internal/notification"
+	"homework/internal/outbox"
 	"homework/internal/service"
 	pkg "homework/internal/service/package"
 	"homework/internal/storage/db"
 	"homework/internal/util"
 	"homework/internal/view"
-	"log"
+	"homework/migrations"
+	"homework/pkg/clock"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
 )
 
 func main() {
-	repository := db.NewSQLRepository(context.Background(), util.NewConfig())
+	ctx := context.Background()
+	cfg, args, err := util.LoadConfig(os.Args[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		fatal("loading config", err)
+	}
+	if len(args) > 0 && args[0] == "config" {
+		util.PrintConfig(os.Stdout, cfg)
+		return
+	}
+
+	var logOutput io.Writer = os.Stderr
+	if len(cfg.LogFile) > 0 {
+		logFile, err := os.OpenFile(cfg.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			fatal("opening log file", err)
+		}
+		defer logFile.Close()
+		logOutput = logFile
+	}
+	log, err := logger.New(logOutput, cfg.LogFormat, cfg.LogLevel)
+	if err != nil {
+		fatal("configuring logger", err)
+	}
+	slog.SetDefault(log)
+
+	locale, ok := i18n.ParseLocale(cfg.Lang)
+	if !ok {
+		slog.Warn("unsupported locale, falling back to English", "lang", cfg.Lang)
+	}
+	i18n.SetDefault(i18n.New(locale))
+
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := migrate(ctx, cfg, args[1:]); err != nil {
+			fatal("migrate", err)
+		}
+		return
+	}
+
+	var serviceClock clock.Clock = clock.Real{}
+	if !cfg.Now.IsZero() {
+		serviceClock = clock.NewOffset(cfg.Now)
+		slog.Warn("clock overridden", "now", cfg.Now)
+		fmt.Println(i18n.T(i18n.MsgClockOverridden, cfg.Now.Format(time.DateTime)))
+	}
+
+	repository, err := db.NewSQLRepository(ctx, cfg)
+	if err != nil {
+		fatal("opening repository", err)
+	}
+
+	var notifiers []notification.Notifier
+	if len(cfg.NotifyFile) > 0 {
+		notifiers = append(notifiers, notification.NewFileNotifier(cfg.NotifyFile))
+	}
+	notificationService := service.NewNotificationService(repository, serviceClock, cfg.NotifyExpiryWindow, cfg.NotifySweepInterval, notifiers...)
 
 	packageService := pkg.NewPackageService()
-	orderService := service.NewOrderService(repository, packageService)
-	validationService := service.NewValidationService(repository, packageService)
+	orderService := service.NewOrderService(repository, packageService, notificationService, serviceClock)
+	validationService := service.NewValidationService(repository, packageService, serviceClock, cfg.AutoCreateUsers)
+	idempotencyService := service.NewIdempotencyService(repository, cfg.IdempotencyTTL, cfg.IdempotencyLease)
+	locationService := service.NewLocationService(repository)
+	operatorService := service.NewOperatorService(repository)
+	userService := service.NewUserService(repository, serviceClock, cfg.StorageFeePerDay)
+	if len(cfg.ManifestKey) == 0 {
+		slog.Warn("MANIFEST_KEY is not set, courier_return is refused")
+	}
+	courierService := service.NewCourierService(repository, serviceClock, cfg.ManifestDir, cfg.ManifestKey)
+	holdService := service.NewHoldService(repository, serviceClock, cfg.HoldTTL)
+	paymentService := service.NewPaymentService()
+	refundService := service.NewRefundService(repository, serviceClock, cfg.StorageFeePerDay, service.RefundRules{
+		RefundPackage: cfg.RefundPackage,
+		WithholdFees:  cfg.RefundWithholdFees,
+	})
+
+	if err := operatorService.Bootstrap(ctx, cfg.AdminPassword); err != nil {
+		fatal("creating admin operator", err)
+	}
+
+	checker := health.NewChecker(cfg.Timeout)
+	commands := view.NewCLI(orderService, validationService, locationService, operatorService, notificationService, idempotencyService, userService, courierService, holdService, paymentService, refundService, checker, cfg.ShutdownTimeout)
+	repository.RegisterHealth(checker)
+	commands.RegisterHealth(checker, cfg.HealthMaxBacklog)
+
+	var servers []server
+	if len(cfg.HTTPAddr) > 0 {
+		servers = append(servers, view.NewServer(cfg.HTTPAddr, orderService, validationService, locationService, operatorService, notificationService, idempotencyService, userService, courierService, holdService, paymentService, refundService))
+	}
+	if len(cfg.MetricsAddr) > 0 {
+		repository.RegisterMetrics(metrics.Default)
+		commands.RegisterMetrics(metrics.Default)
+		servers = append(servers, view.NewOpsServer(cfg.MetricsAddr, metrics.Default, checker))
+	}
+	for _, srv := range servers {
+		go func(srv server) {
+			if err := srv.Run(); err != nil {
+				slog.Error("server stopped", "err", err)
+			}
+		}(srv)
+	}
+
+	jobs := []func(context.Context){notificationService.Run, idempotencyService.Run}
+	// Without a broker the events wait in the outbox, marking them published would lose them
+	if len(cfg.OutboxFile) > 0 {
+		relay := outbox.NewRelay(repository, outbox.NewFileBroker(cfg.OutboxFile), cfg.OutboxInterval, cfg.OutboxBatchSize)
+		jobs = append(jobs, relay.Run)
+	} else {
+		slog.Warn("OUTBOX_FILE is not set, order events are kept in the outbox until a broker is configured")
+	}
+
+	// Background jobs use the pool, they are stopped before it's closed
+	backgroundCtx, stopBackground := context.WithCancel(ctx)
+	var background sync.WaitGroup
+	for _, job := range jobs {
+		background.Add(1)
+		go func(job func(context.Context)) {
+			defer background.Done()
+			job(backgroundCtx)
+		}(job)
+	}
 
-	commands := view.NewCLI(orderService, validationService)
 	if err := commands.Run(); err != nil {
-		log.Fatal(err)
+		fatal("running CLI", err)
 	}
 
-	fmt.Println("Bye!")
+	stopBackground()
+	background.Wait()
+
+	// The CLI has drained its workers, give the HTTP requests the same grace period before the pool goes away
+	shutdownCtx, cancel := context.WithTimeout(ctx, cfg.ShutdownTimeout)
+	defer cancel()
+	for _, srv := range servers {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("server shutdown", "err", err)
+		}
+	}
+	repository.Close()
+
+	fmt.Println(i18n.T(i18n.MsgBye))
+}
+
+// migrate runs `migrate up|down|status` against the configured database
+func migrate(ctx context.Context, cfg *models.Config, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: migrate up|down|status")
+	}
+
+	pool, err := db.Connect(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	migrator, err := db.NewMigrator(pool, migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		return migrator.Down(ctx)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%-16s%-30s%s\n", "version", "name", "applied_at")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.Applied {
+				appliedAt = status.AppliedAt.Format(time.DateTime)
+			}
+			fmt.Printf("%-16d%-30s%s\n", status.Version, status.Name, appliedAt)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown migrate command %q, use up, down or status", args[0])
+}
+
+type server interface {
+	Run() error
+	Shutdown(ctx context.Context) error
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	fmt.Fprintf(os.Stderr, "%s: %v\n", msg, err)
+	os.Exit(1)
 }
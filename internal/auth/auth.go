@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"golang.org/x/crypto/bcrypt"
+	"homework/internal/models"
+	"homework/internal/util"
+)
+
+// System is recorded as the actor when a mutation runs without a logged in operator
+const System = "system"
+
+type operatorKey struct{}
+
+func WithOperator(ctx context.Context, operator models.Operator) context.Context {
+	return context.WithValue(ctx, operatorKey{}, operator)
+}
+
+func OperatorFromContext(ctx context.Context) (models.Operator, bool) {
+	operator, ok := ctx.Value(operatorKey{}).(models.Operator)
+	return operator, ok
+}
+
+// Actor returns the login of the operator acting in ctx
+func Actor(ctx context.Context) string {
+	if operator, ok := OperatorFromContext(ctx); ok {
+		return operator.Login
+	}
+	return System
+}
+
+// Authorize checks that the operator in ctx has at least the required role,
+// an empty role means the command is available to everyone
+func Authorize(ctx context.Context, required models.Role) error {
+	if len(required) == 0 {
+		return nil
+	}
+	operator, ok := OperatorFromContext(ctx)
+	if !ok {
+		return util.ErrNotLoggedIn
+	}
+	if operator.Role.Level() < required.Level() {
+		return util.ErrPermissionDenied
+	}
+	return nil
+}
+
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// NewAPIKey returns a random key for the operator and the hash that is kept in the db
+func NewAPIKey() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	key := hex.EncodeToString(buf)
+	return key, HashAPIKey(key), nil
+}
+
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,178 @@
+package i18n
+
+var en = map[Key]string{
+	HelpTitle:             "Command list:",
+	HelpColumnCommand:     "Command",
+	HelpColumnDescription: "Description",
+	HelpColumnExample:     "Example",
+
+	CmdHelp:             "Help",
+	CmdAccept:           "Accept an order",
+	CmdReturnToCourier:  "Return an order to the courier",
+	CmdIssue:            "Issue orders to a client",
+	CmdAcceptReturn:     "Accept a return",
+	CmdListReturns:      "List returns",
+	CmdRefunds:          "List refunds, the latest first",
+	CmdListOrders:       "List client's orders",
+	CmdUser:             "Customer card",
+	CmdAddUser:          "Add a customer",
+	CmdAddCourier:       "Add a courier",
+	CmdCourierReturn:    "Return expired orders and client returns to their courier",
+	CmdHold:             "Hold orders while serving the customer",
+	CmdRelease:          "Release your holds",
+	CmdHolds:            "List active holds",
+	CmdSearch:           "Search orders",
+	CmdLocations:        "Cell occupancy",
+	CmdSetMaxGoroutines: "Max number of goroutines",
+	CmdStatus:           "Worker status",
+	CmdJobs:             "Command queue",
+	CmdHealth:           "Readiness checks",
+	CmdNotifications:    "Order notifications",
+	CmdLogin:            "Log in",
+	CmdLogout:           "Log out",
+	CmdAddOperator:      "Add an operator",
+	CmdExit:             "Exit",
+
+	MsgShutdownSignal:   "Received shutdown signal, waiting up to %s for running commands, repeat to force exit",
+	MsgForcedExit:       "Forced exit",
+	MsgExiting:          "All goroutines finished. Exiting...",
+	MsgAbortedCommands:  "Aborted commands:",
+	MsgBye:              "Bye!",
+	MsgUnknownCommand:   "Unknown command. Type 'help' for a list of commands.",
+	MsgGoroutinesSet:    "Number of goroutines set to %d",
+	MsgStatusLimit:      "Max goroutines: %d",
+	MsgStatusRunning:    "Running:        %d",
+	MsgStatusQueued:     "Queued:         %d",
+	MsgStatusOperator:   "Operator:       %s (%s)",
+	MsgReady:            "Ready",
+	MsgNotReady:         "Not ready",
+	MsgCalculatingHash:  "Calculating hash.",
+	MsgOrderAccepted:    "Order accepted, put it into cell %s.",
+	MsgOrderIssued:      "Order %s issued, take it from cell %s",
+	MsgReturnAccepted:   "Return accepted.",
+	MsgOrderReturned:    "Order returned.",
+	MsgOccupiedCells:    "Occupied cells: %d/%d",
+	MsgLoggedIn:         "Logged in as %s (%s)",
+	MsgLoggedOut:        "%s logged out",
+	MsgOperatorCreated:  "Operator %s created, API key: %s",
+	MsgAdminCreated:     "Admin operator created, API key: %s",
+	MsgClockOverridden:  "Clock set to %s, dates are checked against it, not the real time",
+	MsgCourierCreated:   "Courier %s created",
+	MsgManifestExported: "Manifest %d: courier %s takes %d orders, exported to %s",
+	MsgOrderHeld:        "Order %s held until %s",
+	MsgHoldsReleased:    "Holds released",
+	MsgReceiptTitle:     "Receipt %d, %s, operator %s, customer %s",
+	MsgReceiptGoods:     "goods",
+	MsgReceiptPackage:   "package %s",
+	MsgReceiptTotal:     "Total:   %v",
+	MsgReceiptPrepaid:   "Prepaid: %v",
+	MsgReceiptDue:       "Due:     %v",
+	MsgReceiptPaid:      "Paid:    %v (%s)",
+	MsgReceiptChange:    "Change:  %v",
+	MsgRefund:           "Refund %v (%s): goods %v, package %v, storage fees withheld %v",
+	MsgUserCreated:      "Customer %s created",
+	MsgUserTitle:        "Customer %s %s %s, since %s",
+	MsgUserSpend:        "Lifetime spend:   %v",
+	MsgUserFees:         "Storage fees due: %v",
+	MsgUserActive:       "Active orders:",
+	MsgUserReturns:      "Returns:",
+
+	ColumnID:           "id",
+	ColumnOrderID:      "order_id",
+	ColumnUserID:       "user_id",
+	ColumnOperator:     "operator",
+	ColumnCreatedAt:    "created_at",
+	ColumnStorageUntil: "storage_until",
+	ColumnIssuedAt:     "issued_at",
+	ColumnReturned:     "returned",
+	ColumnOrderPrice:   "order_price",
+	ColumnWeight:       "weight",
+	ColumnPackageType:  "package_type",
+	ColumnPackagePrice: "package_price",
+	ColumnCell:         "cell",
+	ColumnShelf:        "shelf",
+	ColumnOrders:       "orders",
+	ColumnExpiresAt:    "expires_at",
+	ColumnLeft:         "left",
+	ColumnEvent:        "event",
+	ColumnChannel:      "channel",
+	ColumnStatus:       "status",
+	ColumnAttempts:     "attempts",
+	ColumnSentAt:       "sent_at",
+	ColumnMessage:      "message",
+	ColumnPayment:      "payment",
+	ColumnMethod:       "method",
+	ColumnGoods:        "goods",
+	ColumnPackage:      "package",
+	ColumnWithheld:     "withheld",
+	ColumnAmount:       "amount",
+	ColumnItem:         "item",
+	ColumnJob:          "job",
+	ColumnState:        "state",
+	ColumnCorrelation:  "correlation",
+	ColumnAge:          "age",
+	ColumnCommand:      "command",
+	ColumnCheck:        "check",
+	ColumnDetail:       "detail",
+
+	NotifyAccepted: "Your order {{.ID}} has arrived at the pickup point, you can collect it until {{date .StorageUntil}}.",
+	NotifyExpiring: "Your order {{.ID}} is kept until {{date .StorageUntil}}, after that it will be sent back.",
+	NotifyIssued:   "Your order {{.ID}} has been issued, thank you!",
+	NotifyReturned: "We have accepted the return of your order {{.ID}}.",
+
+	ErrPriceNotProvided:      "error - price not provided",
+	ErrOrderPriceInvalid:     "error - invalid order price",
+	ErrWeightNotProvided:     "error - weight not provided",
+	ErrWeightExceeds:         "error - weight exceeds limit for this type of package",
+	ErrWeightInvalid:         "error - invalid weight",
+	ErrPackageTypeInvalid:    "error - invalid package type",
+	ErrDateInvalid:           "error - invalid date",
+	ErrOrderExists:           "error - order already exists",
+	ErrOrderNotFound:         "error - order not found",
+	ErrOrderIdInvalid:        "error - order id must be number",
+	ErrOrderExpired:          "error - order expired",
+	ErrOrderNotIssued:        "error - order not issued",
+	ErrOrderIssued:           "error - order issued",
+	ErrOrderIdNotProvided:    "error - order id not provided",
+	ErrUserIdNotProvided:     "error - user ids not provided",
+	ErrOrdersUserDiffers:     "error - order's user differs",
+	ErrOrderReturned:         "error - order has been returned",
+	ErrOrderDoesNotBelong:    "error - order does not belong to user",
+	ErrReturnPeriodExpired:   "error - order cant be returned (period is expired)",
+	ErrNoFreeCell:            "error - no free cell for this package",
+	ErrListParamInvalid:      "error - offset must be a number >= 0, limit a number from 1 to 100",
+	ErrArgumentsInvalid:      "error - invalid arguments",
+	ErrGoroutinesInvalid:     "error - number of goroutines must be > 0",
+	ErrNotLoggedIn:           "error - login required",
+	ErrPermissionDenied:      "error - permission denied",
+	ErrInvalidCredentials:    "error - invalid login or password",
+	ErrOperatorExists:        "error - operator already exists",
+	ErrOperatorNotFound:      "error - operator not found",
+	ErrRoleInvalid:           "error - invalid role",
+	ErrLoginNotProvided:      "error - login not provided",
+	ErrIdempotencyKeyReused:  "error - idempotency key was used for another request",
+	ErrIdempotencyInProgress: "error - request with this idempotency key is in progress",
+	ErrUserNotFound:          "error - user not found",
+	ErrUserExists:            "error - user already exists",
+	ErrCourierNotFound:       "error - courier not found",
+	ErrCourierExists:         "error - courier already exists",
+	ErrCourierIdNotProvided:  "error - courier id not provided",
+	ErrCourierIdInvalid:      "error - courier id may only have letters, digits, - and _",
+	ErrManifestEmpty:         "error - courier has no orders to return",
+	ErrManifestStale:         "error - orders changed while the manifest was built, try again",
+	ErrManifestKeyNotSet:     "error - MANIFEST_KEY is not set, manifests can't be signed",
+	ErrOrderHeld:             "error - order is held by another operator",
+	ErrPaymentMethodInvalid:  "error - payment method must be cash or card",
+	ErrPaidInvalid:           "error - paid amount must be a non-negative number",
+	ErrPrepaidInvalid:        "error - prepaid amount must be a non-negative number",
+	ErrPrepaidExceedsTotal:   "error - prepaid amount exceeds the orders total",
+	ErrPaymentInsufficient:   "error - paid amount is less than the amount due",
+	ErrCardAmountMismatch:    "error - card is charged exactly the amount due",
+	ErrPaymentNotFound:       "error - order was issued without a recorded payment",
+	ErrStatusInvalid:         "error - status must be stored, issued or returned",
+	ErrSortColumnInvalid:     "error - orders can't be sorted by this column",
+	ErrRangeInvalid:          "error - range start is after its end",
+	ErrStorageUnavailable:    "error - storage unavailable, try again later",
+	ErrCanceled:              "error - command canceled",
+	ErrInternal:              "error - internal error",
+}
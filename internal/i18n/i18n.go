@@ -0,0 +1,87 @@
+package i18n
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+type Locale string
+
+const (
+	English Locale = "en"
+	Russian Locale = "ru"
+)
+
+// Key identifies a message, the text for every locale lives in the catalogs below
+type Key string
+
+var catalogs = map[Locale]map[Key]string{
+	English: en,
+	Russian: ru,
+}
+
+// Catalog translates messages into one locale, a key it lacks falls back to English and then to the key itself
+type Catalog struct {
+	locale   Locale
+	messages map[Key]string
+}
+
+func New(locale Locale) *Catalog {
+	messages, ok := catalogs[locale]
+	if !ok {
+		locale, messages = English, en
+	}
+	return &Catalog{locale: locale, messages: messages}
+}
+
+func (c *Catalog) Locale() Locale {
+	return c.locale
+}
+
+// T returns the message for key formatted with args like fmt.Sprintf
+func (c *Catalog) T(key Key, args ...any) string {
+	message, ok := c.messages[key]
+	if !ok {
+		if message, ok = en[key]; !ok {
+			message = string(key)
+		}
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// ParseLocale accepts both -lang values and LANG values such as ru_RU.UTF-8,
+// it reports false for a locale without a catalog
+func ParseLocale(s string) (Locale, bool) {
+	lang := strings.ToLower(s)
+	if i := strings.IndexAny(lang, "_-.@"); i >= 0 {
+		lang = lang[:i]
+	}
+	if _, ok := catalogs[Locale(lang)]; ok {
+		return Locale(lang), true
+	}
+	return English, false
+}
+
+var defaultCatalog atomic.Pointer[Catalog]
+
+func init() {
+	defaultCatalog.Store(New(English))
+}
+
+// SetDefault sets the catalog used by T, it's called once on startup like slog.SetDefault
+func SetDefault(c *Catalog) {
+	defaultCatalog.Store(c)
+}
+
+func Default() *Catalog {
+	return defaultCatalog.Load()
+}
+
+// T translates with the default catalog
+func T(key Key, args ...any) string {
+	return Default().T(key, args...)
+}
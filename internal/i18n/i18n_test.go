@@ -0,0 +1,88 @@
+package i18n
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+var verb = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+// declaredKeys reads the Key constants from keys.go, so a key added there and forgotten in a catalog is caught too
+func declaredKeys(t *testing.T) []Key {
+	t.Helper()
+
+	file, err := parser.ParseFile(token.NewFileSet(), "keys.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []Key
+	ast.Inspect(file, func(n ast.Node) bool {
+		spec, ok := n.(*ast.ValueSpec)
+		if !ok {
+			return true
+		}
+		for _, value := range spec.Values {
+			if lit, ok := value.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+				s, err := strconv.Unquote(lit.Value)
+				if err != nil {
+					t.Fatal(err)
+				}
+				keys = append(keys, Key(s))
+			}
+		}
+		return false
+	})
+	if len(keys) == 0 {
+		t.Fatal("no keys found in keys.go")
+	}
+	return keys
+}
+
+// TestCatalogsComplete fails when a key is missing in any locale
+// or when the translations disagree on the format arguments
+func TestCatalogsComplete(t *testing.T) {
+	keys := declaredKeys(t)
+
+	for locale, messages := range catalogs {
+		for _, key := range keys {
+			message, ok := messages[key]
+			if !ok {
+				t.Errorf("%s: missing %q", locale, key)
+				continue
+			}
+			if want, got := verb.FindAllString(en[key], -1), verb.FindAllString(message, -1); !slices.Equal(want, got) {
+				t.Errorf("%s: %q has verbs %v, en has %v", locale, key, got, want)
+			}
+		}
+		if len(messages) > len(keys) {
+			t.Errorf("%s: %d messages for %d keys, drop the ones that are not declared in keys.go", locale, len(messages), len(keys))
+		}
+	}
+}
+
+func TestParseLocale(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Locale
+		ok   bool
+	}{
+		{"ru", Russian, true},
+		{"en", English, true},
+		{"ru_RU.UTF-8", Russian, true},
+		{"en-US", English, true},
+		{"C.UTF-8", English, false},
+		{"", English, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseLocale(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ParseLocale(%q) = %s, %v, want %s, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
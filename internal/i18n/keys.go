@@ -0,0 +1,191 @@
+package i18n
+
+// Help
+const (
+	HelpTitle             Key = "help.title"
+	HelpColumnCommand     Key = "help.column.command"
+	HelpColumnDescription Key = "help.column.description"
+	HelpColumnExample     Key = "help.column.example"
+
+	CmdHelp             Key = "cmd.help"
+	CmdAccept           Key = "cmd.accept"
+	CmdReturnToCourier  Key = "cmd.return_courier"
+	CmdIssue            Key = "cmd.issue"
+	CmdAcceptReturn     Key = "cmd.accept_return"
+	CmdListReturns      Key = "cmd.list_returns"
+	CmdRefunds          Key = "cmd.refunds"
+	CmdListOrders       Key = "cmd.list_orders"
+	CmdUser             Key = "cmd.user"
+	CmdAddUser          Key = "cmd.add_user"
+	CmdAddCourier       Key = "cmd.add_courier"
+	CmdCourierReturn    Key = "cmd.courier_return"
+	CmdHold             Key = "cmd.hold"
+	CmdRelease          Key = "cmd.release"
+	CmdHolds            Key = "cmd.holds"
+	CmdSearch           Key = "cmd.search"
+	CmdLocations        Key = "cmd.locations"
+	CmdSetMaxGoroutines Key = "cmd.set_mg"
+	CmdStatus           Key = "cmd.status"
+	CmdJobs             Key = "cmd.jobs"
+	CmdHealth           Key = "cmd.health"
+	CmdNotifications    Key = "cmd.notifications"
+	CmdLogin            Key = "cmd.login"
+	CmdLogout           Key = "cmd.logout"
+	CmdAddOperator      Key = "cmd.add_operator"
+	CmdExit             Key = "cmd.exit"
+)
+
+// Command output
+const (
+	MsgShutdownSignal   Key = "msg.shutdown_signal"
+	MsgForcedExit       Key = "msg.forced_exit"
+	MsgExiting          Key = "msg.exiting"
+	MsgAbortedCommands  Key = "msg.aborted_commands"
+	MsgBye              Key = "msg.bye"
+	MsgUnknownCommand   Key = "msg.unknown_command"
+	MsgGoroutinesSet    Key = "msg.goroutines_set"
+	MsgStatusLimit      Key = "msg.status.limit"
+	MsgStatusRunning    Key = "msg.status.running"
+	MsgStatusQueued     Key = "msg.status.queued"
+	MsgStatusOperator   Key = "msg.status.operator"
+	MsgReady            Key = "msg.ready"
+	MsgNotReady         Key = "msg.not_ready"
+	MsgCalculatingHash  Key = "msg.calculating_hash"
+	MsgOrderAccepted    Key = "msg.order_accepted"
+	MsgOrderIssued      Key = "msg.order_issued"
+	MsgReturnAccepted   Key = "msg.return_accepted"
+	MsgOrderReturned    Key = "msg.order_returned"
+	MsgOccupiedCells    Key = "msg.occupied_cells"
+	MsgLoggedIn         Key = "msg.logged_in"
+	MsgLoggedOut        Key = "msg.logged_out"
+	MsgOperatorCreated  Key = "msg.operator_created"
+	MsgAdminCreated     Key = "msg.admin_created"
+	MsgClockOverridden  Key = "msg.clock_overridden"
+	MsgCourierCreated   Key = "msg.courier_created"
+	MsgManifestExported Key = "msg.manifest_exported"
+	MsgOrderHeld        Key = "msg.order_held"
+	MsgHoldsReleased    Key = "msg.holds_released"
+	MsgReceiptTitle     Key = "msg.receipt.title"
+	MsgReceiptGoods     Key = "msg.receipt.goods"
+	MsgReceiptPackage   Key = "msg.receipt.package"
+	MsgReceiptTotal     Key = "msg.receipt.total"
+	MsgReceiptPrepaid   Key = "msg.receipt.prepaid"
+	MsgReceiptDue       Key = "msg.receipt.due"
+	MsgReceiptPaid      Key = "msg.receipt.paid"
+	MsgReceiptChange    Key = "msg.receipt.change"
+	MsgRefund           Key = "msg.refund"
+	MsgUserCreated      Key = "msg.user_created"
+	MsgUserTitle        Key = "msg.user.title"
+	MsgUserSpend        Key = "msg.user.spend"
+	MsgUserFees         Key = "msg.user.fees"
+	MsgUserActive       Key = "msg.user.active"
+	MsgUserReturns      Key = "msg.user.returns"
+)
+
+// Table columns
+const (
+	ColumnID           Key = "column.id"
+	ColumnOrderID      Key = "column.order_id"
+	ColumnUserID       Key = "column.user_id"
+	ColumnOperator     Key = "column.operator"
+	ColumnCreatedAt    Key = "column.created_at"
+	ColumnStorageUntil Key = "column.storage_until"
+	ColumnIssuedAt     Key = "column.issued_at"
+	ColumnReturned     Key = "column.returned"
+	ColumnOrderPrice   Key = "column.order_price"
+	ColumnWeight       Key = "column.weight"
+	ColumnPackageType  Key = "column.package_type"
+	ColumnPackagePrice Key = "column.package_price"
+	ColumnCell         Key = "column.cell"
+	ColumnShelf        Key = "column.shelf"
+	ColumnOrders       Key = "column.orders"
+	ColumnExpiresAt    Key = "column.expires_at"
+	ColumnLeft         Key = "column.left"
+	ColumnEvent        Key = "column.event"
+	ColumnChannel      Key = "column.channel"
+	ColumnStatus       Key = "column.status"
+	ColumnAttempts     Key = "column.attempts"
+	ColumnSentAt       Key = "column.sent_at"
+	ColumnMessage      Key = "column.message"
+	ColumnPayment      Key = "column.payment"
+	ColumnMethod       Key = "column.method"
+	ColumnGoods        Key = "column.goods"
+	ColumnPackage      Key = "column.package"
+	ColumnWithheld     Key = "column.withheld"
+	ColumnAmount       Key = "column.amount"
+	ColumnItem         Key = "column.item"
+	ColumnJob          Key = "column.job"
+	ColumnState        Key = "column.state"
+	ColumnCorrelation  Key = "column.correlation"
+	ColumnAge          Key = "column.age"
+	ColumnCommand      Key = "column.command"
+	ColumnCheck        Key = "column.check"
+	ColumnDetail       Key = "column.detail"
+)
+
+// Customer notifications, these are text/template templates executed with the order
+const (
+	NotifyAccepted Key = "notify.accepted"
+	NotifyExpiring Key = "notify.expiring"
+	NotifyIssued   Key = "notify.issued"
+	NotifyReturned Key = "notify.returned"
+)
+
+// Errors, see the util sentinels
+const (
+	ErrPriceNotProvided      Key = "err.price_not_provided"
+	ErrOrderPriceInvalid     Key = "err.order_price_invalid"
+	ErrWeightNotProvided     Key = "err.weight_not_provided"
+	ErrWeightExceeds         Key = "err.weight_exceeds"
+	ErrWeightInvalid         Key = "err.weight_invalid"
+	ErrPackageTypeInvalid    Key = "err.package_type_invalid"
+	ErrDateInvalid           Key = "err.date_invalid"
+	ErrOrderExists           Key = "err.order_exists"
+	ErrOrderNotFound         Key = "err.order_not_found"
+	ErrOrderIdInvalid        Key = "err.order_id_invalid"
+	ErrOrderExpired          Key = "err.order_expired"
+	ErrOrderNotIssued        Key = "err.order_not_issued"
+	ErrOrderIssued           Key = "err.order_issued"
+	ErrOrderIdNotProvided    Key = "err.order_id_not_provided"
+	ErrUserIdNotProvided     Key = "err.user_id_not_provided"
+	ErrOrdersUserDiffers     Key = "err.orders_user_differs"
+	ErrOrderReturned         Key = "err.order_returned"
+	ErrOrderDoesNotBelong    Key = "err.order_does_not_belong"
+	ErrReturnPeriodExpired   Key = "err.return_period_expired"
+	ErrNoFreeCell            Key = "err.no_free_cell"
+	ErrListParamInvalid      Key = "err.list_param_invalid"
+	ErrArgumentsInvalid      Key = "err.arguments_invalid"
+	ErrGoroutinesInvalid     Key = "err.goroutines_invalid"
+	ErrNotLoggedIn           Key = "err.not_logged_in"
+	ErrPermissionDenied      Key = "err.permission_denied"
+	ErrInvalidCredentials    Key = "err.invalid_credentials"
+	ErrOperatorExists        Key = "err.operator_exists"
+	ErrOperatorNotFound      Key = "err.operator_not_found"
+	ErrRoleInvalid           Key = "err.role_invalid"
+	ErrLoginNotProvided      Key = "err.login_not_provided"
+	ErrIdempotencyKeyReused  Key = "err.idempotency_key_reused"
+	ErrIdempotencyInProgress Key = "err.idempotency_in_progress"
+	ErrUserNotFound          Key = "err.user_not_found"
+	ErrUserExists            Key = "err.user_exists"
+	ErrCourierNotFound       Key = "err.courier_not_found"
+	ErrCourierExists         Key = "err.courier_exists"
+	ErrCourierIdNotProvided  Key = "err.courier_id_not_provided"
+	ErrCourierIdInvalid      Key = "err.courier_id_invalid"
+	ErrManifestEmpty         Key = "err.manifest_empty"
+	ErrManifestStale         Key = "err.manifest_stale"
+	ErrManifestKeyNotSet     Key = "err.manifest_key_not_set"
+	ErrOrderHeld             Key = "err.order_held"
+	ErrPaymentMethodInvalid  Key = "err.payment_method_invalid"
+	ErrPaidInvalid           Key = "err.paid_invalid"
+	ErrPrepaidInvalid        Key = "err.prepaid_invalid"
+	ErrPrepaidExceedsTotal   Key = "err.prepaid_exceeds_total"
+	ErrPaymentInsufficient   Key = "err.payment_insufficient"
+	ErrCardAmountMismatch    Key = "err.card_amount_mismatch"
+	ErrPaymentNotFound       Key = "err.payment_not_found"
+	ErrStatusInvalid         Key = "err.status_invalid"
+	ErrSortColumnInvalid     Key = "err.sort_column_invalid"
+	ErrRangeInvalid          Key = "err.range_invalid"
+	ErrStorageUnavailable    Key = "err.storage_unavailable"
+	ErrCanceled              Key = "err.canceled"
+	ErrInternal              Key = "err.internal"
+)
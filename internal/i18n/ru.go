@@ -0,0 +1,178 @@
+package i18n
+
+var ru = map[Key]string{
+	HelpTitle:             "Список команд:",
+	HelpColumnCommand:     "Команда",
+	HelpColumnDescription: "Описание",
+	HelpColumnExample:     "Пример",
+
+	CmdHelp:             "Справка",
+	CmdAccept:           "Принять заказ",
+	CmdReturnToCourier:  "Вернуть заказ курьеру",
+	CmdIssue:            "Выдать заказ клиенту",
+	CmdAcceptReturn:     "Принять возврат",
+	CmdListReturns:      "Список возвратов",
+	CmdRefunds:          "Возвраты денег, последние первыми",
+	CmdListOrders:       "Список заказов",
+	CmdUser:             "Карточка клиента",
+	CmdAddUser:          "Добавить клиента",
+	CmdAddCourier:       "Добавить курьера",
+	CmdCourierReturn:    "Вернуть курьеру просроченные заказы и возвраты",
+	CmdHold:             "Придержать заказы на время выдачи",
+	CmdRelease:          "Снять свои удержания",
+	CmdHolds:            "Активные удержания",
+	CmdSearch:           "Поиск заказов",
+	CmdLocations:        "Занятость ячеек",
+	CmdSetMaxGoroutines: "Максимальное кол-во горутин",
+	CmdStatus:           "Состояние обработчиков",
+	CmdJobs:             "Очередь команд",
+	CmdHealth:           "Проверка готовности",
+	CmdNotifications:    "Уведомления по заказу",
+	CmdLogin:            "Войти",
+	CmdLogout:           "Выйти",
+	CmdAddOperator:      "Добавить оператора",
+	CmdExit:             "Выход",
+
+	MsgShutdownSignal:   "Получен сигнал остановки, ждём выполняющиеся команды до %s, повторите для немедленного выхода",
+	MsgForcedExit:       "Принудительный выход",
+	MsgExiting:          "Все горутины завершились. Выход...",
+	MsgAbortedCommands:  "Прерванные команды:",
+	MsgBye:              "До свидания!",
+	MsgUnknownCommand:   "Неизвестная команда. Введите 'help' для списка команд.",
+	MsgGoroutinesSet:    "Количество горутин: %d",
+	MsgStatusLimit:      "Макс. горутин:  %d",
+	MsgStatusRunning:    "Выполняется:    %d",
+	MsgStatusQueued:     "В очереди:      %d",
+	MsgStatusOperator:   "Оператор:       %s (%s)",
+	MsgReady:            "Готов",
+	MsgNotReady:         "Не готов",
+	MsgCalculatingHash:  "Вычисляем хеш.",
+	MsgOrderAccepted:    "Заказ принят, положите его в ячейку %s.",
+	MsgOrderIssued:      "Заказ %s выдан, возьмите его из ячейки %s",
+	MsgReturnAccepted:   "Возврат принят.",
+	MsgOrderReturned:    "Заказ возвращён.",
+	MsgOccupiedCells:    "Занято ячеек: %d/%d",
+	MsgLoggedIn:         "Вы вошли как %s (%s)",
+	MsgLoggedOut:        "%s вышел",
+	MsgOperatorCreated:  "Оператор %s создан, API-ключ: %s",
+	MsgAdminCreated:     "Создан оператор admin, API-ключ: %s",
+	MsgClockOverridden:  "Часы переведены на %s, сроки проверяются по ним, а не по реальному времени",
+	MsgCourierCreated:   "Курьер %s добавлен",
+	MsgManifestExported: "Накладная %d: курьер %s забирает заказов: %d, файл %s",
+	MsgOrderHeld:        "Заказ %s удержан до %s",
+	MsgHoldsReleased:    "Удержания сняты",
+	MsgReceiptTitle:     "Чек %d, %s, оператор %s, клиент %s",
+	MsgReceiptGoods:     "товар",
+	MsgReceiptPackage:   "упаковка %s",
+	MsgReceiptTotal:     "Итого:      %v",
+	MsgReceiptPrepaid:   "Предоплата: %v",
+	MsgReceiptDue:       "К оплате:   %v",
+	MsgReceiptPaid:      "Внесено:    %v (%s)",
+	MsgReceiptChange:    "Сдача:      %v",
+	MsgRefund:           "Возврат %v (%s): товар %v, упаковка %v, удержано за хранение %v",
+	MsgUserCreated:      "Клиент %s добавлен",
+	MsgUserTitle:        "Клиент %s %s %s, с %s",
+	MsgUserSpend:        "Всего покупок:    %v",
+	MsgUserFees:         "Долг за хранение: %v",
+	MsgUserActive:       "Заказы в пункте:",
+	MsgUserReturns:      "Возвраты:",
+
+	ColumnID:           "№",
+	ColumnOrderID:      "Заказ",
+	ColumnUserID:       "Клиент",
+	ColumnOperator:     "Оператор",
+	ColumnCreatedAt:    "Создан",
+	ColumnStorageUntil: "Хранить до",
+	ColumnIssuedAt:     "Выдан",
+	ColumnReturned:     "Возврат",
+	ColumnOrderPrice:   "Цена",
+	ColumnWeight:       "Вес",
+	ColumnPackageType:  "Упаковка",
+	ColumnPackagePrice: "Цена упак.",
+	ColumnCell:         "Ячейка",
+	ColumnShelf:        "Полка",
+	ColumnOrders:       "Заказы",
+	ColumnExpiresAt:    "Истекает",
+	ColumnLeft:         "Осталось",
+	ColumnEvent:        "Событие",
+	ColumnChannel:      "Канал",
+	ColumnStatus:       "Статус",
+	ColumnAttempts:     "Попытки",
+	ColumnSentAt:       "Отправлено",
+	ColumnMessage:      "Сообщение",
+	ColumnPayment:      "Платёж",
+	ColumnMethod:       "Способ",
+	ColumnGoods:        "Товар",
+	ColumnPackage:      "Упаковка",
+	ColumnWithheld:     "Удержано",
+	ColumnAmount:       "Сумма",
+	ColumnItem:         "Позиция",
+	ColumnJob:          "№",
+	ColumnState:        "Состояние",
+	ColumnCorrelation:  "Корреляция",
+	ColumnAge:          "Возраст",
+	ColumnCommand:      "Команда",
+	ColumnCheck:        "Проверка",
+	ColumnDetail:       "Детали",
+
+	NotifyAccepted: "Ваш заказ {{.ID}} прибыл в пункт выдачи, забрать его можно до {{date .StorageUntil}}.",
+	NotifyExpiring: "Ваш заказ {{.ID}} хранится до {{date .StorageUntil}}, после этого он будет возвращён.",
+	NotifyIssued:   "Ваш заказ {{.ID}} выдан, спасибо!",
+	NotifyReturned: "Мы приняли возврат заказа {{.ID}}.",
+
+	ErrPriceNotProvided:      "ошибка - не указана цена",
+	ErrOrderPriceInvalid:     "ошибка - неверная цена заказа",
+	ErrWeightNotProvided:     "ошибка - не указан вес",
+	ErrWeightExceeds:         "ошибка - вес превышает предел для этой упаковки",
+	ErrWeightInvalid:         "ошибка - неверный вес",
+	ErrPackageTypeInvalid:    "ошибка - неверный тип упаковки",
+	ErrDateInvalid:           "ошибка - неверная дата",
+	ErrOrderExists:           "ошибка - заказ уже существует",
+	ErrOrderNotFound:         "ошибка - заказ не найден",
+	ErrOrderIdInvalid:        "ошибка - id заказа должен быть числом",
+	ErrOrderExpired:          "ошибка - срок хранения заказа истёк",
+	ErrOrderNotIssued:        "ошибка - заказ не выдан",
+	ErrOrderIssued:           "ошибка - заказ уже выдан",
+	ErrOrderIdNotProvided:    "ошибка - не указан id заказа",
+	ErrUserIdNotProvided:     "ошибка - не указан id клиента",
+	ErrOrdersUserDiffers:     "ошибка - заказы принадлежат разным клиентам",
+	ErrOrderReturned:         "ошибка - заказ возвращён",
+	ErrOrderDoesNotBelong:    "ошибка - заказ не принадлежит клиенту",
+	ErrReturnPeriodExpired:   "ошибка - заказ нельзя вернуть (срок возврата истёк)",
+	ErrNoFreeCell:            "ошибка - нет свободной ячейки для этой упаковки",
+	ErrListParamInvalid:      "ошибка - смещение должно быть числом >= 0, лимит - числом от 1 до 100",
+	ErrArgumentsInvalid:      "ошибка - неверные аргументы",
+	ErrGoroutinesInvalid:     "ошибка - количество горутин должно быть > 0",
+	ErrNotLoggedIn:           "ошибка - необходимо войти",
+	ErrPermissionDenied:      "ошибка - недостаточно прав",
+	ErrInvalidCredentials:    "ошибка - неверный логин или пароль",
+	ErrOperatorExists:        "ошибка - оператор уже существует",
+	ErrOperatorNotFound:      "ошибка - оператор не найден",
+	ErrRoleInvalid:           "ошибка - неверная роль",
+	ErrLoginNotProvided:      "ошибка - не указан логин",
+	ErrIdempotencyKeyReused:  "ошибка - ключ идемпотентности уже использован для другого запроса",
+	ErrIdempotencyInProgress: "ошибка - запрос с этим ключом идемпотентности ещё выполняется",
+	ErrUserNotFound:          "ошибка - клиент не найден",
+	ErrUserExists:            "ошибка - клиент уже существует",
+	ErrCourierNotFound:       "ошибка - курьер не найден",
+	ErrCourierExists:         "ошибка - курьер уже существует",
+	ErrCourierIdNotProvided:  "ошибка - не указан курьер",
+	ErrCourierIdInvalid:      "ошибка - id курьера может состоять только из букв, цифр, - и _",
+	ErrManifestEmpty:         "ошибка - у курьера нет заказов к возврату",
+	ErrManifestStale:         "ошибка - заказы изменились, пока собиралась накладная, повторите",
+	ErrManifestKeyNotSet:     "ошибка - не задан MANIFEST_KEY, накладную нечем подписать",
+	ErrOrderHeld:             "ошибка - заказ удержан другим оператором",
+	ErrPaymentMethodInvalid:  "ошибка - способ оплаты должен быть cash или card",
+	ErrPaidInvalid:           "ошибка - сумма оплаты должна быть неотрицательным числом",
+	ErrPrepaidInvalid:        "ошибка - сумма предоплаты должна быть неотрицательным числом",
+	ErrPrepaidExceedsTotal:   "ошибка - предоплата больше стоимости заказов",
+	ErrPaymentInsufficient:   "ошибка - внесено меньше суммы к оплате",
+	ErrCardAmountMismatch:    "ошибка - с карты списывается ровно сумма к оплате",
+	ErrPaymentNotFound:       "ошибка - заказ выдан без записанной оплаты",
+	ErrStatusInvalid:         "ошибка - статус должен быть stored, issued или returned",
+	ErrSortColumnInvalid:     "ошибка - по этому столбцу нельзя сортировать",
+	ErrRangeInvalid:          "ошибка - начало диапазона позже его конца",
+	ErrStorageUnavailable:    "ошибка - хранилище недоступно, повторите позже",
+	ErrCanceled:              "ошибка - команда отменена",
+	ErrInternal:              "ошибка - внутренняя ошибка",
+}
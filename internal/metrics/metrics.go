@@ -0,0 +1,43 @@
+package metrics
+
+// Default is the registry served at /metrics
+var Default = NewRegistry()
+
+var (
+	CommandsTotal = Default.NewCounterVec(
+		"pvz_commands_total",
+		"Commands processed, by command name and result.",
+		"command", "status",
+	)
+	CommandDuration = Default.NewHistogramVec(
+		"pvz_command_duration_seconds",
+		"Time spent processing a command.",
+		DefaultBuckets,
+		"command",
+	)
+	SemaphoreWait = Default.NewHistogramVec(
+		"pvz_semaphore_wait_seconds",
+		"Time a worker waited for a free slot before running its command.",
+		DefaultBuckets,
+	)
+	HashDuration = Default.NewHistogramVec(
+		"pvz_hash_generation_seconds",
+		"Time spent generating an order hash.",
+		DefaultBuckets,
+	)
+	OutboxEventsTotal = Default.NewCounterVec(
+		"pvz_outbox_events_total",
+		"Outbox events handed to the broker, by result.",
+		"status",
+	)
+	NotificationsTotal = Default.NewCounterVec(
+		"pvz_notifications_total",
+		"Customer notifications, by event and delivery status.",
+		"event", "status",
+	)
+)
+
+const (
+	StatusOK    = "ok"
+	StatusError = "error"
+)
@@ -0,0 +1,13 @@
+package models
+
+// Cell is a storage location on a shelf, sized for one package type. A MaxWeight of 0 is no weight limit,
+// film cells have none
+type Cell struct {
+	ID          string      `db:"id" json:"id"`
+	Shelf       string      `db:"shelf" json:"shelf"`
+	PackageType PackageType `db:"package_type" json:"package_type"`
+	MaxWeight   Weight      `db:"max_weight" json:"max_weight"`
+	UsedWeight  Weight      `db:"used_weight" json:"used_weight"`
+	MaxOrders   int         `db:"max_orders" json:"max_orders"`
+	OrdersCount int         `db:"orders_count" json:"orders_count"`
+}
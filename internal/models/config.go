@@ -2,12 +2,71 @@ package models
 
 import "time"
 
+// Config is filled by util.LoadConfig: defaults, then the dotenv file, the config file, environment and flags.
+// The env tag names the variable, file keys and flags are the same name in lower case
 type Config struct {
-	User     string        `env:"POSTGRES_USER"`
-	Password string        `env:"POSTGRES_PASSWORD"`
-	Host     string        `env:"DB_HOST"`
-	Port     string        `env:"DB_PORT"`
-	DBName   string        `env:"POSTGRES_DB"`
-	Attempts int           `env:"ATTEMPTS"`
-	Timeout  time.Duration `env:"TIMEOUT"`
+	User     string `env:"POSTGRES_USER" required:"true"`
+	Password string `env:"POSTGRES_PASSWORD" secret:"true"`
+	Host     string `env:"DB_HOST" default:"localhost"`
+	Port     string `env:"DB_PORT" default:"5432"`
+	DBName   string `env:"POSTGRES_DB" required:"true"`
+	// DBSchema puts the tables in a schema other than public, cmd/explain uses it to work on a throwaway copy
+	DBSchema string        `env:"DB_SCHEMA"`
+	Attempts int           `env:"ATTEMPTS" default:"5"`
+	Timeout  time.Duration `env:"TIMEOUT" default:"5s"`
+
+	RetryDelay    time.Duration `env:"RETRY_DELAY" default:"200ms"`
+	RetryMaxDelay time.Duration `env:"RETRY_MAX_DELAY" default:"5s"`
+
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" default:"10s"`
+	// HealthMaxBacklog is the number of queued commands and pending hashes above which the app reports not ready
+	HealthMaxBacklog int `env:"HEALTH_MAX_BACKLOG" default:"100"`
+
+	// NotifyFile is where the file channel writes customer notifications, notifications are off when it's empty
+	NotifyFile          string        `env:"NOTIFY_FILE" default:"notifications.jsonl"`
+	NotifyExpiryWindow  time.Duration `env:"NOTIFY_EXPIRY_WINDOW" default:"24h"`
+	NotifySweepInterval time.Duration `env:"NOTIFY_SWEEP_INTERVAL" default:"1m"`
+
+	// OutboxFile is where the file broker publishes order events, the relay is off when it's empty
+	// and the events stay in the outbox table
+	OutboxFile      string        `env:"OUTBOX_FILE" default:"outbox.jsonl"`
+	OutboxInterval  time.Duration `env:"OUTBOX_INTERVAL" default:"1s"`
+	OutboxBatchSize int           `env:"OUTBOX_BATCH_SIZE" default:"100"`
+
+	// IdempotencyTTL is how long the outcome of a command is kept under its idempotency key
+	IdempotencyTTL time.Duration `env:"IDEMPOTENCY_TTL" default:"24h"`
+	// IdempotencyLease is how long a key of a running command stays claimed, a key left pending longer can be claimed again.
+	// It has to outlast the retries of a command, ATTEMPTS times RETRY_MAX_DELAY, and the wait in the command queue
+	IdempotencyLease time.Duration `env:"IDEMPOTENCY_LEASE" default:"5m"`
+
+	// AutoCreateUsers lets accept add a customer it doesn't know, otherwise they have to be added with add_user first
+	AutoCreateUsers bool `env:"AUTO_CREATE_USERS" default:"false"`
+	// StorageFeePerDay is charged for every started day an order stays in the pickup point past its storage date
+	StorageFeePerDay Price `env:"STORAGE_FEE_PER_DAY" default:"10"`
+	// RefundPackage gives the package price back with a client return, by default the customer pays for it anyway
+	RefundPackage bool `env:"REFUND_PACKAGE" default:"false"`
+	// RefundWithholdFees keeps the storage fees of a returned order out of its refund
+	RefundWithholdFees bool `env:"REFUND_WITHHOLD_FEES" default:"true"`
+
+	// ManifestDir is where courier return manifests are exported, ManifestKey signs them
+	ManifestDir string `env:"MANIFEST_DIR" default:"manifests"`
+	ManifestKey string `env:"MANIFEST_KEY" secret:"true"`
+
+	// HoldTTL is how long an order stays held for the operator who placed the hold
+	HoldTTL time.Duration `env:"HOLD_TTL" default:"10m"`
+
+	HTTPAddr      string `env:"HTTP_ADDR"`
+	MetricsAddr   string `env:"METRICS_ADDR"`
+	AdminPassword string `env:"ADMIN_PASSWORD" secret:"true"`
+
+	LogFormat string `env:"LOG_FORMAT" default:"text"`
+	LogLevel  string `env:"LOG_LEVEL" default:"info"`
+	LogFile   string `env:"LOG_FILE"`
+
+	// Now makes the services see another time, support uses it to reproduce what a customer saw.
+	// The clock keeps running from that instant, the zero value means the wall clock
+	Now time.Time `env:"NOW"`
+
+	// Lang picks the message catalog, it accepts the usual LANG values such as ru_RU.UTF-8
+	Lang string `env:"LANG" default:"en"`
 }
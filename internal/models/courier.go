@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Courier brings orders to the pickup point and takes back the ones not picked up
+type Courier struct {
+	ID        string    `db:"id" json:"id"`
+	Name      string    `db:"name" json:"name"`
+	Phone     string    `db:"phone" json:"phone"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// Manifest lists the orders handed to a courier in one courier return
+type Manifest struct {
+	ID        int64     `db:"id" json:"id"`
+	CourierID string    `db:"courier_id" json:"courier_id"`
+	Operator  string    `db:"operator" json:"operator"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	Orders    []Order   `db:"orders" json:"orders"`
+	// Signature is the HMAC of the rest of the manifest, the courier's side checks the exported file with it
+	Signature string `db:"-" json:"signature,omitempty"`
+	// File is where the manifest was exported
+	File string `db:"-" json:"file,omitempty"`
+}
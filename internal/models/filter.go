@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// OrderStatus is where an order is in its life, the search filters by it
+type OrderStatus string
+
+const (
+	// StatusStored orders wait in the pickup point, expired ones included
+	StatusStored   OrderStatus = "stored"
+	StatusIssued   OrderStatus = "issued"
+	StatusReturned OrderStatus = "returned"
+)
+
+// Status derives the status from the order flags, a returned order stays issued
+func (o Order) Status() OrderStatus {
+	switch {
+	case o.Returned:
+		return StatusReturned
+	case o.Issued:
+		return StatusIssued
+	}
+	return StatusStored
+}
+
+// ReturnableToCourier tells if the order goes back to its courier at now: it expired in the pickup point
+// or the customer returned it
+func (o Order) ReturnableToCourier(now time.Time) bool {
+	return o.Returned || (!o.Issued && now.After(o.StorageUntil))
+}
+
+// SortColumns are the order columns a search can be sorted by
+var SortColumns = []string{"id", "user_id", "storage_until", "issued", "issued_at", "returned", "order_price", "weight", "package_type", "package_price", "cell_id"}
+
+// OrderFilter selects the orders Storage.Find returns, a zero field doesn't filter.
+// The time ranges include From and exclude To, the price and weight ranges include both ends
+type OrderFilter struct {
+	Status      OrderStatus
+	IDPrefix    string
+	UserID      string
+	PackageType PackageType
+
+	StorageFrom time.Time
+	StorageTo   time.Time
+	IssuedFrom  time.Time
+	IssuedTo    time.Time
+
+	PriceMin  Price
+	PriceMax  Price
+	WeightMin Weight
+	WeightMax Weight
+
+	// SortBy is one of SortColumns, orders with equal values keep the id order
+	SortBy string
+	Desc   bool
+	Offset int
+	Limit  int
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// Hold reserves an order for the operator serving its customer, no one else can issue or return it until it expires
+type Hold struct {
+	OrderID   string    `db:"order_id" json:"order_id"`
+	Operator  string    `db:"operator" json:"operator"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	ExpiresAt time.Time `db:"expires_at" json:"expires_at"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type IdempotencyStatus string
+
+const (
+	IdempotencyPending IdempotencyStatus = "pending"
+	IdempotencyDone    IdempotencyStatus = "done"
+)
+
+// IdempotencyRecord is the outcome of a mutating command stored under the client's key,
+// either Response or the Error fields are set once the command is done
+type IdempotencyRecord struct {
+	Operator     string            `db:"operator"`
+	Key          string            `db:"key"`
+	Command      string            `db:"command"`
+	Fingerprint  string            `db:"fingerprint"`
+	Status       IdempotencyStatus `db:"status"`
+	Response     json.RawMessage   `db:"response"`
+	ErrorCode    string            `db:"error_code"`
+	ErrorField   string            `db:"error_field"`
+	ErrorMessage string            `db:"error_message"`
+	CreatedAt    time.Time         `db:"created_at"`
+	ExpiresAt    time.Time         `db:"expires_at"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+type NotificationEvent string
+
+const (
+	EventAccepted NotificationEvent = "accepted"
+	EventExpiring NotificationEvent = "expiring"
+	EventIssued   NotificationEvent = "issued"
+	EventReturned NotificationEvent = "returned"
+)
+
+type NotificationStatus string
+
+const (
+	NotificationPending NotificationStatus = "pending"
+	NotificationSent    NotificationStatus = "sent"
+	NotificationFailed  NotificationStatus = "failed"
+)
+
+// Notification is one message to a customer sent through one channel, an order gets at most one per event and channel
+type Notification struct {
+	OrderID   string             `db:"order_id" json:"order_id"`
+	UserID    string             `db:"user_id" json:"user_id"`
+	Event     NotificationEvent  `db:"event" json:"event"`
+	Channel   string             `db:"channel" json:"channel"`
+	Message   string             `db:"message" json:"message"`
+	Status    NotificationStatus `db:"status" json:"status"`
+	Attempts  int                `db:"attempts" json:"attempts"`
+	Error     string             `db:"error" json:"error,omitempty"`
+	CreatedAt time.Time          `db:"created_at" json:"created_at"`
+	// ClaimedAt is when the last send started
+	ClaimedAt time.Time  `db:"claimed_at" json:"claimed_at"`
+	SentAt    *time.Time `db:"sent_at" json:"sent_at,omitempty"`
+}
@@ -0,0 +1,29 @@
+package models
+
+type Role string
+
+const (
+	RoleClerk  Role = "clerk"
+	RoleSenior Role = "senior"
+	RoleAdmin  Role = "admin"
+)
+
+// Level orders roles so that a higher role can run everything a lower one can
+func (r Role) Level() int {
+	switch r {
+	case RoleClerk:
+		return 1
+	case RoleSenior:
+		return 2
+	case RoleAdmin:
+		return 3
+	}
+	return 0
+}
+
+type Operator struct {
+	Login        string `db:"login"`
+	Role         Role   `db:"role"`
+	PasswordHash string `db:"password_hash"`
+	APIKeyHash   string `db:"api_key_hash"`
+}
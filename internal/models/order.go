@@ -9,15 +9,18 @@ type Weight float64
 type PackageType string
 
 type Order struct {
-	ID           string      `db:"id"`
-	UserID       string      `db:"user_id"`
-	StorageUntil time.Time   `db:"storage_until"`
-	Issued       bool        `db:"issued"`
-	IssuedAt     time.Time   `db:"issued_at"`
-	Returned     bool        `db:"returned"`
-	OrderPrice   Price       `db:"order_price"`
-	Weight       Weight      `db:"weight"`
-	PackageType  PackageType `db:"package_type"`
-	PackagePrice Price       `db:"package_price"`
-	Hash         string      `db:"hash"`
+	ID           string      `db:"id" json:"id"`
+	UserID       string      `db:"user_id" json:"user_id"`
+	StorageUntil time.Time   `db:"storage_until" json:"storage_until"`
+	Issued       bool        `db:"issued" json:"issued"`
+	IssuedAt     time.Time   `db:"issued_at" json:"issued_at"`
+	Returned     bool        `db:"returned" json:"returned"`
+	OrderPrice   Price       `db:"order_price" json:"order_price"`
+	Weight       Weight      `db:"weight" json:"weight"`
+	PackageType  PackageType `db:"package_type" json:"package_type"`
+	PackagePrice Price       `db:"package_price" json:"package_price"`
+	Hash         string      `db:"hash" json:"hash"`
+	CellID       string      `db:"cell_id" json:"cell_id"`
+	// CourierID is the courier who brought the order, empty for the ones accepted before couriers were recorded
+	CourierID string `db:"courier_id" json:"courier_id,omitempty"`
 }
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type OutboxEventType string
+
+const (
+	OrderAccepted          OutboxEventType = "order.accepted"
+	OrderIssued            OutboxEventType = "order.issued"
+	OrderReturned          OutboxEventType = "order.returned"
+	OrderReturnedToCourier OutboxEventType = "order.returned_to_courier"
+)
+
+// OutboxEvent is an order change waiting to be published, it's written in the transaction of the change itself
+type OutboxEvent struct {
+	ID          int64           `db:"id" json:"id"`
+	OrderID     string          `db:"order_id" json:"order_id"`
+	Type        OutboxEventType `db:"event_type" json:"type"`
+	Payload     json.RawMessage `db:"payload" json:"payload"`
+	CreatedAt   time.Time       `db:"created_at" json:"created_at"`
+	PublishedAt *time.Time      `db:"published_at" json:"published_at,omitempty"`
+	Attempts    int             `db:"attempts" json:"attempts"`
+	LastError   string          `db:"last_error" json:"last_error,omitempty"`
+}
@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+type PaymentMethod string
+
+const (
+	PaymentCash PaymentMethod = "cash"
+	PaymentCard PaymentMethod = "card"
+	// PaymentPrepaid is an issue the customer paid for in full before coming, nothing is taken at the counter
+	PaymentPrepaid PaymentMethod = "prepaid"
+)
+
+// Tender is what the customer offers at pickup, Prepaid is the part of the price they paid beforehand
+type Tender struct {
+	Method  PaymentMethod
+	Paid    Price
+	Prepaid Price
+}
+
+type LineKind string
+
+const (
+	LineGoods   LineKind = "goods"
+	LinePackage LineKind = "package"
+)
+
+// ReceiptLine is the goods or the package of one order, the two lines of an order add up to its price
+type ReceiptLine struct {
+	OrderID     string      `json:"order_id"`
+	Kind        LineKind    `json:"kind"`
+	PackageType PackageType `json:"package_type,omitempty"`
+	Amount      Price       `json:"amount"`
+}
+
+// Payment is taken once per issue for all its orders, Due is Total less Prepaid and Change is Paid less Due
+type Payment struct {
+	ID        int64         `db:"id" json:"id"`
+	UserID    string        `db:"user_id" json:"user_id"`
+	Operator  string        `db:"operator" json:"operator"`
+	CreatedAt time.Time     `db:"created_at" json:"created_at"`
+	Method    PaymentMethod `db:"method" json:"method"`
+	Total     Price         `db:"total" json:"total"`
+	Prepaid   Price         `db:"prepaid" json:"prepaid"`
+	Due       Price         `db:"due" json:"due"`
+	Paid      Price         `db:"paid" json:"paid"`
+	Change    Price         `db:"change_given" json:"change"`
+	Lines     []ReceiptLine `db:"lines" json:"lines"`
+}
+
+// Receipt is what an issue hands back: the payment and the orders it paid for
+type Receipt struct {
+	Payment Payment `json:"payment"`
+	Orders  []Order `json:"orders"`
+}
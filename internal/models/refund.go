@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Refund is what a customer gets back for a returned order, Amount is Goods and Package less Withheld
+type Refund struct {
+	ID int64 `db:"id" json:"id"`
+	// PaymentID is the payment the order was issued with, 0 for orders issued before payments were recorded
+	PaymentID int64         `db:"payment_id" json:"payment_id,omitempty"`
+	OrderID   string        `db:"order_id" json:"order_id"`
+	UserID    string        `db:"user_id" json:"user_id"`
+	Operator  string        `db:"operator" json:"operator"`
+	CreatedAt time.Time     `db:"created_at" json:"created_at"`
+	Method    PaymentMethod `db:"method" json:"method"`
+	Goods     Price         `db:"goods" json:"goods"`
+	Package   Price         `db:"package_price" json:"package"`
+	// Withheld are the storage fees kept out of the refund
+	Withheld Price `db:"withheld" json:"withheld"`
+	Amount   Price `db:"amount" json:"amount"`
+}
+
+// ReturnReceipt is what accepting a return hands back: the returned order and its refund
+type ReturnReceipt struct {
+	Order  Order  `json:"order"`
+	Refund Refund `json:"refund"`
+}
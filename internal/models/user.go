@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// User is a customer, orders are only accepted for a known one
+type User struct {
+	ID        string    `db:"id" json:"id"`
+	Name      string    `db:"name" json:"name"`
+	Phone     string    `db:"phone" json:"phone"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// UserSummary is the customer card shown by the user command
+type UserSummary struct {
+	User         User    `json:"user"`
+	ActiveOrders []Order `json:"active_orders"`
+	Returns      []Order `json:"returns"`
+	// LifetimeSpend is the price of the orders the customer took and kept
+	LifetimeSpend Price `json:"lifetime_spend"`
+	// OutstandingFees are owed for the active orders kept past their storage date
+	OutstandingFees Price `json:"outstanding_fees"`
+}
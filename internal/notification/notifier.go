@@ -0,0 +1,69 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"homework/internal/models"
+	"os"
+	"sync"
+	"time"
+)
+
+// Notifier delivers a rendered message to the customer through one channel, e.g. sms or email
+type Notifier interface {
+	// Channel names the channel, delivery status is recorded per channel
+	Channel() string
+	Send(ctx context.Context, notification models.Notification) error
+}
+
+// FileNotifier appends notifications to a JSON lines file, it stands in for a real channel on local runs
+type FileNotifier struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{path: path}
+}
+
+func (n *FileNotifier) Channel() string {
+	return "file"
+}
+
+type fileRecord struct {
+	UserID  string                   `json:"user_id"`
+	OrderID string                   `json:"order_id"`
+	Event   models.NotificationEvent `json:"event"`
+	Message string                   `json:"message"`
+	SentAt  time.Time                `json:"sent_at"`
+}
+
+func (n *FileNotifier) Send(ctx context.Context, notification models.Notification) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(fileRecord{
+		UserID:  notification.UserID,
+		OrderID: notification.OrderID,
+		Event:   notification.Event,
+		Message: notification.Message,
+		SentAt:  time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,43 @@
+package notification
+
+import (
+	"fmt"
+	"homework/internal/i18n"
+	"homework/internal/models"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templates are kept in the message catalog, so customers get them in the locale of the pickup point
+var templates = map[models.NotificationEvent]i18n.Key{
+	models.EventAccepted: i18n.NotifyAccepted,
+	models.EventExpiring: i18n.NotifyExpiring,
+	models.EventIssued:   i18n.NotifyIssued,
+	models.EventReturned: i18n.NotifyReturned,
+}
+
+var funcs = template.FuncMap{
+	"date": func(t time.Time) string {
+		return t.Format(time.DateOnly)
+	},
+}
+
+// Render fills the template of the event with the order
+func Render(event models.NotificationEvent, order models.Order) (string, error) {
+	key, ok := templates[event]
+	if !ok {
+		return "", fmt.Errorf("no template for event %q", event)
+	}
+
+	tmpl, err := template.New(string(event)).Funcs(funcs).Parse(i18n.T(key))
+	if err != nil {
+		return "", fmt.Errorf("parsing %s template: %w", event, err)
+	}
+
+	var sb strings.Builder
+	if err = tmpl.Execute(&sb, order); err != nil {
+		return "", fmt.Errorf("rendering %s notification: %w", event, err)
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,49 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"homework/internal/models"
+	"os"
+	"sync"
+)
+
+// Publisher hands an event to the message broker, the order id is the partition key,
+// so a broker that keeps per-key order delivers an order's events in the order they happened
+type Publisher interface {
+	Publish(ctx context.Context, event models.OutboxEvent) error
+}
+
+// FileBroker appends events to a JSON lines file, consumers can tail it
+type FileBroker struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileBroker(path string) *FileBroker {
+	return &FileBroker{path: path}
+}
+
+func (b *FileBroker) Publish(ctx context.Context, event models.OutboxEvent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	f, err := os.OpenFile(b.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,80 @@
+package outbox
+
+import (
+	"context"
+	"homework/internal/logger"
+	"homework/internal/metrics"
+	"homework/internal/models"
+	"log/slog"
+	"time"
+)
+
+// Store gives the relay the unpublished events, see db.Repository.ProcessOutbox
+type Store interface {
+	ProcessOutbox(ctx context.Context, limit int, publish func(ctx context.Context, events []models.OutboxEvent) map[int64]error) (int, error)
+}
+
+// Relay moves events from the outbox table to the broker
+type Relay struct {
+	store     Store
+	publisher Publisher
+	interval  time.Duration
+	batchSize int
+}
+
+func NewRelay(store Store, publisher Publisher, interval time.Duration, batchSize int) *Relay {
+	return &Relay{
+		store:     store,
+		publisher: publisher,
+		interval:  interval,
+		batchSize: batchSize,
+	}
+}
+
+// Run polls the outbox every interval until ctx is done, a full batch is followed by the next one right away
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		relayCtx := logger.WithCorrelationID(ctx, logger.NewCorrelationID())
+		published, err := r.store.ProcessOutbox(relayCtx, r.batchSize, r.publish)
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(relayCtx, "outbox relay failed", "err", err)
+		}
+		if published == r.batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publish sends the events in id order, once an event of an order fails the later events of that order
+// are held back until the next round, so consumers never see them out of order
+func (r *Relay) publish(ctx context.Context, events []models.OutboxEvent) map[int64]error {
+	results := make(map[int64]error, len(events))
+	blocked := make(map[string]bool)
+
+	for _, event := range events {
+		if blocked[event.OrderID] {
+			continue
+		}
+
+		err := r.publisher.Publish(ctx, event)
+		results[event.ID] = err
+		if err != nil {
+			blocked[event.OrderID] = true
+			metrics.OutboxEventsTotal.Inc(metrics.StatusError)
+			slog.WarnContext(ctx, "outbox event not published", "event_id", event.ID, "order_id", event.OrderID, "type", event.Type, "err", err)
+			continue
+		}
+		metrics.OutboxEventsTotal.Inc(metrics.StatusOK)
+		slog.DebugContext(ctx, "outbox event published", "event_id", event.ID, "order_id", event.OrderID, "type", event.Type)
+	}
+	return results
+}
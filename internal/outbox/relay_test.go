@@ -0,0 +1,51 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"homework/internal/models"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// memoryBroker keeps the published events, it fails every event of the orders in fail
+type memoryBroker struct {
+	mu     sync.Mutex
+	events []int64
+	fail   map[string]bool
+}
+
+func (b *memoryBroker) Publish(ctx context.Context, event models.OutboxEvent) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.fail[event.OrderID] {
+		return errors.New("broker unavailable")
+	}
+	b.events = append(b.events, event.ID)
+	return nil
+}
+
+func TestRelayPublishHoldsBackFailedOrders(t *testing.T) {
+	broker := &memoryBroker{fail: map[string]bool{"2": true}}
+	relay := NewRelay(nil, broker, 0, 10)
+
+	results := relay.publish(context.Background(), []models.OutboxEvent{
+		{ID: 1, OrderID: "1"},
+		{ID: 2, OrderID: "2"},
+		{ID: 3, OrderID: "1"},
+		{ID: 4, OrderID: "2"},
+	})
+
+	if !slices.Equal(broker.events, []int64{1, 3}) {
+		t.Errorf("published %v, want the events of order 1", broker.events)
+	}
+	if results[1] != nil || results[3] != nil || results[2] == nil {
+		t.Errorf("results = %v, want 1 and 3 published and 2 failed", results)
+	}
+	// A later event of a failed order isn't tried, it stays in the outbox behind the failed one
+	if _, ok := results[4]; ok {
+		t.Errorf("event 4 has a result %v, want it held back", results[4])
+	}
+}
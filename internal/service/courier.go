@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"homework/internal/auth"
+	"homework/internal/i18n"
+	"homework/internal/models"
+	"homework/internal/storage"
+	"homework/internal/util"
+	"homework/pkg/clock"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// CourierService manages couriers and hands them back the orders the pickup point can't keep
+type CourierService interface {
+	Create(ctx context.Context, id, name, phone string) (models.Courier, error)
+	// Return gives the orders to the courier in one transaction and exports the signed manifest
+	Return(ctx context.Context, courierId string, orders []models.Order) (models.Manifest, error)
+	PrintManifest(manifest models.Manifest)
+}
+
+type courierService struct {
+	repository storage.Storage
+	clock      clock.Clock
+	// manifestDir gets a file per courier return, manifestKey signs it
+	manifestDir string
+	manifestKey []byte
+}
+
+// courierIdPattern keeps a courier id safe as a segment of the manifest file name
+var courierIdPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+func validateCourierId(id string) error {
+	if len(id) == 0 {
+		return util.ErrCourierIdNotProvided
+	}
+	if !courierIdPattern.MatchString(id) {
+		return util.ErrCourierIdInvalid
+	}
+	return nil
+}
+
+func NewCourierService(repository storage.Storage, clock clock.Clock, manifestDir, manifestKey string) CourierService {
+	return &courierService{
+		repository:  repository,
+		clock:       clock,
+		manifestDir: manifestDir,
+		manifestKey: []byte(manifestKey),
+	}
+}
+
+func (s *courierService) Create(ctx context.Context, id, name, phone string) (models.Courier, error) {
+	if err := validateCourierId(id); err != nil {
+		return models.Courier{}, err
+	}
+
+	courier := models.Courier{
+		ID:        id,
+		Name:      name,
+		Phone:     phone,
+		CreatedAt: s.clock.Now(),
+	}
+	if err := s.repository.InsertCourier(ctx, courier); err != nil {
+		return models.Courier{}, err
+	}
+	return courier, nil
+}
+
+func (s *courierService) Return(ctx context.Context, courierId string, orders []models.Order) (models.Manifest, error) {
+	// A manifest signed with an empty key proves nothing, the orders stay until the key is set
+	if len(s.manifestKey) == 0 {
+		return models.Manifest{}, util.ErrManifestKeyNotSet
+	}
+	// The id names the exported file, a courier added before ids were checked is refused before the orders go
+	if err := validateCourierId(courierId); err != nil {
+		return models.Manifest{}, err
+	}
+	manifest := models.Manifest{
+		CourierID: courierId,
+		Operator:  auth.Actor(ctx),
+		// Postgres keeps microseconds, the exported file has to match the stored manifest
+		CreatedAt: s.clock.Now().UTC().Truncate(time.Microsecond),
+		Orders:    orders,
+	}
+	if err := s.repository.ReturnToCourier(ctx, &manifest); err != nil {
+		return models.Manifest{}, err
+	}
+
+	// The orders are gone at this point, a failed export leaves the manifest recorded in the db only
+	signature, err := SignManifest(s.manifestKey, manifest)
+	if err != nil {
+		return manifest, err
+	}
+	manifest.Signature = signature
+	if manifest.File, err = s.export(manifest); err != nil {
+		return manifest, fmt.Errorf("exporting manifest %d: %w", manifest.ID, err)
+	}
+	return manifest, nil
+}
+
+func (s *courierService) export(manifest models.Manifest) (string, error) {
+	if err := os.MkdirAll(s.manifestDir, 0o755); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(s.manifestDir, fmt.Sprintf("manifest-%s-%d.json", manifest.CourierID, manifest.ID))
+	if err = os.WriteFile(path, data, 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func (s *courierService) PrintManifest(manifest models.Manifest) {
+	fmt.Println(i18n.T(i18n.MsgManifestExported, manifest.ID, manifest.CourierID, len(manifest.Orders), manifest.File))
+	printOrders(manifest.Orders)
+}
+
+// SignManifest is the hex HMAC-SHA256 of the manifest JSON without its signature and file
+func SignManifest(key []byte, manifest models.Manifest) (string, error) {
+	manifest.Signature, manifest.File = "", ""
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return "", fmt.Errorf("encoding manifest: %w", err)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// VerifyManifest reads an exported manifest file and checks it was signed with key
+func VerifyManifest(key []byte, data []byte) (models.Manifest, error) {
+	var manifest models.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return models.Manifest{}, fmt.Errorf("decoding manifest: %w", err)
+	}
+	want, err := SignManifest(key, manifest)
+	if err != nil {
+		return models.Manifest{}, err
+	}
+	if !hmac.Equal([]byte(manifest.Signature), []byte(want)) {
+		return models.Manifest{}, errors.New("manifest signature mismatch")
+	}
+	return manifest, nil
+}
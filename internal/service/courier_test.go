@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"homework/internal/models"
+	"homework/internal/storage/mocks"
+	"homework/internal/util"
+	"homework/pkg/clock"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestCourierServiceReturn(t *testing.T) {
+	key := []byte("manifest-key")
+	orders := []models.Order{{ID: "1", CourierID: testCourier}, {ID: "4", CourierID: testCourier, Returned: true}}
+
+	var recorded models.Manifest
+	repository := &mocks.Storage{ReturnToCourierFunc: func(ctx context.Context, manifest *models.Manifest) error {
+		manifest.ID = 3
+		recorded = *manifest
+		return nil
+	}}
+	cs := NewCourierService(repository, clock.NewFake(testNow), t.TempDir(), string(key))
+
+	manifest, err := cs.Return(context.Background(), testCourier, orders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recorded.CourierID != testCourier || !recorded.CreatedAt.Equal(testNow) || !slices.Equal(orderIDs(recorded.Orders), []string{"1", "4"}) {
+		t.Errorf("recorded %+v, want both orders for courier %s at %s", recorded, testCourier, testNow)
+	}
+
+	data, err := os.ReadFile(manifest.File)
+	if err != nil {
+		t.Fatalf("reading the exported manifest: %v", err)
+	}
+	exported, err := VerifyManifest(key, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exported.ID != 3 || !slices.Equal(orderIDs(exported.Orders), []string{"1", "4"}) {
+		t.Errorf("exported %+v, want manifest 3 with both orders", exported)
+	}
+	if _, err = VerifyManifest([]byte("another key"), data); err == nil {
+		t.Error("manifest verified with another key")
+	}
+}
+
+func TestCourierServiceReturnStale(t *testing.T) {
+	repository := &mocks.Storage{ReturnToCourierFunc: func(ctx context.Context, manifest *models.Manifest) error {
+		return util.ErrManifestStale
+	}}
+	dir := t.TempDir()
+	cs := NewCourierService(repository, clock.NewFake(testNow), dir, "key")
+
+	if _, err := cs.Return(context.Background(), testCourier, []models.Order{{ID: "1"}}); !errors.Is(err, util.ErrManifestStale) {
+		t.Fatalf("error = %v, want %v", err, util.ErrManifestStale)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("a stale manifest was exported: %v", files)
+	}
+}
+
+func TestCourierServiceReturnWithoutKey(t *testing.T) {
+	cs := NewCourierService(&mocks.Storage{}, clock.NewFake(testNow), t.TempDir(), "")
+
+	if _, err := cs.Return(context.Background(), testCourier, []models.Order{{ID: "1"}}); !errors.Is(err, util.ErrManifestKeyNotSet) {
+		t.Fatalf("error = %v, want %v", err, util.ErrManifestKeyNotSet)
+	}
+}
+
+func TestCourierServiceCreate(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      string
+		wantErr error
+	}{
+		{name: "created", id: "dpd-7_north"},
+		{name: "no id", id: "", wantErr: util.ErrCourierIdNotProvided},
+		{name: "path in the id", id: "../../x", wantErr: util.ErrCourierIdInvalid},
+		{name: "separator in the id", id: "a/b", wantErr: util.ErrCourierIdInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &mocks.Storage{InsertCourierFunc: func(ctx context.Context, courier models.Courier) error {
+				return nil
+			}}
+			cs := NewCourierService(repository, clock.NewFake(testNow), t.TempDir(), "key")
+
+			if _, err := cs.Create(context.Background(), tt.id, "Ivan", "+7900"); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package service
+
+import (
+	"homework/internal/models"
+	"homework/pkg/clock"
+	"math"
+)
+
+// storageFees charges for every started day an order stays in the pickup point past its storage date
+type storageFees struct {
+	perDay models.Price
+	clock  clock.Clock
+}
+
+// fee is what the order owes by now, an issued order stopped owing when it left
+func (f storageFees) fee(order models.Order) models.Price {
+	end := f.clock.Now()
+	if order.Issued {
+		end = order.IssuedAt
+	}
+	overdue := end.Sub(order.StorageUntil)
+	if overdue <= 0 {
+		return 0
+	}
+	return f.perDay * models.Price(math.Ceil(overdue.Hours()/24))
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"homework/internal/auth"
+	"homework/internal/i18n"
+	"homework/internal/models"
+	"homework/internal/storage"
+	"homework/pkg/clock"
+	"strings"
+	"time"
+)
+
+// HoldService reserves orders for an operator while they serve the customer, the holds expire by themselves
+type HoldService interface {
+	Hold(ctx context.Context, ids []string) ([]models.Hold, error)
+	// Release drops the holds of the operator in ctx, a hold of someone else stays until it expires
+	Release(ctx context.Context, ids []string) error
+	List(ctx context.Context) ([]models.Hold, error)
+	PrintList(holds []models.Hold)
+}
+
+type holdService struct {
+	repository storage.Storage
+	clock      clock.Clock
+	ttl        time.Duration
+}
+
+// NewHoldService places holds that last ttl on clock
+func NewHoldService(repository storage.Storage, clock clock.Clock, ttl time.Duration) HoldService {
+	return &holdService{
+		repository: repository,
+		clock:      clock,
+		ttl:        ttl,
+	}
+}
+
+func (s *holdService) Hold(ctx context.Context, ids []string) ([]models.Hold, error) {
+	now := s.clock.Now()
+	holds := make([]models.Hold, 0, len(ids))
+	for _, id := range ids {
+		holds = append(holds, models.Hold{
+			OrderID:   id,
+			Operator:  auth.Actor(ctx),
+			CreatedAt: now,
+			ExpiresAt: now.Add(s.ttl),
+		})
+	}
+	if err := s.repository.PlaceHolds(ctx, holds); err != nil {
+		return nil, err
+	}
+	return holds, nil
+}
+
+func (s *holdService) Release(ctx context.Context, ids []string) error {
+	return s.repository.ReleaseHolds(ctx, ids, auth.Actor(ctx))
+}
+
+func (s *holdService) List(ctx context.Context) ([]models.Hold, error) {
+	return s.repository.GetHolds(ctx, s.clock.Now())
+}
+
+func (s *holdService) PrintList(holds []models.Hold) {
+	now := s.clock.Now()
+	fmt.Printf("%-10s%-15s%-22s%-10s\n", i18n.T(i18n.ColumnOrderID), i18n.T(i18n.ColumnOperator), i18n.T(i18n.ColumnExpiresAt), i18n.T(i18n.ColumnLeft))
+	fmt.Println(strings.Repeat("-", 57))
+	for _, hold := range holds {
+		fmt.Printf("%-10s%-15s%-22s%-10s\n",
+			hold.OrderID,
+			hold.Operator,
+			hold.ExpiresAt.Format(time.DateTime),
+			hold.ExpiresAt.Sub(now).Round(time.Second))
+	}
+	fmt.Printf("\n")
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"homework/internal/auth"
+	"homework/internal/models"
+	"homework/internal/storage/mocks"
+	"homework/internal/util"
+	"homework/pkg/clock"
+	"testing"
+	"time"
+)
+
+func TestHoldServiceHold(t *testing.T) {
+	var placed []models.Hold
+	repository := &mocks.Storage{PlaceHoldsFunc: func(ctx context.Context, holds []models.Hold) error {
+		placed = holds
+		if holds[0].OrderID == "9" {
+			return util.ErrOrderHeld
+		}
+		return nil
+	}}
+	hs := NewHoldService(repository, clock.NewFake(testNow), 10*time.Minute)
+	ctx := auth.WithOperator(context.Background(), models.Operator{Login: "anna", Role: models.RoleClerk})
+
+	holds, err := hs.Hold(ctx, []string{"1", "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holds) != 2 || len(placed) != 2 {
+		t.Fatalf("held %v, placed %v, want both orders", holds, placed)
+	}
+	for _, hold := range placed {
+		if hold.Operator != "anna" || !hold.CreatedAt.Equal(testNow) || !hold.ExpiresAt.Equal(testNow.Add(10*time.Minute)) {
+			t.Errorf("hold %+v, want anna's for 10m from %s", hold, testNow)
+		}
+	}
+
+	if _, err = hs.Hold(ctx, []string{"9"}); !errors.Is(err, util.ErrOrderHeld) {
+		t.Errorf("error = %v, want %v", err, util.ErrOrderHeld)
+	}
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"homework/internal/auth"
+	"homework/internal/models"
+	"homework/internal/storage"
+	"homework/internal/util"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// IdempotencyService remembers the outcome of mutating commands by the key the client sent with them,
+// keys are scoped by operator
+type IdempotencyService interface {
+	// Begin claims the key for the command, a key that was already used returns its record and false
+	Begin(ctx context.Context, key, command, fingerprint string) (models.IdempotencyRecord, bool, error)
+	// Complete stores the outcome, a transient error releases the key so the command can be retried with it
+	Complete(ctx context.Context, record models.IdempotencyRecord, response any, err error)
+	// Run removes expired keys every hour until ctx is done
+	Run(ctx context.Context)
+}
+
+type idempotencyService struct {
+	repository storage.Storage
+	ttl        time.Duration
+	// lease is how long a pending key stays claimed, after it the command is taken for lost with its process
+	lease time.Duration
+}
+
+func NewIdempotencyService(repository storage.Storage, ttl, lease time.Duration) IdempotencyService {
+	return &idempotencyService{
+		repository: repository,
+		ttl:        ttl,
+		lease:      lease,
+	}
+}
+
+func (s *idempotencyService) Begin(ctx context.Context, key, command, fingerprint string) (models.IdempotencyRecord, bool, error) {
+	record, claimed, err := s.repository.ClaimIdempotencyKey(ctx, models.IdempotencyRecord{
+		Operator:    auth.Actor(ctx),
+		Key:         key,
+		Command:     command,
+		Fingerprint: fingerprint,
+		ExpiresAt:   time.Now().Add(s.ttl),
+	}, s.lease)
+	if err != nil || claimed {
+		return record, claimed, err
+	}
+
+	if record.Command != command || record.Fingerprint != fingerprint {
+		return record, false, util.ErrIdempotencyKeyReused
+	}
+	if record.Status == models.IdempotencyPending {
+		return record, false, util.ErrIdempotencyInProgress
+	}
+	return record, false, nil
+}
+
+func (s *idempotencyService) Complete(ctx context.Context, record models.IdempotencyRecord, response any, err error) {
+	// The command has run, its outcome must be kept even if the command context is gone
+	ctx = context.WithoutCancel(ctx)
+
+	if err != nil {
+		e := util.AsError(err)
+		switch e.Code {
+		case util.CodeUnavailable, util.CodeInternal:
+			released, err := s.repository.ReleaseIdempotencyKey(ctx, record)
+			if err != nil {
+				slog.ErrorContext(ctx, "idempotency key not released", "key", record.Key, "err", err)
+			} else if !released {
+				slog.WarnContext(ctx, "idempotency claim lost, the key was taken over after its lease", "key", record.Key)
+			}
+			return
+		}
+		record.ErrorCode, record.ErrorField, record.ErrorMessage = string(e.Code), e.Field, e.Message
+	} else {
+		data, err := json.Marshal(response)
+		if err != nil {
+			slog.ErrorContext(ctx, "idempotent response not encoded", "key", record.Key, "err", err)
+			return
+		}
+		record.Response = data
+	}
+
+	completed, err := s.repository.CompleteIdempotencyKey(ctx, record)
+	if err != nil {
+		slog.ErrorContext(ctx, "idempotent outcome not saved", "key", record.Key, "err", err)
+	} else if !completed {
+		// Another request holds the key now, its outcome is the one kept
+		slog.WarnContext(ctx, "idempotency claim lost, the key was taken over after its lease", "key", record.Key)
+	}
+}
+
+func (s *idempotencyService) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := s.repository.DeleteExpiredIdempotencyKeys(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "expired idempotency keys not deleted", "err", err)
+		} else if deleted > 0 {
+			slog.InfoContext(ctx, "expired idempotency keys deleted", "count", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Idempotent runs fn once per key: a repeated key with the same request returns the stored outcome
+// and reports it as replayed, an empty key just runs fn
+func Idempotent[T any](ctx context.Context, s IdempotencyService, key, command, fingerprint string, fn func(ctx context.Context) (T, error)) (T, bool, error) {
+	var zero T
+	if len(key) == 0 {
+		result, err := fn(ctx)
+		return result, false, err
+	}
+
+	record, claimed, err := s.Begin(ctx, key, command, fingerprint)
+	if err != nil {
+		return zero, false, err
+	}
+	if !claimed {
+		slog.InfoContext(ctx, "idempotent replay", "key", key, "command", command)
+		if len(record.ErrorCode) > 0 {
+			return zero, true, util.Restore(util.Code(record.ErrorCode), record.ErrorField, record.ErrorMessage)
+		}
+		var result T
+		if err = json.Unmarshal(record.Response, &result); err != nil {
+			return zero, true, util.ErrInternal.Wrap(err)
+		}
+		return result, true, nil
+	}
+
+	result, err := fn(ctx)
+	s.Complete(ctx, record, result, err)
+	return result, false, err
+}
+
+// Fingerprint identifies the request sent with an idempotency key, so the key can't be reused for another one
+func Fingerprint(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"homework/internal/auth"
+	"homework/internal/models"
+	"homework/internal/storage/mocks"
+	"homework/internal/util"
+	"testing"
+	"time"
+)
+
+func TestIdempotentCompletesItsOwnClaim(t *testing.T) {
+	claimedAt := testNow.Add(-time.Minute)
+	var completed, released []models.IdempotencyRecord
+	repository := &mocks.Storage{
+		ClaimIdempotencyKeyFunc: func(ctx context.Context, record models.IdempotencyRecord, lease time.Duration) (models.IdempotencyRecord, bool, error) {
+			if lease != time.Minute {
+				t.Errorf("lease = %s, want 1m", lease)
+			}
+			record.Status, record.CreatedAt = models.IdempotencyPending, claimedAt
+			return record, true, nil
+		},
+		// The claim was taken over while the command ran, the late outcome is dropped
+		CompleteIdempotencyKeyFunc: func(ctx context.Context, record models.IdempotencyRecord) (bool, error) {
+			completed = append(completed, record)
+			return false, nil
+		},
+		ReleaseIdempotencyKeyFunc: func(ctx context.Context, record models.IdempotencyRecord) (bool, error) {
+			released = append(released, record)
+			return true, nil
+		},
+	}
+	is := NewIdempotencyService(repository, time.Hour, time.Minute)
+	ctx := auth.WithOperator(context.Background(), models.Operator{Login: "anna", Role: models.RoleClerk})
+
+	result, replayed, err := Idempotent(ctx, is, "key-1", "accept", "fingerprint", func(ctx context.Context) (string, error) {
+		return "done", nil
+	})
+	if err != nil || replayed || result != "done" {
+		t.Fatalf("Idempotent = %q, %v, %v, want the result of the run", result, replayed, err)
+	}
+	if len(completed) != 1 || !completed[0].CreatedAt.Equal(claimedAt) || completed[0].Operator != "anna" {
+		t.Errorf("completed %+v, want the claim taken at %s", completed, claimedAt)
+	}
+
+	// A transient failure gives the key back, fenced by the same claim
+	_, _, err = Idempotent(ctx, is, "key-2", "accept", "fingerprint", func(ctx context.Context) (string, error) {
+		return "", util.ErrStorageUnavailable
+	})
+	if !errors.Is(err, util.ErrStorageUnavailable) {
+		t.Fatalf("error = %v, want %v", err, util.ErrStorageUnavailable)
+	}
+	if len(released) != 1 || released[0].Key != "key-2" || !released[0].CreatedAt.Equal(claimedAt) {
+		t.Errorf("released %+v, want the claim of key-2", released)
+	}
+}
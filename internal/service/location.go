@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"homework/internal/i18n"
+	"homework/internal/models"
+	"homework/internal/storage"
+	"strings"
+)
+
+// LocationService shows where accepted orders are kept,
+// cells are assigned by the repository on Insert and released on issue or courier return
+type LocationService interface {
+	ListCells(ctx context.Context) ([]models.Cell, error)
+	PrintCells(cells []models.Cell)
+}
+
+type locationService struct {
+	repository storage.Storage
+}
+
+func NewLocationService(repository storage.Storage) LocationService {
+	return &locationService{
+		repository: repository,
+	}
+}
+
+func (ls *locationService) ListCells(ctx context.Context) ([]models.Cell, error) {
+	return ls.repository.GetCells(ctx)
+}
+
+func (ls *locationService) PrintCells(cells []models.Cell) {
+	var usedCells int
+	fmt.Printf("%-7s%-7s%-14s%-16s%-10s\n", i18n.T(i18n.ColumnCell), i18n.T(i18n.ColumnShelf), i18n.T(i18n.ColumnPackageType), i18n.T(i18n.ColumnWeight), i18n.T(i18n.ColumnOrders))
+	fmt.Println(strings.Repeat("-", 54))
+	for _, cell := range cells {
+		if cell.OrdersCount > 0 {
+			usedCells++
+		}
+		maxWeight := "-"
+		if cell.MaxWeight > 0 {
+			maxWeight = fmt.Sprint(cell.MaxWeight)
+		}
+		fmt.Printf("%-7s%-7s%-14s%-16s%-10s\n",
+			cell.ID,
+			cell.Shelf,
+			cell.PackageType,
+			fmt.Sprintf("%v/%s", cell.UsedWeight, maxWeight),
+			fmt.Sprintf("%d/%d", cell.OrdersCount, cell.MaxOrders))
+	}
+	fmt.Printf("\n%s\n\n", i18n.T(i18n.MsgOccupiedCells, usedCells, len(cells)))
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"homework/internal/i18n"
+	"homework/internal/logger"
+	"homework/internal/metrics"
+	"homework/internal/models"
+	"homework/internal/notification"
+	"homework/internal/storage"
+	"homework/pkg/clock"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// maxNotificationAttempts bounds the resends of a failed notification by the sweeper
+const maxNotificationAttempts = 5
+
+// notificationLease is how long a send may take, a notification pending for longer lost the process sending it
+// and the sweeper sends it again
+const notificationLease = 5 * time.Minute
+
+// NotificationService tells customers about their orders, every event is sent at most once per order and channel
+type NotificationService interface {
+	// Notify sends the event through every channel, a failure is recorded and retried by the sweeper, never returned
+	Notify(ctx context.Context, event models.NotificationEvent, order models.Order)
+	// Sweep announces the orders whose storage ends within the expiry window and resends failed notifications
+	Sweep(ctx context.Context) error
+	// Run sweeps every interval until ctx is done
+	Run(ctx context.Context)
+	List(ctx context.Context, orderID string) ([]models.Notification, error)
+	PrintList(notifications []models.Notification)
+}
+
+type notificationService struct {
+	repository    storage.Storage
+	notifiers     []notification.Notifier
+	clock         clock.Clock
+	expiryWindow  time.Duration
+	sweepInterval time.Duration
+}
+
+// NewNotificationService decides which orders are about to expire by the time of clock
+func NewNotificationService(repository storage.Storage, clock clock.Clock, expiryWindow, sweepInterval time.Duration, notifiers ...notification.Notifier) NotificationService {
+	return &notificationService{
+		repository:    repository,
+		notifiers:     notifiers,
+		clock:         clock,
+		expiryWindow:  expiryWindow,
+		sweepInterval: sweepInterval,
+	}
+}
+
+func (ns *notificationService) Notify(ctx context.Context, event models.NotificationEvent, order models.Order) {
+	if len(ns.notifiers) == 0 {
+		return
+	}
+
+	message, err := notification.Render(event, order)
+	if err != nil {
+		slog.ErrorContext(ctx, "notification not rendered", "order_id", order.ID, "event", event, "err", err)
+		return
+	}
+
+	for _, notifier := range ns.notifiers {
+		ns.deliver(ctx, notifier, models.Notification{
+			OrderID: order.ID,
+			UserID:  order.UserID,
+			Event:   event,
+			Channel: notifier.Channel(),
+			Message: message,
+		})
+	}
+}
+
+// deliver claims the notification, so a duplicate event is dropped, sends it and records the outcome
+func (ns *notificationService) deliver(ctx context.Context, notifier notification.Notifier, n models.Notification) {
+	claimed, err := ns.repository.ClaimNotification(ctx, n, notificationLease)
+	if err != nil {
+		slog.ErrorContext(ctx, "notification not claimed", "order_id", n.OrderID, "event", n.Event, "channel", n.Channel, "err", err)
+		return
+	}
+	if !claimed {
+		slog.DebugContext(ctx, "duplicate notification dropped", "order_id", n.OrderID, "event", n.Event, "channel", n.Channel)
+		return
+	}
+
+	if err = notifier.Send(ctx, n); err != nil {
+		n.Status = models.NotificationFailed
+		n.Error = err.Error()
+		slog.WarnContext(ctx, "notification failed", "order_id", n.OrderID, "event", n.Event, "channel", n.Channel, "err", err)
+	} else {
+		sentAt := ns.clock.Now()
+		n.Status = models.NotificationSent
+		n.SentAt = &sentAt
+		slog.InfoContext(ctx, "notification sent", "order_id", n.OrderID, "event", n.Event, "channel", n.Channel)
+	}
+	metrics.NotificationsTotal.Inc(string(n.Event), string(n.Status))
+
+	// The send has happened, record it even if the command is being canceled
+	if err = ns.repository.UpdateNotification(context.WithoutCancel(ctx), n); err != nil {
+		slog.ErrorContext(ctx, "notification status not saved", "order_id", n.OrderID, "event", n.Event, "channel", n.Channel, "err", err)
+	}
+}
+
+func (ns *notificationService) Sweep(ctx context.Context) error {
+	now := ns.clock.Now()
+	expiring, err := ns.repository.GetExpiring(ctx, now, now.Add(ns.expiryWindow))
+	if err != nil {
+		return err
+	}
+	for _, order := range expiring {
+		ns.Notify(ctx, models.EventExpiring, order)
+	}
+
+	failed, err := ns.repository.GetFailedNotifications(ctx, maxNotificationAttempts, notificationLease, 100)
+	if err != nil {
+		return err
+	}
+	for _, n := range failed {
+		if notifier, ok := ns.notifier(n.Channel); ok {
+			ns.deliver(ctx, notifier, n)
+		}
+	}
+	return nil
+}
+
+func (ns *notificationService) notifier(channel string) (notification.Notifier, bool) {
+	for _, notifier := range ns.notifiers {
+		if notifier.Channel() == channel {
+			return notifier, true
+		}
+	}
+	return nil, false
+}
+
+func (ns *notificationService) Run(ctx context.Context) {
+	if len(ns.notifiers) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(ns.sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		sweepCtx := logger.WithCorrelationID(ctx, logger.NewCorrelationID())
+		if err := ns.Sweep(sweepCtx); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(sweepCtx, "notification sweep failed", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (ns *notificationService) List(ctx context.Context, orderID string) ([]models.Notification, error) {
+	return ns.repository.GetNotifications(ctx, orderID)
+}
+
+func (ns *notificationService) PrintList(notifications []models.Notification) {
+	fmt.Printf("%-10s%-9s%-9s%-9s%-21s%s\n", i18n.T(i18n.ColumnEvent), i18n.T(i18n.ColumnChannel), i18n.T(i18n.ColumnStatus), i18n.T(i18n.ColumnAttempts), i18n.T(i18n.ColumnSentAt), i18n.T(i18n.ColumnMessage))
+	fmt.Println(strings.Repeat("-", 100))
+	for _, n := range notifications {
+		sentAt := "-"
+		if n.SentAt != nil {
+			sentAt = n.SentAt.Format(time.DateTime)
+		}
+		message := n.Message
+		if n.Status == models.NotificationFailed {
+			message = n.Error
+		}
+		fmt.Printf("%-10s%-9s%-9s%-9d%-21s%s\n", n.Event, n.Channel, n.Status, n.Attempts, sentAt, message)
+	}
+	fmt.Printf("\n")
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"homework/internal/auth"
+	"homework/internal/i18n"
+	"homework/internal/models"
+	"homework/internal/storage"
+	"homework/internal/util"
+)
+
+// OperatorService manages operator accounts, CLI sessions log in with a password and HTTP clients with an API key
+type OperatorService interface {
+	Login(ctx context.Context, login, password string) (models.Operator, error)
+	Authenticate(ctx context.Context, apiKey string) (models.Operator, error)
+	Create(ctx context.Context, login, password string, role models.Role) (string, error)
+	Bootstrap(ctx context.Context, adminPassword string) error
+}
+
+type operatorService struct {
+	repository storage.Storage
+}
+
+func NewOperatorService(repository storage.Storage) OperatorService {
+	return &operatorService{
+		repository: repository,
+	}
+}
+
+func (s *operatorService) Login(ctx context.Context, login, password string) (models.Operator, error) {
+	if len(login) == 0 {
+		return models.Operator{}, util.ErrLoginNotProvided
+	}
+
+	operator, err := s.repository.GetOperator(ctx, login)
+	if err != nil {
+		if errors.Is(err, util.ErrOperatorNotFound) {
+			return models.Operator{}, util.ErrInvalidCredentials
+		}
+		return models.Operator{}, err
+	}
+	if !auth.CheckPassword(operator.PasswordHash, password) {
+		return models.Operator{}, util.ErrInvalidCredentials
+	}
+
+	return operator, nil
+}
+
+func (s *operatorService) Authenticate(ctx context.Context, apiKey string) (models.Operator, error) {
+	if len(apiKey) == 0 {
+		return models.Operator{}, util.ErrNotLoggedIn
+	}
+
+	operator, err := s.repository.GetOperatorByKey(ctx, auth.HashAPIKey(apiKey))
+	if err != nil {
+		if errors.Is(err, util.ErrOperatorNotFound) {
+			return models.Operator{}, util.ErrInvalidCredentials
+		}
+		return models.Operator{}, err
+	}
+
+	return operator, nil
+}
+
+// Create adds an operator and returns the API key, only its hash is stored so it is shown once
+func (s *operatorService) Create(ctx context.Context, login, password string, role models.Role) (string, error) {
+	if len(login) == 0 {
+		return "", util.ErrLoginNotProvided
+	}
+	if len(password) == 0 {
+		return "", util.ErrInvalidCredentials
+	}
+	if role.Level() == 0 {
+		return "", util.ErrRoleInvalid
+	}
+
+	passwordHash, err := auth.HashPassword(password)
+	if err != nil {
+		return "", err
+	}
+	apiKey, apiKeyHash, err := auth.NewAPIKey()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.repository.InsertOperator(ctx, models.Operator{
+		Login:        login,
+		Role:         role,
+		PasswordHash: passwordHash,
+		APIKeyHash:   apiKeyHash,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return apiKey, nil
+}
+
+// Bootstrap creates the admin account on a fresh database so there is someone to create the other operators.
+// There is no default password, ADMIN_PASSWORD has to be set and can't be the login itself
+func (s *operatorService) Bootstrap(ctx context.Context, adminPassword string) error {
+	_, err := s.repository.GetOperator(ctx, string(models.RoleAdmin))
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, util.ErrOperatorNotFound) {
+		return err
+	}
+	if len(adminPassword) == 0 {
+		return errors.New("ADMIN_PASSWORD is required to create the admin operator")
+	}
+	if adminPassword == string(models.RoleAdmin) {
+		return errors.New("ADMIN_PASSWORD can't be the admin login, pick another password")
+	}
+
+	apiKey, err := s.Create(ctx, string(models.RoleAdmin), adminPassword, models.RoleAdmin)
+	if err != nil && !errors.Is(err, util.ErrOperatorExists) {
+		return err
+	}
+	if len(apiKey) > 0 {
+		// The only chance to see the key, it is not stored in plain text
+		fmt.Println(i18n.T(i18n.MsgAdminCreated, apiKey))
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"homework/internal/models"
+	"homework/internal/storage/mocks"
+	"homework/internal/util"
+	"testing"
+)
+
+func TestOperatorServiceBootstrap(t *testing.T) {
+	tests := []struct {
+		name        string
+		exists      bool
+		password    string
+		wantErr     bool
+		wantCreated bool
+	}{
+		{name: "admin exists", exists: true},
+		{name: "created", password: "s3cret-pass", wantCreated: true},
+		{name: "no password", password: "", wantErr: true},
+		{name: "password is the login", password: "admin", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var created []models.Operator
+			repository := &mocks.Storage{
+				GetOperatorFunc: func(ctx context.Context, login string) (models.Operator, error) {
+					if tt.exists {
+						return models.Operator{Login: login, Role: models.RoleAdmin}, nil
+					}
+					return models.Operator{}, util.ErrOperatorNotFound
+				},
+				InsertOperatorFunc: func(ctx context.Context, operator models.Operator) error {
+					created = append(created, operator)
+					return nil
+				},
+			}
+
+			err := NewOperatorService(repository).Bootstrap(context.Background(), tt.password)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantCreated != (len(created) == 1) {
+				t.Fatalf("created %+v, want created %v", created, tt.wantCreated)
+			}
+			if tt.wantCreated && (created[0].Login != "admin" || created[0].Role != models.RoleAdmin) {
+				t.Errorf("created %+v, want the admin", created[0])
+			}
+		})
+	}
+}
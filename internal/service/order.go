@@ -1,101 +1,162 @@
 package service
 
 import (
+	"context"
 	"fmt"
+	"homework/internal/auth"
+	"homework/internal/i18n"
+	"homework/internal/metrics"
 	"homework/internal/models"
 	pkg "homework/internal/service/package"
 	"homework/internal/storage"
+	"homework/pkg/clock"
 	"homework/pkg/hash"
+	"io"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
 type OrderService interface {
-	Accept(order *models.Order, pkgTypeStr string) error
-	Issue(ordersToIssue *[]models.Order) error
-	Return(orders *models.Order) error
-	ReturnToCourier(id string) error
-	ListReturns(offset, limit int) ([]models.Order, error)
-	ListOrders(userId string, offset, limit int) ([]models.Order, error)
+	// Accept writes the progress of the hash to progress, callers without a terminal pass io.Discard
+	Accept(ctx context.Context, order *models.Order, pkgTypeStr string, progress io.Writer) error
+	// Issue gives out the orders and records their payment, the payment gets its id and time
+	Issue(ctx context.Context, ordersToIssue *[]models.Order, payment *models.Payment) error
+	// Return takes the order back and records its refund, the refund gets its id and time
+	Return(ctx context.Context, order *models.Order, refund *models.Refund) error
+	ReturnToCourier(ctx context.Context, id string) error
+	ListReturns(ctx context.Context, offset, limit int) ([]models.Order, error)
+	ListOrders(ctx context.Context, userId string, offset, limit int) ([]models.Order, error)
+	Search(ctx context.Context, filter models.OrderFilter) ([]models.Order, error)
 	PrintList(orders []models.Order)
+	// HashBacklog is the number of hashes being generated right now
+	HashBacklog() int
 }
 
 type orderService struct {
 	repository     storage.Storage
 	packageService pkg.PackageService
+	notifications  NotificationService
+	clock          clock.Clock
+	hashBacklog    atomic.Int64
+	// generateHash takes seconds, tests replace it
+	generateHash func() string
 }
 
-func NewOrderService(repository storage.Storage, packageService pkg.PackageService) OrderService {
+// NewOrderService stamps issued orders with the time of clock
+func NewOrderService(repository storage.Storage, packageService pkg.PackageService, notifications NotificationService, clock clock.Clock) OrderService {
 	return &orderService{
 		repository:     repository,
 		packageService: packageService,
+		notifications:  notifications,
+		clock:          clock,
+		generateHash:   hash.GenerateHash,
 	}
 }
 
-func (os *orderService) Accept(order *models.Order, pkgTypeStr string) error {
+func (os *orderService) Accept(ctx context.Context, order *models.Order, pkgTypeStr string, progress io.Writer) error {
 	os.packageService.ApplyPackage(order, models.PackageType(pkgTypeStr))
 
-	fmt.Print("Calculating hash.")
+	fmt.Fprint(progress, i18n.T(i18n.MsgCalculatingHash))
 
 	ticker := time.NewTicker(time.Second)
-	done := make(chan struct{})
+	defer ticker.Stop()
+
+	// Buffered, so the generator can finish even if the command was canceled
+	hashChannel := make(chan string, 1)
+	os.hashBacklog.Add(1)
 	go func() {
-		for {
-			select {
-			case <-done:
-				return
-			case <-ticker.C:
-				fmt.Print(" .")
-			}
-		}
+		defer os.hashBacklog.Add(-1)
+		start := time.Now()
+		hashChannel <- os.generateHash()
+		metrics.HashDuration.Observe(time.Since(start).Seconds())
 	}()
 
-	go func(order *models.Order, ticker *time.Ticker, done chan struct{}) {
-		order.Hash = hash.GenerateHash()
-		ticker.Stop()
-		done <- struct{}{}
-	}(order, ticker, done)
-
-	<-done
-
-	return os.repository.Insert(*order)
+	for {
+		select {
+		case order.Hash = <-hashChannel:
+			fmt.Fprintln(progress)
+			if err := os.repository.Insert(ctx, order); err != nil {
+				return err
+			}
+			os.notifications.Notify(ctx, models.EventAccepted, *order)
+			return nil
+		case <-ticker.C:
+			fmt.Fprint(progress, " .")
+		case <-ctx.Done():
+			fmt.Fprintln(progress)
+			return ctx.Err()
+		}
+	}
 }
 
-func (os *orderService) Issue(orders *[]models.Order) error {
-	for _, order := range *orders {
-		order.Issued = true
-		order.IssuedAt = time.Now()
+func (os *orderService) Issue(ctx context.Context, orders *[]models.Order, payment *models.Payment) error {
+	now := os.clock.Now()
+	for i := range *orders {
+		(*orders)[i].Issued = true
+		(*orders)[i].IssuedAt = now
 	}
+	payment.Operator = auth.Actor(ctx)
+	payment.CreatedAt = now
 
-	return os.repository.IssueUpdate(*orders)
+	if err := os.repository.IssueUpdate(ctx, *orders, payment); err != nil {
+		return err
+	}
+	for _, order := range *orders {
+		os.notifications.Notify(ctx, models.EventIssued, order)
+	}
+	return nil
 }
 
-func (os *orderService) Return(order *models.Order) error {
+func (os *orderService) Return(ctx context.Context, order *models.Order, refund *models.Refund) error {
 	order.Returned = true
+	refund.Operator = auth.Actor(ctx)
+	refund.CreatedAt = os.clock.Now()
+
+	if err := os.repository.Update(ctx, *order, refund); err != nil {
+		return err
+	}
+	os.notifications.Notify(ctx, models.EventReturned, *order)
+	return nil
+}
 
-	return os.repository.Update(*order)
+func (os *orderService) ReturnToCourier(ctx context.Context, id string) error {
+	return os.repository.Delete(ctx, id, os.clock.Now())
 }
 
-func (os *orderService) ReturnToCourier(id string) error {
-	return os.repository.Delete(id)
+func (os *orderService) ListReturns(ctx context.Context, offset, limit int) ([]models.Order, error) {
+	return os.repository.GetReturns(ctx, offset, limit)
 }
 
-func (os *orderService) ListReturns(offset, limit int) ([]models.Order, error) {
-	return os.repository.GetReturns(offset, limit)
+func (os *orderService) ListOrders(ctx context.Context, userId string, offset, limit int) ([]models.Order, error) {
+	return os.repository.GetOrders(ctx, userId, offset, limit)
 }
 
-func (os *orderService) ListOrders(userId string, offset, limit int) ([]models.Order, error) {
-	return os.repository.GetOrders(userId, offset, limit)
+func (os *orderService) Search(ctx context.Context, filter models.OrderFilter) ([]models.Order, error) {
+	return os.repository.Find(ctx, filter)
+}
+
+func (os *orderService) HashBacklog() int {
+	return int(os.hashBacklog.Load())
 }
 
 func (os *orderService) PrintList(orders []models.Order) {
+	printOrders(orders)
+}
+
+// printOrders renders orders as the table every order listing shows
+func printOrders(orders []models.Order) {
 	if len(orders) == 0 {
 		defer fmt.Printf("\n\n")
 	}
-	fmt.Printf("%-5s%-10s%-15s%-15v%-10v%-13v%-10v%-13s%-13v\n", "id", "user_id", "storage_until", "issued_at", "returned", "order_price", "weight", "package_type", "package_price")
-	fmt.Println(strings.Repeat("-", 100))
+	fmt.Printf("%-5s%-10s%-15s%-15v%-10v%-13v%-10v%-13s%-15v%-6s\n", i18n.T(i18n.ColumnID), i18n.T(i18n.ColumnUserID), i18n.T(i18n.ColumnStorageUntil), i18n.T(i18n.ColumnIssuedAt), i18n.T(i18n.ColumnReturned), i18n.T(i18n.ColumnOrderPrice), i18n.T(i18n.ColumnWeight), i18n.T(i18n.ColumnPackageType), i18n.T(i18n.ColumnPackagePrice), i18n.T(i18n.ColumnCell))
+	fmt.Println(strings.Repeat("-", 108))
 	for _, order := range orders {
-		fmt.Printf("%-5s%-10s%-15s%-15v%-10v%-13v%-10v%-13s%-13v\n",
+		cell := order.CellID
+		if len(cell) == 0 {
+			cell = "-"
+		}
+		fmt.Printf("%-5s%-10s%-15s%-15v%-10v%-13v%-10v%-13s%-15v%-6s\n",
 			order.ID,
 			order.UserID,
 			order.StorageUntil.Format("2006-01-02"),
@@ -104,7 +165,8 @@ func (os *orderService) PrintList(orders []models.Order) {
 			order.OrderPrice,
 			order.Weight,
 			order.PackageType,
-			order.PackagePrice)
+			order.PackagePrice,
+			cell)
 	}
 	fmt.Printf("\n")
 }
@@ -0,0 +1,342 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"homework/internal/auth"
+	"homework/internal/models"
+	pkg "homework/internal/service/package"
+	"homework/internal/storage/mocks"
+	"homework/internal/util"
+	"homework/pkg/clock"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type notified struct {
+	event   models.NotificationEvent
+	orderID string
+}
+
+// notificationRecorder keeps what Notify was asked to send
+type notificationRecorder struct {
+	mu     sync.Mutex
+	events []notified
+}
+
+func (n *notificationRecorder) Notify(ctx context.Context, event models.NotificationEvent, order models.Order) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.events = append(n.events, notified{event, order.ID})
+}
+
+func (n *notificationRecorder) sent() []notified {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return slices.Clone(n.events)
+}
+
+func (n *notificationRecorder) Sweep(ctx context.Context) error { return nil }
+
+func (n *notificationRecorder) Run(ctx context.Context) {}
+
+func (n *notificationRecorder) List(ctx context.Context, orderID string) ([]models.Notification, error) {
+	return nil, nil
+}
+
+func (n *notificationRecorder) PrintList(notifications []models.Notification) {}
+
+func newOrderService(repository *mocks.Storage) (*orderService, *notificationRecorder) {
+	notifications := &notificationRecorder{}
+	os := NewOrderService(repository, pkg.NewPackageService(), notifications, clock.NewFake(testNow)).(*orderService)
+	os.generateHash = func() string { return "hash" }
+	return os, notifications
+}
+
+func TestOrderServiceAccept(t *testing.T) {
+	tests := []struct {
+		name         string
+		pkgType      string
+		insertErr    error
+		wantErr      error
+		wantType     models.PackageType
+		wantPrice    models.Price
+		wantNotified []notified
+	}{
+		{
+			name:         "box",
+			pkgType:      "box",
+			wantType:     pkg.BoxType,
+			wantPrice:    100 + pkg.BoxPrice,
+			wantNotified: []notified{{models.EventAccepted, "1"}},
+		},
+		{
+			name:         "packet",
+			pkgType:      "packet",
+			wantType:     pkg.PacketType,
+			wantPrice:    100 + pkg.PacketPrice,
+			wantNotified: []notified{{models.EventAccepted, "1"}},
+		},
+		{
+			name:         "unknown package is wrapped in film",
+			pkgType:      "",
+			wantType:     pkg.FilmType,
+			wantPrice:    100 + pkg.FilmPrice,
+			wantNotified: []notified{{models.EventAccepted, "1"}},
+		},
+		{
+			name:      "insert fails",
+			pkgType:   "box",
+			insertErr: util.ErrNoFreeCell,
+			wantErr:   util.ErrNoFreeCell,
+			wantType:  pkg.BoxType,
+			wantPrice: 100 + pkg.BoxPrice,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var inserted models.Order
+			repository := &mocks.Storage{InsertFunc: func(ctx context.Context, order *models.Order) error {
+				inserted = *order
+				return tt.insertErr
+			}}
+			os, notifications := newOrderService(repository)
+
+			order := &models.Order{ID: "1", UserID: "10", OrderPrice: 100, Weight: 5}
+			var progress strings.Builder
+			err := os.Accept(context.Background(), order, tt.pkgType, &progress)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if !strings.HasPrefix(progress.String(), "Calculating hash.") {
+				t.Errorf("progress = %q, want it written to the caller's writer", progress.String())
+			}
+			if inserted.Hash != "hash" {
+				t.Errorf("inserted hash = %q, want the generated one", inserted.Hash)
+			}
+			if inserted.PackageType != tt.wantType || inserted.OrderPrice != tt.wantPrice {
+				t.Errorf("inserted %s for %v, want %s for %v", inserted.PackageType, inserted.OrderPrice, tt.wantType, tt.wantPrice)
+			}
+			if sent := notifications.sent(); !slices.Equal(sent, tt.wantNotified) {
+				t.Errorf("notified %v, want %v", sent, tt.wantNotified)
+			}
+		})
+	}
+}
+
+func TestOrderServiceAcceptCanceled(t *testing.T) {
+	os, notifications := newOrderService(&mocks.Storage{})
+	release := make(chan struct{})
+	os.generateHash = func() string {
+		<-release
+		return "hash"
+	}
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- os.Accept(ctx, &models.Order{ID: "1"}, "box", io.Discard)
+	}()
+
+	waitFor(t, func() bool { return os.HashBacklog() == 1 })
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want %v", err, context.Canceled)
+	}
+	if len(notifications.sent()) != 0 {
+		t.Errorf("canceled order notified: %v", notifications.sent())
+	}
+
+	// The generator is still running, the backlog drops once it's done
+	release <- struct{}{}
+	waitFor(t, func() bool { return os.HashBacklog() == 0 })
+}
+
+func TestOrderServiceIssue(t *testing.T) {
+	tests := []struct {
+		name         string
+		issueErr     error
+		wantNotified []notified
+	}{
+		{
+			name:         "issued",
+			wantNotified: []notified{{models.EventIssued, "1"}, {models.EventIssued, "2"}},
+		},
+		{
+			name:     "update fails",
+			issueErr: util.ErrStorageUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				updated  []models.Order
+				recorded models.Payment
+			)
+			repository := &mocks.Storage{IssueUpdateFunc: func(ctx context.Context, orders []models.Order, payment *models.Payment) error {
+				updated, recorded = slices.Clone(orders), *payment
+				payment.ID = 1
+				return tt.issueErr
+			}}
+			os, notifications := newOrderService(repository)
+
+			ctx := auth.WithOperator(context.Background(), models.Operator{Login: "ivan", Role: models.RoleClerk})
+			orders := []models.Order{{ID: "1", UserID: "10"}, {ID: "2", UserID: "10"}}
+			payment := models.Payment{UserID: "10", Method: models.PaymentCard, Total: 200, Due: 200, Paid: 200}
+			err := os.Issue(ctx, &orders, &payment)
+			if !errors.Is(err, tt.issueErr) {
+				t.Fatalf("error = %v, want %v", err, tt.issueErr)
+			}
+			if recorded.Operator != "ivan" || !recorded.CreatedAt.Equal(testNow) {
+				t.Errorf("payment recorded by %q at %s, want by ivan at %s", recorded.Operator, recorded.CreatedAt, testNow)
+			}
+			if repository.Calls("IssueUpdate") != 1 {
+				t.Errorf("IssueUpdate called %d times, want once for all orders", repository.Calls("IssueUpdate"))
+			}
+			for _, order := range updated {
+				if !order.Issued || !order.IssuedAt.Equal(testNow) {
+					t.Errorf("order %s issued %v at %s, want at %s", order.ID, order.Issued, order.IssuedAt, testNow)
+				}
+			}
+			if sent := notifications.sent(); !slices.Equal(sent, tt.wantNotified) {
+				t.Errorf("notified %v, want %v", sent, tt.wantNotified)
+			}
+		})
+	}
+}
+
+func TestOrderServiceReturn(t *testing.T) {
+	tests := []struct {
+		name         string
+		updateErr    error
+		wantNotified []notified
+	}{
+		{
+			name:         "returned",
+			wantNotified: []notified{{models.EventReturned, "1"}},
+		},
+		{
+			name:      "update fails",
+			updateErr: util.ErrStorageUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				updated  models.Order
+				recorded models.Refund
+			)
+			repository := &mocks.Storage{UpdateFunc: func(ctx context.Context, order models.Order, refund *models.Refund) error {
+				updated, recorded = order, *refund
+				return tt.updateErr
+			}}
+			os, notifications := newOrderService(repository)
+
+			ctx := auth.WithOperator(context.Background(), models.Operator{Login: "ivan", Role: models.RoleClerk})
+			refund := models.Refund{OrderID: "1", Amount: 80}
+			err := os.Return(ctx, &models.Order{ID: "1", Issued: true, IssuedAt: testNow.Add(-time.Hour)}, &refund)
+			if !errors.Is(err, tt.updateErr) {
+				t.Fatalf("error = %v, want %v", err, tt.updateErr)
+			}
+			if !updated.Returned {
+				t.Error("order is not marked returned")
+			}
+			if recorded.Operator != "ivan" || !recorded.CreatedAt.Equal(testNow) || recorded.Amount != 80 {
+				t.Errorf("refund recorded as %+v, want the refund by ivan at %s", recorded, testNow)
+			}
+			if sent := notifications.sent(); !slices.Equal(sent, tt.wantNotified) {
+				t.Errorf("notified %v, want %v", sent, tt.wantNotified)
+			}
+		})
+	}
+}
+
+func TestOrderServiceReturnToCourier(t *testing.T) {
+	tests := []struct {
+		name      string
+		deleteErr error
+	}{
+		{name: "deleted"},
+		{name: "not found", deleteErr: util.ErrOrderNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deleted string
+			repository := &mocks.Storage{DeleteFunc: func(ctx context.Context, id string, now time.Time) error {
+				if !now.Equal(testNow) {
+					t.Errorf("Delete at %v, want the clock time", now)
+				}
+				deleted = id
+				return tt.deleteErr
+			}}
+			os, notifications := newOrderService(repository)
+
+			if err := os.ReturnToCourier(context.Background(), "1"); !errors.Is(err, tt.deleteErr) {
+				t.Fatalf("error = %v, want %v", err, tt.deleteErr)
+			}
+			if deleted != "1" {
+				t.Errorf("deleted %q, want 1", deleted)
+			}
+			if len(notifications.sent()) != 0 {
+				t.Errorf("notified %v, the courier return has no event", notifications.sent())
+			}
+		})
+	}
+}
+
+func TestOrderServiceLists(t *testing.T) {
+	stored := []models.Order{{ID: "1"}, {ID: "2"}}
+	repository := &mocks.Storage{
+		GetReturnsFunc: func(ctx context.Context, offset, limit int) ([]models.Order, error) {
+			if offset != 5 || limit != 10 {
+				t.Errorf("GetReturns(%d, %d), want (5, 10)", offset, limit)
+			}
+			return stored, nil
+		},
+		GetOrdersFunc: func(ctx context.Context, userId string, offset, limit int) ([]models.Order, error) {
+			if userId != "10" || offset != 0 || limit != 3 {
+				t.Errorf("GetOrders(%s, %d, %d), want (10, 0, 3)", userId, offset, limit)
+			}
+			return nil, util.ErrStorageUnavailable
+		},
+		FindFunc: func(ctx context.Context, filter models.OrderFilter) ([]models.Order, error) {
+			if filter.Status != models.StatusReturned || filter.Limit != 10 {
+				t.Errorf("Find(%+v), want the filter as given", filter)
+			}
+			return stored, nil
+		},
+	}
+	os, _ := newOrderService(repository)
+
+	returns, err := os.ListReturns(context.Background(), 5, 10)
+	if err != nil || len(returns) != len(stored) {
+		t.Errorf("ListReturns = %v, %v, want %v", returns, err, stored)
+	}
+	if _, err = os.ListOrders(context.Background(), "10", 0, 3); !errors.Is(err, util.ErrStorageUnavailable) {
+		t.Errorf("ListOrders error = %v, want %v", err, util.ErrStorageUnavailable)
+	}
+	found, err := os.Search(context.Background(), models.OrderFilter{Status: models.StatusReturned, Limit: 10})
+	if err != nil || len(found) != len(stored) {
+		t.Errorf("Search = %v, %v, want %v", found, err, stored)
+	}
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in 5s")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
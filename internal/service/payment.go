@@ -0,0 +1,114 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"homework/internal/i18n"
+	"homework/internal/models"
+	"homework/internal/util"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// PaymentService works out what the customer pays at pickup and prints the receipt
+type PaymentService interface {
+	// Charge builds the payment for the orders, it is recorded when the orders are issued with it
+	Charge(orders []models.Order, tender models.Tender) (models.Payment, error)
+	// PrintReceipt prints the payment as a text receipt or as JSON
+	PrintReceipt(payment models.Payment, asJSON bool) error
+}
+
+type paymentService struct{}
+
+func NewPaymentService() PaymentService {
+	return &paymentService{}
+}
+
+func (s *paymentService) Charge(orders []models.Order, tender models.Tender) (models.Payment, error) {
+	var payment models.Payment
+	for _, order := range orders {
+		payment.UserID = order.UserID
+		payment.Total += order.OrderPrice
+		// The order price includes its package, the receipt shows the two apart
+		payment.Lines = append(payment.Lines,
+			models.ReceiptLine{OrderID: order.ID, Kind: models.LineGoods, Amount: roundPrice(order.OrderPrice - order.PackagePrice)},
+			models.ReceiptLine{OrderID: order.ID, Kind: models.LinePackage, PackageType: order.PackageType, Amount: roundPrice(order.PackagePrice)},
+		)
+	}
+	payment.Total = roundPrice(payment.Total)
+
+	payment.Prepaid = roundPrice(tender.Prepaid)
+	if payment.Prepaid > payment.Total {
+		return models.Payment{}, util.ErrPrepaidExceedsTotal
+	}
+	payment.Due = roundPrice(payment.Total - payment.Prepaid)
+
+	paid := roundPrice(tender.Paid)
+	switch {
+	case payment.Due == 0:
+		// Nothing is taken at the counter, whatever the customer offered stays with them
+		payment.Method = models.PaymentPrepaid
+	case tender.Method == models.PaymentCard:
+		// The terminal charges the amount due, a different sum is a typo
+		if paid != 0 && paid != payment.Due {
+			return models.Payment{}, util.ErrCardAmountMismatch
+		}
+		payment.Method, payment.Paid = models.PaymentCard, payment.Due
+	case tender.Method == models.PaymentCash:
+		if paid < payment.Due {
+			return models.Payment{}, util.ErrPaymentInsufficient
+		}
+		payment.Method, payment.Paid, payment.Change = models.PaymentCash, paid, roundPrice(paid-payment.Due)
+	default:
+		return models.Payment{}, util.ErrPaymentMethodInvalid
+	}
+	return payment, nil
+}
+
+// roundPrice keeps prices in kopecks, so sums of float prices compare as the customer sees them
+func roundPrice(price models.Price) models.Price {
+	return models.Price(math.Round(float64(price)*100) / 100)
+}
+
+func (s *paymentService) PrintReceipt(payment models.Payment, asJSON bool) error {
+	if asJSON {
+		data, err := json.MarshalIndent(payment, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	writeReceipt(os.Stdout, payment)
+	return nil
+}
+
+// writeReceipt renders the text receipt, the lines of an order follow each other
+func writeReceipt(w io.Writer, payment models.Payment) {
+	fmt.Fprintln(w, i18n.T(i18n.MsgReceiptTitle, payment.ID, payment.CreatedAt.Format(time.DateTime), payment.Operator, payment.UserID))
+	fmt.Fprintf(w, "%-10s%-20s%-10s\n", i18n.T(i18n.ColumnOrderID), i18n.T(i18n.ColumnItem), i18n.T(i18n.ColumnAmount))
+	fmt.Fprintln(w, strings.Repeat("-", 40))
+	for _, line := range payment.Lines {
+		item := i18n.T(i18n.MsgReceiptGoods)
+		if line.Kind == models.LinePackage {
+			item = i18n.T(i18n.MsgReceiptPackage, line.PackageType)
+		}
+		fmt.Fprintf(w, "%-10s%-20s%-10v\n", line.OrderID, item, line.Amount)
+	}
+	fmt.Fprintln(w, strings.Repeat("-", 40))
+	fmt.Fprintln(w, i18n.T(i18n.MsgReceiptTotal, payment.Total))
+	if payment.Prepaid > 0 {
+		fmt.Fprintln(w, i18n.T(i18n.MsgReceiptPrepaid, payment.Prepaid))
+	}
+	fmt.Fprintln(w, i18n.T(i18n.MsgReceiptDue, payment.Due))
+	if payment.Method != models.PaymentPrepaid {
+		fmt.Fprintln(w, i18n.T(i18n.MsgReceiptPaid, payment.Paid, payment.Method))
+	}
+	if payment.Change > 0 {
+		fmt.Fprintln(w, i18n.T(i18n.MsgReceiptChange, payment.Change))
+	}
+	fmt.Fprintln(w)
+}
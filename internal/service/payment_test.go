@@ -0,0 +1,112 @@
+package service
+
+import (
+	"errors"
+	"homework/internal/models"
+	"homework/internal/util"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPaymentServiceCharge(t *testing.T) {
+	orders := []models.Order{
+		{ID: "1", UserID: "10", OrderPrice: 120.1, PackageType: "box", PackagePrice: 20},
+		{ID: "2", UserID: "10", OrderPrice: 80.2, PackageType: "film", PackagePrice: 1},
+	}
+	tests := []struct {
+		name    string
+		tender  models.Tender
+		want    models.Payment
+		wantErr error
+	}{
+		{
+			name:   "cash with change",
+			tender: models.Tender{Method: models.PaymentCash, Paid: 250},
+			want:   models.Payment{Method: models.PaymentCash, Total: 200.3, Due: 200.3, Paid: 250, Change: 49.7},
+		},
+		{
+			name:   "exact cash",
+			tender: models.Tender{Method: models.PaymentCash, Paid: 200.3},
+			want:   models.Payment{Method: models.PaymentCash, Total: 200.3, Due: 200.3, Paid: 200.3},
+		},
+		{
+			name:   "card is charged the amount due",
+			tender: models.Tender{Method: models.PaymentCard, Prepaid: 100},
+			want:   models.Payment{Method: models.PaymentCard, Total: 200.3, Prepaid: 100, Due: 100.3, Paid: 100.3},
+		},
+		{
+			name:   "prepaid in full takes nothing",
+			tender: models.Tender{Method: models.PaymentCash, Paid: 50, Prepaid: 200.3},
+			want:   models.Payment{Method: models.PaymentPrepaid, Total: 200.3, Prepaid: 200.3},
+		},
+		{
+			name:    "cash short of the amount due",
+			tender:  models.Tender{Method: models.PaymentCash, Paid: 200, Prepaid: 0.2},
+			wantErr: util.ErrPaymentInsufficient,
+		},
+		{
+			name:    "card for another amount",
+			tender:  models.Tender{Method: models.PaymentCard, Paid: 300},
+			wantErr: util.ErrCardAmountMismatch,
+		},
+		{
+			name:    "prepaid more than the total",
+			tender:  models.Tender{Method: models.PaymentCard, Prepaid: 300},
+			wantErr: util.ErrPrepaidExceedsTotal,
+		},
+		{
+			name:    "no method with something due",
+			tender:  models.Tender{Prepaid: 100},
+			wantErr: util.ErrPaymentMethodInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payment, err := NewPaymentService().Charge(orders, tt.tender)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if payment.UserID != "10" {
+				t.Errorf("payment of %q, want the customer of the orders", payment.UserID)
+			}
+			if !equalPayments(payment, tt.want) {
+				t.Errorf("payment = %+v, want %+v", payment, tt.want)
+			}
+		})
+	}
+}
+
+func equalPayments(a, b models.Payment) bool {
+	return a.Method == b.Method && a.Total == b.Total && a.Prepaid == b.Prepaid && a.Due == b.Due && a.Paid == b.Paid && a.Change == b.Change
+}
+
+func TestPaymentServiceReceiptLines(t *testing.T) {
+	orders := []models.Order{{ID: "1", UserID: "10", OrderPrice: 120, PackageType: "box", PackagePrice: 20}}
+	payment, err := NewPaymentService().Charge(orders, models.Tender{Method: models.PaymentCash, Paid: 150})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []models.ReceiptLine{
+		{OrderID: "1", Kind: models.LineGoods, Amount: 100},
+		{OrderID: "1", Kind: models.LinePackage, PackageType: "box", Amount: 20},
+	}
+	if !slices.Equal(payment.Lines, want) {
+		t.Errorf("lines = %+v, want %+v", payment.Lines, want)
+	}
+
+	payment.ID, payment.Operator, payment.CreatedAt = 3, "ivan", testNow
+	var sb strings.Builder
+	writeReceipt(&sb, payment)
+	receipt := sb.String()
+	for _, part := range []string{"Receipt 3", "operator ivan", "goods", "package box", "Total:   120", "Paid:    150 (cash)", "Change:  30"} {
+		if !strings.Contains(receipt, part) {
+			t.Errorf("receipt has no %q:\n%s", part, receipt)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"homework/internal/i18n"
+	"homework/internal/models"
+	"homework/internal/storage"
+	"homework/internal/util"
+	"homework/pkg/clock"
+	"strings"
+	"time"
+)
+
+// RefundService works out what a customer gets back for a returned order
+type RefundService interface {
+	// Calculate applies the refund rules to an order being returned, the refund is recorded by the return
+	Calculate(ctx context.Context, order models.Order) (models.Refund, error)
+	// List pages the refunds, the latest first, of one customer unless userId is empty
+	List(ctx context.Context, userId string, offset, limit int) ([]models.Refund, error)
+	PrintList(refunds []models.Refund)
+	// PrintRefund is the line the operator reads out when the return is accepted
+	PrintRefund(refund models.Refund)
+}
+
+// RefundRules are the pickup point policy, the goods are always refunded
+type RefundRules struct {
+	// RefundPackage gives the package price back too, by default it is kept
+	RefundPackage bool
+	// WithholdFees keeps the storage fees the order ran up out of the refund
+	WithholdFees bool
+}
+
+type refundService struct {
+	repository storage.Storage
+	fees       storageFees
+	rules      RefundRules
+}
+
+// NewRefundService charges the storage fees at feePerDay on clock when the rules withhold them
+func NewRefundService(repository storage.Storage, clock clock.Clock, feePerDay models.Price, rules RefundRules) RefundService {
+	return &refundService{
+		repository: repository,
+		fees:       storageFees{perDay: feePerDay, clock: clock},
+		rules:      rules,
+	}
+}
+
+func (s *refundService) Calculate(ctx context.Context, order models.Order) (models.Refund, error) {
+	refund := models.Refund{OrderID: order.ID, UserID: order.UserID}
+	goods, pkgPrice := order.OrderPrice-order.PackagePrice, order.PackagePrice
+
+	payment, err := s.repository.GetOrderPayment(ctx, order.ID)
+	switch {
+	case err == nil:
+		// The money goes back the way it came, at the prices of the receipt
+		refund.PaymentID, refund.Method = payment.ID, payment.Method
+		for _, line := range payment.Lines {
+			if line.OrderID != order.ID {
+				continue
+			}
+			if line.Kind == models.LinePackage {
+				pkgPrice = line.Amount
+			} else {
+				goods = line.Amount
+			}
+		}
+	case errors.Is(err, util.ErrPaymentNotFound):
+		// Issued before payments were recorded, the order price is all there is and the refund is paid in cash
+		refund.Method = models.PaymentCash
+	default:
+		return models.Refund{}, err
+	}
+
+	refund.Goods = roundPrice(goods)
+	if s.rules.RefundPackage {
+		refund.Package = roundPrice(pkgPrice)
+	}
+	if s.rules.WithholdFees {
+		// The fees can eat the whole refund, they are not collected beyond it
+		refund.Withheld = min(s.fees.fee(order), refund.Goods+refund.Package)
+	}
+	refund.Amount = roundPrice(refund.Goods + refund.Package - refund.Withheld)
+	return refund, nil
+}
+
+func (s *refundService) List(ctx context.Context, userId string, offset, limit int) ([]models.Refund, error) {
+	return s.repository.GetRefunds(ctx, userId, offset, limit)
+}
+
+func (s *refundService) PrintList(refunds []models.Refund) {
+	fmt.Printf("%-8s%-10s%-10s%-10s%-22s%-10s%-10s%-10s%-10s%-10s%-15s\n",
+		i18n.T(i18n.ColumnID), i18n.T(i18n.ColumnOrderID), i18n.T(i18n.ColumnUserID), i18n.T(i18n.ColumnPayment), i18n.T(i18n.ColumnCreatedAt), i18n.T(i18n.ColumnMethod), i18n.T(i18n.ColumnGoods), i18n.T(i18n.ColumnPackage), i18n.T(i18n.ColumnWithheld), i18n.T(i18n.ColumnAmount), i18n.T(i18n.ColumnOperator))
+	fmt.Println(strings.Repeat("-", 125))
+	for _, refund := range refunds {
+		payment := "-"
+		if refund.PaymentID != 0 {
+			payment = fmt.Sprint(refund.PaymentID)
+		}
+		fmt.Printf("%-8d%-10s%-10s%-10s%-22s%-10s%-10v%-10v%-10v%-10v%-15s\n",
+			refund.ID,
+			refund.OrderID,
+			refund.UserID,
+			payment,
+			refund.CreatedAt.Format(time.DateTime),
+			refund.Method,
+			refund.Goods,
+			refund.Package,
+			refund.Withheld,
+			refund.Amount,
+			refund.Operator)
+	}
+	fmt.Printf("\n")
+}
+
+func (s *refundService) PrintRefund(refund models.Refund) {
+	fmt.Println(i18n.T(i18n.MsgRefund, refund.Amount, refund.Method, refund.Goods, refund.Package, refund.Withheld))
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"homework/internal/models"
+	"homework/internal/storage/mocks"
+	"homework/internal/util"
+	"homework/pkg/clock"
+	"testing"
+	"time"
+)
+
+func TestRefundServiceCalculate(t *testing.T) {
+	order := models.Order{ID: "1", UserID: "10", StorageUntil: testNow.Add(-72 * time.Hour), Issued: true,
+		IssuedAt: testNow.Add(-24 * time.Hour), Returned: true, OrderPrice: 120, PackageType: "box", PackagePrice: 20}
+	payment := models.Payment{ID: 5, Method: models.PaymentCard, Lines: []models.ReceiptLine{
+		{OrderID: "2", Kind: models.LineGoods, Amount: 500},
+		{OrderID: "1", Kind: models.LineGoods, Amount: 100},
+		{OrderID: "1", Kind: models.LinePackage, PackageType: "box", Amount: 20},
+	}}
+
+	tests := []struct {
+		name       string
+		rules      RefundRules
+		feePerDay  models.Price
+		paymentErr error
+		want       models.Refund
+		wantErr    error
+	}{
+		{
+			name:  "goods only",
+			rules: RefundRules{},
+			want:  models.Refund{PaymentID: 5, Method: models.PaymentCard, Goods: 100, Amount: 100},
+		},
+		{
+			name:  "package refunded",
+			rules: RefundRules{RefundPackage: true},
+			want:  models.Refund{PaymentID: 5, Method: models.PaymentCard, Goods: 100, Package: 20, Amount: 120},
+		},
+		{
+			name:      "storage fees withheld",
+			rules:     RefundRules{WithholdFees: true},
+			feePerDay: 10,
+			want:      models.Refund{PaymentID: 5, Method: models.PaymentCard, Goods: 100, Withheld: 20, Amount: 80},
+		},
+		{
+			name:      "fees don't go past the refund",
+			rules:     RefundRules{RefundPackage: true, WithholdFees: true},
+			feePerDay: 1000,
+			want:      models.Refund{PaymentID: 5, Method: models.PaymentCard, Goods: 100, Package: 20, Withheld: 120},
+		},
+		{
+			name:       "issued before payments were recorded",
+			rules:      RefundRules{RefundPackage: true},
+			paymentErr: util.ErrPaymentNotFound,
+			want:       models.Refund{Method: models.PaymentCash, Goods: 100, Package: 20, Amount: 120},
+		},
+		{
+			name:       "storage fails",
+			paymentErr: util.ErrStorageUnavailable,
+			wantErr:    util.ErrStorageUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &mocks.Storage{GetOrderPaymentFunc: func(ctx context.Context, orderID string) (models.Payment, error) {
+				if orderID != "1" {
+					t.Errorf("GetOrderPayment(%s), want the returned order", orderID)
+				}
+				return payment, tt.paymentErr
+			}}
+			rs := NewRefundService(repository, clock.NewFake(testNow), tt.feePerDay, tt.rules)
+
+			refund, err := rs.Calculate(context.Background(), order)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			tt.want.OrderID, tt.want.UserID = "1", "10"
+			if refund != tt.want {
+				t.Errorf("refund = %+v, want %+v", refund, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefundServiceList(t *testing.T) {
+	repository := &mocks.Storage{GetRefundsFunc: func(ctx context.Context, userID string, offset, limit int) ([]models.Refund, error) {
+		if userID != "10" || offset != 5 || limit != 10 {
+			t.Errorf("GetRefunds(%s, %d, %d), want (10, 5, 10)", userID, offset, limit)
+		}
+		return []models.Refund{{ID: 1}}, nil
+	}}
+	rs := NewRefundService(repository, clock.NewFake(testNow), 10, RefundRules{})
+
+	refunds, err := rs.List(context.Background(), "10", 5, 10)
+	if err != nil || len(refunds) != 1 {
+		t.Errorf("List = %v, %v, want the stored refund", refunds, err)
+	}
+}
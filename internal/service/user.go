@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"homework/internal/i18n"
+	"homework/internal/models"
+	"homework/internal/storage"
+	"homework/internal/util"
+	"homework/pkg/clock"
+	"time"
+)
+
+// UserService manages customers and builds the customer card from their orders
+type UserService interface {
+	Create(ctx context.Context, id, name, phone string) (models.User, error)
+	Summary(ctx context.Context, id string) (models.UserSummary, error)
+	PrintSummary(summary models.UserSummary)
+}
+
+type userService struct {
+	repository storage.Storage
+	clock      clock.Clock
+	fees       storageFees
+}
+
+// NewUserService stamps new customers and counts storage fees with the time of clock
+func NewUserService(repository storage.Storage, clock clock.Clock, feePerDay models.Price) UserService {
+	return &userService{
+		repository: repository,
+		clock:      clock,
+		fees:       storageFees{perDay: feePerDay, clock: clock},
+	}
+}
+
+func (s *userService) Create(ctx context.Context, id, name, phone string) (models.User, error) {
+	if len(id) == 0 {
+		return models.User{}, util.ErrUserIdNotProvided
+	}
+
+	user := models.User{
+		ID:        id,
+		Name:      name,
+		Phone:     phone,
+		CreatedAt: s.clock.Now(),
+	}
+	if err := s.repository.InsertUser(ctx, user); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// Summary splits the orders of the customer into the ones waiting in the pickup point and the returns,
+// the orders they took and kept make the lifetime spend
+func (s *userService) Summary(ctx context.Context, id string) (models.UserSummary, error) {
+	if len(id) == 0 {
+		return models.UserSummary{}, util.ErrUserIdNotProvided
+	}
+
+	user, err := s.repository.GetUser(ctx, id)
+	if err != nil {
+		return models.UserSummary{}, err
+	}
+	orders, err := s.repository.GetUserOrders(ctx, id)
+	if err != nil {
+		return models.UserSummary{}, err
+	}
+
+	summary := models.UserSummary{User: user}
+	for _, order := range orders {
+		switch order.Status() {
+		case models.StatusStored:
+			summary.ActiveOrders = append(summary.ActiveOrders, order)
+			summary.OutstandingFees += s.fees.fee(order)
+		case models.StatusIssued:
+			summary.LifetimeSpend += order.OrderPrice
+		case models.StatusReturned:
+			summary.Returns = append(summary.Returns, order)
+		}
+	}
+	return summary, nil
+}
+
+func (s *userService) PrintSummary(summary models.UserSummary) {
+	user := summary.User
+	fmt.Println(i18n.T(i18n.MsgUserTitle, user.ID, user.Name, user.Phone, user.CreatedAt.Format(time.DateOnly)))
+	fmt.Println(i18n.T(i18n.MsgUserSpend, summary.LifetimeSpend))
+	fmt.Println(i18n.T(i18n.MsgUserFees, summary.OutstandingFees))
+	fmt.Println(i18n.T(i18n.MsgUserActive))
+	printOrders(summary.ActiveOrders)
+	fmt.Println(i18n.T(i18n.MsgUserReturns))
+	printOrders(summary.Returns)
+}
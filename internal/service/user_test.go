@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"homework/internal/models"
+	"homework/internal/storage/mocks"
+	"homework/internal/util"
+	"homework/pkg/clock"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestStorageFee(t *testing.T) {
+	fees := storageFees{perDay: 10, clock: clock.NewFake(testNow)}
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name  string
+		order models.Order
+		want  models.Price
+	}{
+		{name: "not due yet", order: models.Order{StorageUntil: testNow.Add(day)}, want: 0},
+		{name: "due right now", order: models.Order{StorageUntil: testNow}, want: 0},
+		{name: "started day", order: models.Order{StorageUntil: testNow.Add(-time.Hour)}, want: 10},
+		{name: "three days", order: models.Order{StorageUntil: testNow.Add(-2*day - time.Minute)}, want: 30},
+		{
+			name:  "issued late stops owing when it left",
+			order: models.Order{StorageUntil: testNow.Add(-5 * day), Issued: true, IssuedAt: testNow.Add(-4 * day)},
+			want:  10,
+		},
+		{
+			name:  "issued in time",
+			order: models.Order{StorageUntil: testNow, Issued: true, IssuedAt: testNow.Add(-day)},
+			want:  0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fees.fee(tt.order); got != tt.want {
+				t.Errorf("fee = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserServiceSummary(t *testing.T) {
+	day := 24 * time.Hour
+	orders := []models.Order{
+		{ID: "1", UserID: "10", OrderPrice: 100, StorageUntil: testNow.Add(day)},
+		{ID: "2", UserID: "10", OrderPrice: 200, StorageUntil: testNow.Add(-36 * time.Hour)},
+		{ID: "3", UserID: "10", OrderPrice: 300, StorageUntil: testNow.Add(-day), Issued: true, IssuedAt: testNow.Add(-2 * day)},
+		{ID: "4", UserID: "10", OrderPrice: 400, StorageUntil: testNow.Add(-day), Issued: true, IssuedAt: testNow.Add(-2 * day), Returned: true},
+	}
+	repository := &mocks.Storage{
+		GetUserFunc: func(ctx context.Context, id string) (models.User, error) {
+			if id != "10" {
+				return models.User{}, util.ErrUserNotFound
+			}
+			return models.User{ID: id, Name: "Anna"}, nil
+		},
+		GetUserOrdersFunc: func(ctx context.Context, userID string) ([]models.Order, error) {
+			return orders, nil
+		},
+	}
+	us := NewUserService(repository, clock.NewFake(testNow), 10)
+
+	summary, err := us.Summary(context.Background(), "10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.User.Name != "Anna" {
+		t.Errorf("user = %+v, want Anna", summary.User)
+	}
+	if ids := orderIDs(summary.ActiveOrders); !slices.Equal(ids, []string{"1", "2"}) {
+		t.Errorf("active orders = %v, want [1 2]", ids)
+	}
+	if ids := orderIDs(summary.Returns); !slices.Equal(ids, []string{"4"}) {
+		t.Errorf("returns = %v, want [4]", ids)
+	}
+	// Only the order taken and kept counts, the returned one was refunded
+	if summary.LifetimeSpend != 300 {
+		t.Errorf("lifetime spend = %v, want 300", summary.LifetimeSpend)
+	}
+	// Order 2 is a day and a half late, two started days
+	if summary.OutstandingFees != 20 {
+		t.Errorf("outstanding fees = %v, want 20", summary.OutstandingFees)
+	}
+
+	if _, err = us.Summary(context.Background(), "20"); !errors.Is(err, util.ErrUserNotFound) {
+		t.Errorf("unknown user error = %v, want %v", err, util.ErrUserNotFound)
+	}
+	if repository.Calls("GetUserOrders") != 1 {
+		t.Errorf("GetUserOrders called %d times, want only for the known user", repository.Calls("GetUserOrders"))
+	}
+}
+
+func TestUserServiceCreate(t *testing.T) {
+	var inserted models.User
+	repository := &mocks.Storage{InsertUserFunc: func(ctx context.Context, user models.User) error {
+		inserted = user
+		return nil
+	}}
+	us := NewUserService(repository, clock.NewFake(testNow), 10)
+
+	if _, err := us.Create(context.Background(), "", "Anna", ""); !errors.Is(err, util.ErrUserIdNotProvided) {
+		t.Errorf("no id error = %v, want %v", err, util.ErrUserIdNotProvided)
+	}
+	if _, err := us.Create(context.Background(), "10", "Anna", "+79990000000"); err != nil {
+		t.Fatal(err)
+	}
+	if inserted.ID != "10" || inserted.Name != "Anna" || !inserted.CreatedAt.Equal(testNow) {
+		t.Errorf("inserted %+v, want Anna created at %s", inserted, testNow)
+	}
+}
+
+func orderIDs(orders []models.Order) []string {
+	ids := make([]string, 0, len(orders))
+	for _, order := range orders {
+		ids = append(ids, order.ID)
+	}
+	return ids
+}
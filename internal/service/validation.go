@@ -1,36 +1,77 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"homework/internal/auth"
 	"homework/internal/models"
 	pkg "homework/internal/service/package"
 	"homework/internal/storage"
 	"homework/internal/util"
+	"homework/pkg/clock"
+	"math"
+	"slices"
 	"strconv"
 	"time"
 )
 
 type ValidationService interface {
-	ValidateAccept(id, userId, dateStr, orderPriceStr, weightStr, pkgTypeStr string) (*models.Order, error)
-	ValidateIssue(ids []string) (*[]models.Order, error)
-	ValidateAcceptReturn(id, userId string) (*models.Order, error)
-	ValidateReturnToCourier(id string) error
+	ValidateAccept(ctx context.Context, id, userId, dateStr, orderPriceStr, weightStr, pkgTypeStr, courierId string) (*models.Order, error)
+	ValidateIssue(ctx context.Context, ids []string) (*[]models.Order, error)
+	// ValidateTender parses what the customer pays with, the amounts may be empty,
+	// whether they cover the orders is up to the payment service
+	ValidateTender(methodStr, paidStr, prepaidStr string) (models.Tender, error)
+	ValidateAcceptReturn(ctx context.Context, id, userId string) (*models.Order, error)
+	ValidateReturnToCourier(ctx context.Context, id string) error
+	// ValidateCourierReturn collects the orders the courier takes back, the manifest of a courier return
+	ValidateCourierReturn(ctx context.Context, courierId string) ([]models.Order, error)
+	// ValidateHold checks the orders to hold exist, whether someone else holds them is up to the repository
+	ValidateHold(ctx context.Context, ids []string) error
 	ValidateList(offset, limit string) (int, int, error)
+	ValidateSearch(params SearchParams) (models.OrderFilter, error)
+}
+
+// SearchParams is a search as the operator typed it, the dates are days and both ends of a range are included
+type SearchParams struct {
+	Status      string
+	IDPrefix    string
+	UserID      string
+	PackageType string
+	StorageFrom string
+	StorageTo   string
+	IssuedFrom  string
+	IssuedTo    string
+	PriceMin    string
+	PriceMax    string
+	WeightMin   string
+	WeightMax   string
+	SortBy      string
+	Desc        bool
+	Offset      string
+	Limit       string
 }
 
 type validationService struct {
 	repository     storage.Storage
 	packageService pkg.PackageService
+	clock          clock.Clock
+	// autoCreateUsers lets an order for an unknown customer through, the repository adds them with the order
+	autoCreateUsers bool
 }
 
-func NewValidationService(repository storage.Storage, packageService pkg.PackageService) ValidationService {
+// NewValidationService checks storage dates, expiry and the return window against clock,
+// accept takes orders of unknown customers only with autoCreateUsers
+func NewValidationService(repository storage.Storage, packageService pkg.PackageService, clock clock.Clock, autoCreateUsers bool) ValidationService {
 	return &validationService{
-		repository:     repository,
-		packageService: packageService,
+		repository:      repository,
+		packageService:  packageService,
+		clock:           clock,
+		autoCreateUsers: autoCreateUsers,
 	}
 }
 
-func (v *validationService) ValidateAccept(id, userId, dateStr, orderPriceStr, weightStr, pkgTypeStr string) (*models.Order, error) {
+func (v *validationService) ValidateAccept(ctx context.Context, id, userId, dateStr, orderPriceStr, weightStr, pkgTypeStr, courierId string) (*models.Order, error) {
 	if len(id) == 0 {
 		return &models.Order{}, util.ErrOrderIdNotProvided
 	}
@@ -46,25 +87,45 @@ func (v *validationService) ValidateAccept(id, userId, dateStr, orderPriceStr, w
 
 	storageUntil, err := time.Parse(time.DateOnly, dateStr)
 	if err != nil {
-		return &models.Order{}, errors.New("error parsing date")
-	} else if storageUntil.Before(time.Now()) {
+		return &models.Order{}, util.ErrDateInvalid.Wrap(err)
+	} else if storageUntil.Before(v.clock.Now()) {
 		return &models.Order{}, util.ErrDateInvalid
 	}
 
+	// ParseFloat takes NaN and Inf, neither is a price or a weight
 	orderPriceFloat, err := strconv.ParseFloat(orderPriceStr, 64)
-	if err != nil || orderPriceFloat <= 0 {
+	if err != nil {
+		return &models.Order{}, util.ErrOrderPriceInvalid.Wrap(err)
+	} else if !(orderPriceFloat > 0) || math.IsInf(orderPriceFloat, 0) {
 		return &models.Order{}, util.ErrOrderPriceInvalid
 	}
 
 	weightFloat, err := strconv.ParseFloat(weightStr, 64)
-	if err != nil || weightFloat <= 0 {
+	if err != nil {
+		return &models.Order{}, util.ErrWeightInvalid.Wrap(err)
+	} else if !(weightFloat > 0) || math.IsInf(weightFloat, 0) {
 		return &models.Order{}, util.ErrWeightInvalid
 	}
 
-	//Check for existence
-	_, err = v.repository.Get(id)
+	//Check for existence, a db failure must not let the order through
+	_, err = v.repository.Get(ctx, id)
 	if err == nil {
 		return &models.Order{}, util.ErrOrderExists
+	} else if !errors.Is(err, util.ErrOrderNotFound) {
+		return &models.Order{}, err
+	}
+
+	if _, err = v.repository.GetUser(ctx, userId); err != nil {
+		if !errors.Is(err, util.ErrUserNotFound) || !v.autoCreateUsers {
+			return &models.Order{}, err
+		}
+	}
+
+	// Orders accepted without a courier are still allowed, they just can't go back with a courier return
+	if len(courierId) > 0 {
+		if _, err = v.repository.GetCourier(ctx, courierId); err != nil {
+			return &models.Order{}, err
+		}
 	}
 
 	orderPrice := models.Price(orderPriceFloat)
@@ -81,28 +142,31 @@ func (v *validationService) ValidateAccept(id, userId, dateStr, orderPriceStr, w
 		StorageUntil: storageUntil,
 		OrderPrice:   orderPrice,
 		Weight:       weight,
+		CourierID:    courierId,
 	}
 
 	return &order, nil
 }
 
-func (v *validationService) ValidateIssue(ids []string) (*[]models.Order, error) {
+func (v *validationService) ValidateIssue(ctx context.Context, ids []string) (*[]models.Order, error) {
 	var ordersToIssue []models.Order
 
 	if len(ids) == 0 {
 		return &ordersToIssue, util.ErrUserIdNotProvided
 	}
+	// An order listed twice would be charged twice and break the payment, it is issued once
+	ids = distinct(ids)
 
-	order, err := v.repository.Get(ids[0])
+	order, err := v.repository.Get(ctx, ids[0])
 	if err != nil {
-		return &ordersToIssue, util.ErrOrderNotFound
+		return &ordersToIssue, err
 	}
 	recipientID := order.UserID
 
 	for _, id := range ids {
-		order, err = v.repository.Get(id)
+		order, err = v.repository.Get(ctx, id)
 		if err != nil {
-			return &ordersToIssue, util.ErrOrderNotFound
+			return &ordersToIssue, err
 		}
 		if order.Issued {
 			return &ordersToIssue, util.ErrOrderIssued
@@ -110,7 +174,7 @@ func (v *validationService) ValidateIssue(ids []string) (*[]models.Order, error)
 		if order.Returned {
 			return &ordersToIssue, util.ErrOrderReturned
 		}
-		if time.Now().After(order.StorageUntil) {
+		if v.clock.Now().After(order.StorageUntil) {
 			return &ordersToIssue, util.ErrOrderExpired
 		}
 
@@ -122,10 +186,58 @@ func (v *validationService) ValidateIssue(ids []string) (*[]models.Order, error)
 		ordersToIssue = append(ordersToIssue, order)
 	}
 
+	if err = v.checkHolds(ctx, ids...); err != nil {
+		return &[]models.Order{}, err
+	}
+
 	return &ordersToIssue, nil
 }
 
-func (v *validationService) ValidateAcceptReturn(id, userId string) (*models.Order, error) {
+// distinct drops the repeated ids, the first occurrence keeps its place
+func distinct(ids []string) []string {
+	unique := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if !slices.Contains(unique, id) {
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+func (v *validationService) ValidateTender(methodStr, paidStr, prepaidStr string) (models.Tender, error) {
+	method := models.PaymentMethod(methodStr)
+	// No method is fine as long as the orders are prepaid in full
+	if len(method) > 0 && method != models.PaymentCash && method != models.PaymentCard {
+		return models.Tender{}, util.ErrPaymentMethodInvalid
+	}
+
+	paid, err := parseAmount(paidStr, util.ErrPaidInvalid)
+	if err != nil {
+		return models.Tender{}, err
+	}
+	prepaid, err := parseAmount(prepaidStr, util.ErrPrepaidInvalid)
+	if err != nil {
+		return models.Tender{}, err
+	}
+
+	return models.Tender{Method: method, Paid: paid, Prepaid: prepaid}, nil
+}
+
+// parseAmount reads an optional sum of money, empty is zero
+func parseAmount(amountStr string, invalid *util.Error) (models.Price, error) {
+	if len(amountStr) == 0 {
+		return 0, nil
+	}
+	amount, err := strconv.ParseFloat(amountStr, 64)
+	if err != nil {
+		return 0, invalid.Wrap(err)
+	} else if !(amount >= 0) || math.IsInf(amount, 0) {
+		return 0, invalid
+	}
+	return models.Price(amount), nil
+}
+
+func (v *validationService) ValidateAcceptReturn(ctx context.Context, id, userId string) (*models.Order, error) {
 	if len(id) == 0 {
 		return &models.Order{}, util.ErrOrderIdNotProvided
 	}
@@ -134,9 +246,9 @@ func (v *validationService) ValidateAcceptReturn(id, userId string) (*models.Ord
 		return &models.Order{}, util.ErrUserIdNotProvided
 	}
 
-	order, err := v.repository.Get(id)
+	order, err := v.repository.Get(ctx, id)
 	if err != nil {
-		return &models.Order{}, util.ErrOrderNotFound
+		return &models.Order{}, err
 	}
 
 	if order.UserID != userId {
@@ -145,25 +257,29 @@ func (v *validationService) ValidateAcceptReturn(id, userId string) (*models.Ord
 	if !order.Issued {
 		return &models.Order{}, util.ErrOrderNotIssued
 	}
-	if time.Now().After(order.IssuedAt.Add(48 * time.Hour)) {
+	if v.clock.Now().After(order.IssuedAt.Add(48 * time.Hour)) {
 		return &models.Order{}, util.ErrReturnPeriodExpired
 	}
 
+	if err = v.checkHolds(ctx, id); err != nil {
+		return &models.Order{}, err
+	}
+
 	return &order, nil
 }
 
-func (v *validationService) ValidateReturnToCourier(id string) error {
+func (v *validationService) ValidateReturnToCourier(ctx context.Context, id string) error {
 	if len(id) == 0 {
 		return util.ErrOrderIdNotProvided
 	}
 
 	if _, err := strconv.Atoi(id); err != nil {
-		return util.ErrOrderIdInvalid
+		return util.ErrOrderIdInvalid.Wrap(err)
 	}
 
-	order, err := v.repository.Get(id)
+	order, err := v.repository.Get(ctx, id)
 	if err != nil {
-		return util.ErrOrderNotFound
+		return err
 	}
 
 	if order.Issued {
@@ -171,22 +287,207 @@ func (v *validationService) ValidateReturnToCourier(id string) error {
 	}
 
 	//skip checking for a period, to ensure that its working
-	//if time.Now().Before(order.StorageUntil) {
+	//if v.clock.Now().Before(order.StorageUntil) {
 	//	return util.ErrOrderNotExpired
 	//}
 
+	return v.checkHolds(ctx, id)
+}
+
+func (v *validationService) ValidateCourierReturn(ctx context.Context, courierId string) ([]models.Order, error) {
+	if err := validateCourierId(courierId); err != nil {
+		return nil, err
+	}
+
+	if _, err := v.repository.GetCourier(ctx, courierId); err != nil {
+		return nil, err
+	}
+
+	returnable, err := v.repository.GetCourierReturnable(ctx, courierId, v.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	// An order held at another terminal stays for the next courier return
+	ids := make([]string, 0, len(returnable))
+	for _, order := range returnable {
+		ids = append(ids, order.ID)
+	}
+	held, err := v.heldByOthers(ctx, ids...)
+	if err != nil {
+		return nil, err
+	}
+	orders := slices.DeleteFunc(returnable, func(order models.Order) bool {
+		return slices.ContainsFunc(held, func(hold models.Hold) bool { return hold.OrderID == order.ID })
+	})
+	if len(orders) == 0 {
+		return nil, util.ErrManifestEmpty
+	}
+
+	return orders, nil
+}
+
+func (v *validationService) ValidateHold(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return util.ErrOrderIdNotProvided
+	}
+	for _, id := range ids {
+		if len(id) == 0 {
+			return util.ErrOrderIdNotProvided
+		}
+		if _, err := v.repository.Get(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// heldByOthers returns the holds active on the orders that belong to another operator than the one in ctx
+func (v *validationService) heldByOthers(ctx context.Context, ids ...string) ([]models.Hold, error) {
+	holds, err := v.repository.GetOrderHolds(ctx, ids, v.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	actor := auth.Actor(ctx)
+	return slices.DeleteFunc(holds, func(hold models.Hold) bool {
+		return hold.Operator == actor
+	}), nil
+}
+
+// checkHolds fails when another operator holds one of the orders
+func (v *validationService) checkHolds(ctx context.Context, ids ...string) error {
+	held, err := v.heldByOthers(ctx, ids...)
+	if err != nil {
+		return err
+	}
+	if len(held) > 0 {
+		hold := held[0]
+		return util.ErrOrderHeld.Wrap(fmt.Errorf("order %s held by %s until %s", hold.OrderID, hold.Operator, hold.ExpiresAt.Format(time.DateTime)))
+	}
 	return nil
 }
 
+// maxListLimit caps a page, so a listing can't pull a whole table
+const maxListLimit = 100
+
 func (v *validationService) ValidateList(offset, limit string) (int, int, error) {
 	offsetInt, err := strconv.Atoi(offset)
 	if err != nil {
-		return -1, -1, err
+		return -1, -1, util.ErrListParamInvalid.WithField("offset").Wrap(err)
+	}
+	if offsetInt < 0 {
+		return -1, -1, util.ErrListParamInvalid.WithField("offset").Wrap(fmt.Errorf("offset %d", offsetInt))
 	}
 	limitInt, err := strconv.Atoi(limit)
 	if err != nil {
-		return -1, -1, err
+		return -1, -1, util.ErrListParamInvalid.WithField("limit").Wrap(err)
+	}
+	if limitInt < 1 || limitInt > maxListLimit {
+		return -1, -1, util.ErrListParamInvalid.WithField("limit").Wrap(fmt.Errorf("limit %d", limitInt))
 	}
 
 	return offsetInt, limitInt, nil
 }
+
+func (v *validationService) ValidateSearch(params SearchParams) (models.OrderFilter, error) {
+	filter := models.OrderFilter{
+		Status:      models.OrderStatus(params.Status),
+		IDPrefix:    params.IDPrefix,
+		UserID:      params.UserID,
+		PackageType: models.PackageType(params.PackageType),
+		SortBy:      params.SortBy,
+		Desc:        params.Desc,
+	}
+
+	switch filter.Status {
+	case "", models.StatusStored, models.StatusIssued, models.StatusReturned:
+	default:
+		return models.OrderFilter{}, util.ErrStatusInvalid
+	}
+	switch filter.PackageType {
+	case "", pkg.BoxType, pkg.PacketType, pkg.FilmType:
+	default:
+		return models.OrderFilter{}, util.ErrPackageTypeInvalid
+	}
+	if len(filter.SortBy) == 0 {
+		filter.SortBy = "id"
+	} else if !slices.Contains(models.SortColumns, filter.SortBy) {
+		return models.OrderFilter{}, util.ErrSortColumnInvalid
+	}
+
+	var err error
+	if filter.StorageFrom, filter.StorageTo, err = parseDays(params.StorageFrom, params.StorageTo, "until_from", "until_to"); err != nil {
+		return models.OrderFilter{}, err
+	}
+	if filter.IssuedFrom, filter.IssuedTo, err = parseDays(params.IssuedFrom, params.IssuedTo, "issued_from", "issued_to"); err != nil {
+		return models.OrderFilter{}, err
+	}
+
+	priceMin, priceMax, err := parseRange(params.PriceMin, params.PriceMax, "price_min", "price_max", util.ErrOrderPriceInvalid)
+	if err != nil {
+		return models.OrderFilter{}, err
+	}
+	filter.PriceMin, filter.PriceMax = models.Price(priceMin), models.Price(priceMax)
+
+	weightMin, weightMax, err := parseRange(params.WeightMin, params.WeightMax, "w_min", "w_max", util.ErrWeightInvalid)
+	if err != nil {
+		return models.OrderFilter{}, err
+	}
+	filter.WeightMin, filter.WeightMax = models.Weight(weightMin), models.Weight(weightMax)
+
+	if filter.Offset, filter.Limit, err = v.ValidateList(params.Offset, params.Limit); err != nil {
+		return models.OrderFilter{}, err
+	}
+	return filter, nil
+}
+
+// parseDays turns a range of days into [from, to), to is moved to the start of the next day so it's included
+func parseDays(fromStr, toStr, fromField, toField string) (time.Time, time.Time, error) {
+	var from, to time.Time
+	var err error
+	if len(fromStr) > 0 {
+		if from, err = time.Parse(time.DateOnly, fromStr); err != nil {
+			return time.Time{}, time.Time{}, util.ErrDateInvalid.WithField(fromField).Wrap(err)
+		}
+	}
+	if len(toStr) > 0 {
+		if to, err = time.Parse(time.DateOnly, toStr); err != nil {
+			return time.Time{}, time.Time{}, util.ErrDateInvalid.WithField(toField).Wrap(err)
+		}
+		if !from.IsZero() && from.After(to) {
+			return time.Time{}, time.Time{}, util.ErrRangeInvalid.WithField(fromField)
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+	return from, to, nil
+}
+
+// parseRange reads the optional ends of a price or weight range, a set end must be a positive number
+func parseRange(minStr, maxStr, minField, maxField string, invalid *util.Error) (float64, float64, error) {
+	parse := func(s, field string) (float64, error) {
+		if len(s) == 0 {
+			return 0, nil
+		}
+		value, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, invalid.WithField(field).Wrap(err)
+		}
+		if !(value > 0) || math.IsInf(value, 0) {
+			return 0, invalid.WithField(field)
+		}
+		return value, nil
+	}
+
+	minValue, err := parse(minStr, minField)
+	if err != nil {
+		return 0, 0, err
+	}
+	maxValue, err := parse(maxStr, maxField)
+	if err != nil {
+		return 0, 0, err
+	}
+	if minValue > 0 && maxValue > 0 && minValue > maxValue {
+		return 0, 0, util.ErrRangeInvalid.WithField(minField)
+	}
+	return minValue, maxValue, nil
+}
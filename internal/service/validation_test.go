@@ -0,0 +1,666 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"homework/internal/auth"
+	"homework/internal/models"
+	pkg "homework/internal/service/package"
+	"homework/internal/storage/mocks"
+	"homework/internal/util"
+	"homework/pkg/clock"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+
+// testUser is the customer the accept tests take orders for, testCourier the courier who brings them
+const (
+	testUser    = "10"
+	testCourier = "7"
+)
+
+// storageWith answers Get from the given orders, like the repository does for a missing one,
+// GetUser knows testUser and the customers of the orders, GetCourier knows testCourier only and nothing is held
+func storageWith(orders ...models.Order) *mocks.Storage {
+	byID := make(map[string]models.Order, len(orders))
+	users := map[string]bool{testUser: true}
+	for _, order := range orders {
+		byID[order.ID] = order
+		users[order.UserID] = true
+	}
+	return &mocks.Storage{
+		GetUserFunc: func(ctx context.Context, id string) (models.User, error) {
+			if !users[id] {
+				return models.User{}, util.ErrUserNotFound
+			}
+			return models.User{ID: id}, nil
+		},
+		GetCourierFunc: func(ctx context.Context, id string) (models.Courier, error) {
+			if id != testCourier {
+				return models.Courier{}, util.ErrCourierNotFound
+			}
+			return models.Courier{ID: id}, nil
+		},
+		GetOrderHoldsFunc: func(ctx context.Context, ids []string, now time.Time) ([]models.Hold, error) {
+			return nil, nil
+		},
+		GetCourierReturnableFunc: func(ctx context.Context, courierID string, now time.Time) ([]models.Order, error) {
+			var returnable []models.Order
+			for _, order := range orders {
+				if order.CourierID == courierID && order.ReturnableToCourier(now) {
+					returnable = append(returnable, order)
+				}
+			}
+			return returnable, nil
+		},
+		GetFunc: func(ctx context.Context, id string) (models.Order, error) {
+			order, ok := byID[id]
+			if !ok {
+				return models.Order{}, util.ErrOrderNotFound
+			}
+			return order, nil
+		},
+	}
+}
+
+func newValidationService(repository *mocks.Storage) *validationService {
+	return NewValidationService(repository, pkg.NewPackageService(), clock.NewFake(testNow), false).(*validationService)
+}
+
+func TestValidateAccept(t *testing.T) {
+	type args struct {
+		id, userID, date, price, weight, pkgType, courierID string
+	}
+	valid := args{id: "1", userID: testUser, date: "2024-07-02", price: "100", weight: "5", pkgType: "box"}
+	with := func(change func(a *args)) args {
+		a := valid
+		change(&a)
+		return a
+	}
+
+	tests := []struct {
+		name       string
+		args       args
+		repository *mocks.Storage
+		autoCreate bool
+		wantErr    error
+		wantField  string
+		want       *models.Order
+	}{
+		{
+			name:       "valid",
+			args:       valid,
+			repository: storageWith(),
+			want: &models.Order{
+				ID:           "1",
+				UserID:       "10",
+				StorageUntil: time.Date(2024, 7, 2, 0, 0, 0, 0, time.UTC),
+				OrderPrice:   100,
+				Weight:       5,
+			},
+		},
+		{name: "no id", args: with(func(a *args) { a.id = "" }), wantErr: util.ErrOrderIdNotProvided},
+		{name: "no user", args: with(func(a *args) { a.userID = "" }), wantErr: util.ErrUserIdNotProvided},
+		{name: "no weight", args: with(func(a *args) { a.weight = "" }), wantErr: util.ErrWeightNotProvided},
+		{name: "no price", args: with(func(a *args) { a.price = "" }), wantErr: util.ErrPriceNotProvided},
+		{name: "date not parsed", args: with(func(a *args) { a.date = "02.07.2024" }), wantErr: util.ErrDateInvalid, wantField: "storage_until"},
+		{name: "date in the past", args: with(func(a *args) { a.date = "2024-07-01" }), wantErr: util.ErrDateInvalid},
+		{name: "price not a number", args: with(func(a *args) { a.price = "ten" }), wantErr: util.ErrOrderPriceInvalid, wantField: "price"},
+		{name: "zero price", args: with(func(a *args) { a.price = "0" }), wantErr: util.ErrOrderPriceInvalid},
+		{name: "negative price", args: with(func(a *args) { a.price = "-1" }), wantErr: util.ErrOrderPriceInvalid},
+		{name: "price not finite", args: with(func(a *args) { a.price = "Inf" }), wantErr: util.ErrOrderPriceInvalid},
+		{name: "weight not a number", args: with(func(a *args) { a.weight = "5kg" }), wantErr: util.ErrWeightInvalid, wantField: "weight"},
+		{name: "zero weight", args: with(func(a *args) { a.weight = "0" }), wantErr: util.ErrWeightInvalid},
+		{name: "weight not a number value", args: with(func(a *args) { a.weight = "NaN" }), wantErr: util.ErrWeightInvalid},
+		{
+			name:       "order exists",
+			args:       valid,
+			repository: storageWith(models.Order{ID: "1"}),
+			wantErr:    util.ErrOrderExists,
+		},
+		{
+			name: "storage unavailable",
+			args: valid,
+			repository: &mocks.Storage{GetFunc: func(ctx context.Context, id string) (models.Order, error) {
+				return models.Order{}, util.ErrStorageUnavailable
+			}},
+			wantErr: util.ErrStorageUnavailable,
+		},
+		{
+			name:       "courier recorded",
+			args:       with(func(a *args) { a.courierID = testCourier }),
+			repository: storageWith(),
+			want: &models.Order{
+				ID:           "1",
+				UserID:       "10",
+				StorageUntil: time.Date(2024, 7, 2, 0, 0, 0, 0, time.UTC),
+				OrderPrice:   100,
+				Weight:       5,
+				CourierID:    testCourier,
+			},
+		},
+		{
+			name:       "unknown courier",
+			args:       with(func(a *args) { a.courierID = "8" }),
+			repository: storageWith(),
+			wantErr:    util.ErrCourierNotFound,
+		},
+		{
+			name:       "unknown customer",
+			args:       with(func(a *args) { a.userID = "30" }),
+			repository: storageWith(),
+			wantErr:    util.ErrUserNotFound,
+		},
+		{
+			name:       "unknown customer is created",
+			args:       with(func(a *args) { a.userID = "30" }),
+			repository: storageWith(),
+			autoCreate: true,
+		},
+		{
+			name: "customer lookup fails",
+			args: valid,
+			repository: &mocks.Storage{
+				GetFunc: storageWith().GetFunc,
+				GetUserFunc: func(ctx context.Context, id string) (models.User, error) {
+					return models.User{}, util.ErrStorageUnavailable
+				},
+			},
+			autoCreate: true,
+			wantErr:    util.ErrStorageUnavailable,
+		},
+		{
+			name:       "too heavy for a packet",
+			args:       with(func(a *args) { a.pkgType = "packet"; a.weight = "10" }),
+			repository: storageWith(),
+			wantErr:    util.ErrWeightExceeds,
+		},
+		{
+			name:       "too heavy for a box",
+			args:       with(func(a *args) { a.weight = "30" }),
+			repository: storageWith(),
+			wantErr:    util.ErrWeightExceeds,
+		},
+		{
+			name:       "unknown package",
+			args:       with(func(a *args) { a.pkgType = "envelope" }),
+			repository: storageWith(),
+			wantErr:    util.ErrPackageTypeInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := tt.repository
+			if repository == nil {
+				// The input is rejected before the repository is asked
+				repository = &mocks.Storage{}
+			}
+			v := newValidationService(repository)
+			v.autoCreateUsers = tt.autoCreate
+
+			order, err := v.ValidateAccept(context.Background(), tt.args.id, tt.args.userID, tt.args.date, tt.args.price, tt.args.weight, tt.args.pkgType, tt.args.courierID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if len(tt.wantField) > 0 {
+				if field := util.AsError(err).Field; field != tt.wantField {
+					t.Errorf("field = %q, want %q", field, tt.wantField)
+				}
+			}
+			if tt.want != nil && *order != *tt.want {
+				t.Errorf("order = %+v, want %+v", *order, *tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateIssue(t *testing.T) {
+	pending := func(id, userID string) models.Order {
+		return models.Order{ID: id, UserID: userID, StorageUntil: testNow.Add(24 * time.Hour)}
+	}
+	issued := pending("2", "10")
+	issued.Issued = true
+	returned := pending("3", "10")
+	returned.Returned = true
+	expired := pending("4", "10")
+	expired.StorageUntil = testNow.Add(-time.Second)
+
+	repository := storageWith(pending("1", "10"), issued, returned, expired, pending("5", "20"), pending("6", "10"))
+
+	tests := []struct {
+		name    string
+		ids     []string
+		wantErr error
+		wantIDs []string
+	}{
+		{name: "one order", ids: []string{"1"}, wantIDs: []string{"1"}},
+		{name: "orders of one user", ids: []string{"1", "6"}, wantIDs: []string{"1", "6"}},
+		{name: "repeated ids", ids: []string{"1", "6", "1"}, wantIDs: []string{"1", "6"}},
+		{name: "no ids", ids: nil, wantErr: util.ErrUserIdNotProvided},
+		{name: "unknown first order", ids: []string{"404"}, wantErr: util.ErrOrderNotFound},
+		{name: "unknown later order", ids: []string{"1", "404"}, wantErr: util.ErrOrderNotFound},
+		{name: "already issued", ids: []string{"2"}, wantErr: util.ErrOrderIssued},
+		{name: "returned", ids: []string{"3"}, wantErr: util.ErrOrderReturned},
+		{name: "storage expired", ids: []string{"4"}, wantErr: util.ErrOrderExpired},
+		{name: "different users", ids: []string{"1", "5"}, wantErr: util.ErrOrdersUserDiffers},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orders, err := newValidationService(repository).ValidateIssue(context.Background(), tt.ids)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(*orders) != len(tt.wantIDs) {
+				t.Fatalf("got %d orders, want %d", len(*orders), len(tt.wantIDs))
+			}
+			for i, order := range *orders {
+				if order.ID != tt.wantIDs[i] {
+					t.Errorf("order %d = %s, want %s", i, order.ID, tt.wantIDs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidateIssueStorageError(t *testing.T) {
+	repository := &mocks.Storage{GetFunc: func(ctx context.Context, id string) (models.Order, error) {
+		return models.Order{}, util.ErrStorageUnavailable
+	}}
+
+	_, err := newValidationService(repository).ValidateIssue(context.Background(), []string{"1"})
+	if !errors.Is(err, util.ErrStorageUnavailable) {
+		t.Fatalf("error = %v, want %v", err, util.ErrStorageUnavailable)
+	}
+}
+
+func TestValidateAcceptReturn(t *testing.T) {
+	issuedAt := func(id string, ago time.Duration) models.Order {
+		return models.Order{ID: id, UserID: "10", Issued: true, IssuedAt: testNow.Add(-ago)}
+	}
+	repository := storageWith(
+		issuedAt("1", time.Hour),
+		issuedAt("2", 48*time.Hour),
+		issuedAt("3", 48*time.Hour+time.Second),
+		models.Order{ID: "4", UserID: "10"},
+	)
+
+	tests := []struct {
+		name    string
+		id      string
+		userID  string
+		wantErr error
+	}{
+		{name: "within the window", id: "1", userID: "10"},
+		{name: "last moment of the window", id: "2", userID: "10"},
+		{name: "window closed", id: "3", userID: "10", wantErr: util.ErrReturnPeriodExpired},
+		{name: "not issued", id: "4", userID: "10", wantErr: util.ErrOrderNotIssued},
+		{name: "other user", id: "1", userID: "20", wantErr: util.ErrOrderDoesNotBelong},
+		{name: "unknown order", id: "404", userID: "10", wantErr: util.ErrOrderNotFound},
+		{name: "no id", id: "", userID: "10", wantErr: util.ErrOrderIdNotProvided},
+		{name: "no user", id: "1", userID: "", wantErr: util.ErrUserIdNotProvided},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order, err := newValidationService(repository).ValidateAcceptReturn(context.Background(), tt.id, tt.userID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && order.ID != tt.id {
+				t.Errorf("order = %s, want %s", order.ID, tt.id)
+			}
+		})
+	}
+}
+
+func TestValidateReturnToCourier(t *testing.T) {
+	repository := storageWith(
+		models.Order{ID: "1", UserID: "10"},
+		models.Order{ID: "2", UserID: "10", Issued: true},
+	)
+
+	tests := []struct {
+		name    string
+		id      string
+		wantErr error
+	}{
+		{name: "kept order", id: "1"},
+		{name: "issued order", id: "2", wantErr: util.ErrOrderIssued},
+		{name: "unknown order", id: "404", wantErr: util.ErrOrderNotFound},
+		{name: "no id", id: "", wantErr: util.ErrOrderIdNotProvided},
+		{name: "id not a number", id: "abc", wantErr: util.ErrOrderIdInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newValidationService(repository).ValidateReturnToCourier(context.Background(), tt.id)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateCourierReturn(t *testing.T) {
+	day := 24 * time.Hour
+	courierOrders := storageWith(
+		models.Order{ID: "1", CourierID: testCourier, StorageUntil: testNow.Add(-day)},
+		models.Order{ID: "2", CourierID: testCourier, StorageUntil: testNow.Add(day)},
+		models.Order{ID: "3", CourierID: testCourier, Issued: true, IssuedAt: testNow.Add(-2 * day)},
+		models.Order{ID: "4", CourierID: testCourier, Issued: true, IssuedAt: testNow.Add(-day), Returned: true},
+		models.Order{ID: "5", CourierID: "8", StorageUntil: testNow.Add(-day)},
+	)
+
+	tests := []struct {
+		name       string
+		courierID  string
+		repository *mocks.Storage
+		want       []string
+		wantErr    error
+	}{
+		{name: "expired and returned", courierID: testCourier, repository: courierOrders, want: []string{"1", "4"}},
+		{name: "nothing to return", courierID: testCourier, repository: storageWith(), wantErr: util.ErrManifestEmpty},
+		{name: "unknown courier", courierID: "8", repository: courierOrders, wantErr: util.ErrCourierNotFound},
+		{name: "no courier", courierID: "", repository: &mocks.Storage{}, wantErr: util.ErrCourierIdNotProvided},
+		{name: "courier id with a path", courierID: "../../x", repository: &mocks.Storage{}, wantErr: util.ErrCourierIdInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orders, err := newValidationService(tt.repository).ValidateCourierReturn(context.Background(), tt.courierID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if ids := orderIDs(orders); tt.want != nil && !slices.Equal(ids, tt.want) {
+				t.Errorf("manifest = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+// holding makes the repository answer GetOrderHolds from holds, the ones expired at now are left out like postgres does
+func holding(repository *mocks.Storage, holds ...models.Hold) *mocks.Storage {
+	repository.GetOrderHoldsFunc = func(ctx context.Context, ids []string, now time.Time) ([]models.Hold, error) {
+		var active []models.Hold
+		for _, hold := range holds {
+			if slices.Contains(ids, hold.OrderID) && hold.ExpiresAt.After(now) {
+				active = append(active, hold)
+			}
+		}
+		return active, nil
+	}
+	return repository
+}
+
+func TestValidateHolds(t *testing.T) {
+	day := 24 * time.Hour
+	repository := holding(
+		storageWith(
+			models.Order{ID: "1", UserID: "10", StorageUntil: testNow.Add(day)},
+			models.Order{ID: "2", UserID: "10", Issued: true, IssuedAt: testNow.Add(-time.Hour)},
+			models.Order{ID: "3", UserID: "10", StorageUntil: testNow.Add(-day), CourierID: testCourier},
+			models.Order{ID: "4", UserID: "10", StorageUntil: testNow.Add(-day), CourierID: testCourier},
+			models.Order{ID: "5", UserID: "10", StorageUntil: testNow.Add(day)},
+		),
+		models.Hold{OrderID: "1", Operator: "boris", ExpiresAt: testNow.Add(time.Minute)},
+		models.Hold{OrderID: "2", Operator: "boris", ExpiresAt: testNow.Add(time.Minute)},
+		models.Hold{OrderID: "3", Operator: "boris", ExpiresAt: testNow.Add(time.Minute)},
+		models.Hold{OrderID: "5", Operator: "boris", ExpiresAt: testNow.Add(-time.Second)},
+	)
+	as := func(login string) context.Context {
+		return auth.WithOperator(context.Background(), models.Operator{Login: login, Role: models.RoleClerk})
+	}
+	v := newValidationService(repository)
+
+	tests := []struct {
+		name     string
+		validate func(ctx context.Context) error
+		login    string
+		wantErr  error
+	}{
+		{
+			name:     "issue held by another",
+			validate: func(ctx context.Context) error { _, err := v.ValidateIssue(ctx, []string{"1"}); return err },
+			login:    "anna",
+			wantErr:  util.ErrOrderHeld,
+		},
+		{
+			name:     "issue held by the operator",
+			validate: func(ctx context.Context) error { _, err := v.ValidateIssue(ctx, []string{"1"}); return err },
+			login:    "boris",
+		},
+		{
+			name:     "issue after the hold expired",
+			validate: func(ctx context.Context) error { _, err := v.ValidateIssue(ctx, []string{"5"}); return err },
+			login:    "anna",
+		},
+		{
+			name:     "client return held by another",
+			validate: func(ctx context.Context) error { _, err := v.ValidateAcceptReturn(ctx, "2", "10"); return err },
+			login:    "anna",
+			wantErr:  util.ErrOrderHeld,
+		},
+		{
+			name:     "courier return held by another",
+			validate: func(ctx context.Context) error { return v.ValidateReturnToCourier(ctx, "1") },
+			login:    "anna",
+			wantErr:  util.ErrOrderHeld,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.validate(as(tt.login)); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("manifest leaves held orders", func(t *testing.T) {
+		orders, err := v.ValidateCourierReturn(as("anna"), testCourier)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids := orderIDs(orders); !slices.Equal(ids, []string{"4"}) {
+			t.Errorf("manifest = %v, want the order nobody holds [4]", ids)
+		}
+	})
+}
+
+func TestValidateList(t *testing.T) {
+	tests := []struct {
+		name       string
+		offset     string
+		limit      string
+		wantOffset int
+		wantLimit  int
+		wantField  string
+	}{
+		{name: "valid", offset: "10", limit: "20", wantOffset: 10, wantLimit: 20},
+		{name: "bad offset", offset: "x", limit: "20", wantField: "offset"},
+		{name: "bad limit", offset: "0", limit: "", wantField: "limit"},
+		{name: "largest page", offset: "0", limit: "100", wantLimit: 100},
+		{name: "negative offset", offset: "-1", limit: "10", wantField: "offset"},
+		{name: "zero limit", offset: "0", limit: "0", wantField: "limit"},
+		{name: "negative limit", offset: "0", limit: "-5", wantField: "limit"},
+		{name: "limit over the cap", offset: "0", limit: "101", wantField: "limit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offset, limit, err := newValidationService(&mocks.Storage{}).ValidateList(tt.offset, tt.limit)
+			if len(tt.wantField) > 0 {
+				if !errors.Is(err, util.ErrListParamInvalid) {
+					t.Fatalf("error = %v, want %v", err, util.ErrListParamInvalid)
+				}
+				if field := util.AsError(err).Field; field != tt.wantField {
+					t.Errorf("field = %q, want %q", field, tt.wantField)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if offset != tt.wantOffset || limit != tt.wantLimit {
+				t.Errorf("got %d, %d, want %d, %d", offset, limit, tt.wantOffset, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestValidateTender(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		paid    string
+		prepaid string
+		want    models.Tender
+		wantErr error
+	}{
+		{name: "cash", method: "cash", paid: "150.5", want: models.Tender{Method: models.PaymentCash, Paid: 150.5}},
+		{name: "card without amount", method: "card", want: models.Tender{Method: models.PaymentCard}},
+		{name: "prepaid without method", prepaid: "100", want: models.Tender{Prepaid: 100}},
+		{name: "unknown method", method: "cheque", wantErr: util.ErrPaymentMethodInvalid},
+		{name: "paid not a number", method: "cash", paid: "a lot", wantErr: util.ErrPaidInvalid},
+		{name: "negative paid", method: "cash", paid: "-1", wantErr: util.ErrPaidInvalid},
+		{name: "infinite prepaid", method: "cash", prepaid: "Inf", wantErr: util.ErrPrepaidInvalid},
+		{name: "NaN prepaid", method: "cash", prepaid: "NaN", wantErr: util.ErrPrepaidInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tender, err := newValidationService(&mocks.Storage{}).ValidateTender(tt.method, tt.paid, tt.prepaid)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tender != tt.want {
+				t.Errorf("tender = %+v, want %+v", tender, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSearch(t *testing.T) {
+	day := func(s string) time.Time {
+		d, _ := time.Parse(time.DateOnly, s)
+		return d
+	}
+
+	tests := []struct {
+		name      string
+		params    SearchParams
+		want      models.OrderFilter
+		wantErr   error
+		wantField string
+	}{
+		{
+			name:   "no conditions",
+			params: SearchParams{Offset: "0", Limit: "10"},
+			want:   models.OrderFilter{SortBy: "id", Limit: 10},
+		},
+		{
+			name: "every condition",
+			params: SearchParams{
+				Status: "stored", IDPrefix: "12", UserID: "10", PackageType: "box",
+				StorageFrom: "2024-07-01", StorageTo: "2024-07-31", IssuedFrom: "2024-06-01", IssuedTo: "2024-06-01",
+				PriceMin: "100", PriceMax: "200.5", WeightMin: "1", WeightMax: "1",
+				SortBy: "order_price", Desc: true, Offset: "20", Limit: "10",
+			},
+			want: models.OrderFilter{
+				Status: models.StatusStored, IDPrefix: "12", UserID: "10", PackageType: "box",
+				StorageFrom: day("2024-07-01"), StorageTo: day("2024-08-01"), IssuedFrom: day("2024-06-01"), IssuedTo: day("2024-06-02"),
+				PriceMin: 100, PriceMax: 200.5, WeightMin: 1, WeightMax: 1,
+				SortBy: "order_price", Desc: true, Offset: 20, Limit: 10,
+			},
+		},
+		{
+			name:   "open ranges",
+			params: SearchParams{StorageTo: "2024-07-31", PriceMin: "100", Offset: "0", Limit: "10"},
+			want:   models.OrderFilter{StorageTo: day("2024-08-01"), PriceMin: 100, SortBy: "id", Limit: 10},
+		},
+		{name: "unknown status", params: SearchParams{Status: "lost"}, wantErr: util.ErrStatusInvalid},
+		{name: "unknown package", params: SearchParams{PackageType: "envelope"}, wantErr: util.ErrPackageTypeInvalid},
+		{name: "unknown sort column", params: SearchParams{SortBy: "hash; DROP TABLE orders"}, wantErr: util.ErrSortColumnInvalid},
+		{name: "bad date", params: SearchParams{IssuedTo: "31.07.2024"}, wantErr: util.ErrDateInvalid, wantField: "issued_to"},
+		{name: "dates reversed", params: SearchParams{StorageFrom: "2024-08-01", StorageTo: "2024-07-31"}, wantErr: util.ErrRangeInvalid, wantField: "until_from"},
+		{name: "bad price", params: SearchParams{PriceMax: "a lot"}, wantErr: util.ErrOrderPriceInvalid, wantField: "price_max"},
+		{name: "negative price", params: SearchParams{PriceMin: "-1"}, wantErr: util.ErrOrderPriceInvalid, wantField: "price_min"},
+		{name: "prices reversed", params: SearchParams{PriceMin: "200", PriceMax: "100"}, wantErr: util.ErrRangeInvalid, wantField: "price_min"},
+		{name: "bad weight", params: SearchParams{WeightMin: "NaN"}, wantErr: util.ErrWeightInvalid, wantField: "w_min"},
+		{name: "weights reversed", params: SearchParams{WeightMin: "5", WeightMax: "1"}, wantErr: util.ErrRangeInvalid, wantField: "w_min"},
+		{name: "bad limit", params: SearchParams{Offset: "0", Limit: "all"}, wantErr: util.ErrListParamInvalid, wantField: "limit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := newValidationService(&mocks.Storage{}).ValidateSearch(tt.params)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if len(tt.wantField) > 0 {
+				if field := util.AsError(err).Field; field != tt.wantField {
+					t.Errorf("field = %q, want %q", field, tt.wantField)
+				}
+			}
+			if err == nil && filter != tt.want {
+				t.Errorf("filter = %+v, want %+v", filter, tt.want)
+			}
+		})
+	}
+}
+
+// FuzzValidateAccept feeds arbitrary input to the parsing, an accepted order must be one the pickup point can keep
+// and a rejection must be an invalid argument error
+func FuzzValidateAccept(f *testing.F) {
+	f.Add("1", "10", "2024-07-02", "100", "5", "box")
+	f.Add("1", "10", "2024-07-02", "0.01", "9.99", "packet")
+	f.Add("1", "10", "2099-12-31", "1e9", "1e3", "film")
+	f.Add("1", "10", "2024-07-01", "100", "5", "box")
+	f.Add("", "", "", "", "", "")
+	f.Add("1", "10", "2024-13-40", "-1", "NaN", "box")
+	f.Add("1", "10", "2024-07-02", "Inf", "5", "film")
+
+	packages := pkg.NewPackageService()
+	f.Fuzz(func(t *testing.T, id, userID, date, price, weight, pkgType string) {
+		// Customers come and go with the input, only its parsing is fuzzed
+		v := newValidationService(storageWith())
+		v.autoCreateUsers = true
+
+		order, err := v.ValidateAccept(context.Background(), id, userID, date, price, weight, pkgType, "")
+		if err != nil {
+			var domainErr *util.Error
+			if !errors.As(err, &domainErr) {
+				t.Fatalf("error %v is not a domain error", err)
+			}
+			if domainErr.Code != util.CodeInvalidArgument {
+				t.Fatalf("error %v has code %s", err, domainErr.Code)
+			}
+			return
+		}
+
+		if order.ID != id || order.UserID != userID {
+			t.Errorf("order %+v does not keep the ids %q, %q", *order, id, userID)
+		}
+		if !(order.OrderPrice > 0) || math.IsInf(float64(order.OrderPrice), 0) {
+			t.Errorf("accepted price %v", order.OrderPrice)
+		}
+		if !(order.Weight > 0) || math.IsInf(float64(order.Weight), 0) {
+			t.Errorf("accepted weight %v", order.Weight)
+		}
+		if order.StorageUntil.Before(testNow) {
+			t.Errorf("accepted storage date %s in the past", order.StorageUntil)
+		}
+		if err = packages.ValidatePackage(order.Weight, models.PackageType(pkgType)); err != nil {
+			t.Errorf("accepted %v kg in %q: %v", order.Weight, pkgType, err)
+		}
+	})
+}
@@ -0,0 +1,152 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"homework/internal/auth"
+	"homework/internal/models"
+	"homework/internal/util"
+	"log/slog"
+	"time"
+)
+
+const insertCourierQuery = `
+		INSERT INTO couriers (id, name, phone, created_at)
+		VALUES ($1, $2, $3, $4)
+		`
+
+func (r *Repository) InsertCourier(ctx context.Context, courier models.Courier) error {
+	_, err := r.pool.Exec(ctx, insertCourierQuery, courier.ID, courier.Name, courier.Phone, courier.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return util.ErrCourierExists
+		}
+		logQueryError(ctx, "InsertCourier", err)
+		return storageError(err)
+	}
+	return nil
+}
+
+const getCourierQuery = `
+		SELECT id, name, phone, created_at FROM couriers
+		WHERE id=$1
+		`
+
+func (r *Repository) GetCourier(ctx context.Context, id string) (models.Courier, error) {
+	var courier models.Courier
+	if err := pgxscan.Get(ctx, r.pool, &courier, getCourierQuery, id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Courier{}, util.ErrCourierNotFound
+		}
+		logQueryError(ctx, "GetCourier", err)
+		return models.Courier{}, storageError(err)
+	}
+	return courier, nil
+}
+
+const getCourierReturnableQuery = `
+		SELECT id, user_id, storage_until, issued, issued_at, returned, order_price, weight, package_type, package_price, hash, COALESCE(cell_id, '') AS cell_id, COALESCE(courier_id, '') AS courier_id
+		FROM orders
+		WHERE courier_id = $1 AND (returned = TRUE OR (issued = FALSE AND storage_until < $2))
+		ORDER BY id
+	`
+
+// GetCourierReturnable returns the orders of the courier that go back to them at now, see models.Order.ReturnableToCourier
+func (r *Repository) GetCourierReturnable(ctx context.Context, courierID string, now time.Time) ([]models.Order, error) {
+	rows, err := r.pool.Query(ctx, getCourierReturnableQuery, courierID, now)
+	if err != nil {
+		logQueryError(ctx, "GetCourierReturnable", err)
+		return nil, storageError(err)
+	}
+	defer rows.Close()
+
+	var orders []models.Order
+	if err := pgxscan.ScanAll(&orders, rows); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// ReturnToCourier deletes the orders of the manifest and records it, all in one transaction.
+// It locks the orders first, like IssueUpdate, and fails with ErrManifestStale when one of the orders
+// was issued, returned to the courier or prolonged since the manifest was built
+func (r *Repository) ReturnToCourier(ctx context.Context, manifest *models.Manifest) error {
+	return r.withRetry(ctx, "ReturnToCourier", func(ctx context.Context) error {
+		return r.returnToCourier(ctx, manifest)
+	})
+}
+
+const deleteReturnableQuery = `
+		DELETE FROM orders
+		WHERE id = $1 AND courier_id = $2 AND (returned = TRUE OR (issued = FALSE AND storage_until < $3))
+		RETURNING id, user_id, storage_until, issued, issued_at, returned, order_price, weight, package_type, package_price, hash, COALESCE(cell_id, '') AS cell_id, COALESCE(courier_id, '') AS courier_id
+		`
+
+const insertManifestQuery = `
+		INSERT INTO courier_manifests (courier_id, operator, created_at, orders)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+		`
+
+func (r *Repository) returnToCourier(ctx context.Context, manifest *models.Manifest) error {
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{
+		IsoLevel:   pgx.ReadCommitted,
+		AccessMode: pgx.ReadWrite,
+	})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	actor := auth.Actor(ctx)
+	ids := make([]string, 0, len(manifest.Orders))
+	for _, order := range manifest.Orders {
+		ids = append(ids, order.ID)
+	}
+	if err = checkHolds(ctx, tx, ids, actor, manifest.CreatedAt); err != nil {
+		return err
+	}
+
+	for _, order := range manifest.Orders {
+		if _, err = tx.Exec(ctx, releaseCellQuery, order.ID); err != nil {
+			return err
+		}
+
+		var deleted models.Order
+		if err = pgxscan.Get(ctx, tx, &deleted, deleteReturnableQuery, order.ID, manifest.CourierID, manifest.CreatedAt); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return util.ErrManifestStale.Wrap(fmt.Errorf("order %s", order.ID))
+			}
+			logQueryError(ctx, "ReturnToCourier", err)
+			return err
+		}
+
+		if _, err = tx.Exec(ctx, auditQuery, order.ID, auditReturnToCourier, actor); err != nil {
+			return err
+		}
+		if err = enqueue(ctx, tx, order.ID, models.OrderReturnedToCourier, deleted); err != nil {
+			return err
+		}
+	}
+
+	orders, err := json.Marshal(manifest.Orders)
+	if err != nil {
+		return fmt.Errorf("encoding manifest: %w", err)
+	}
+	if err = tx.QueryRow(ctx, insertManifestQuery, manifest.CourierID, manifest.Operator, manifest.CreatedAt, string(orders)).Scan(&manifest.ID); err != nil {
+		logQueryError(ctx, "ReturnToCourier", err)
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "orders returned to courier", "courier_id", manifest.CourierID, "manifest_id", manifest.ID, "orders", len(manifest.Orders), "operator", actor)
+	return nil
+}
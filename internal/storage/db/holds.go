@@ -0,0 +1,169 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"homework/internal/models"
+	"homework/internal/util"
+	"log/slog"
+	"time"
+)
+
+// foreignKeyViolation is the SQLSTATE postgres reports for a reference to a missing row
+const foreignKeyViolation = "23503"
+
+const holdColumns = `order_id, operator, created_at, expires_at`
+
+// placeHoldQuery takes a free or expired hold, or extends the operator's own one, a hold of someone else
+// returns no row
+const placeHoldQuery = `
+		INSERT INTO holds (order_id, operator, created_at, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (order_id) DO UPDATE
+		SET operator = EXCLUDED.operator, created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at
+		WHERE holds.operator = EXCLUDED.operator OR holds.expires_at <= EXCLUDED.created_at
+		RETURNING order_id
+		`
+
+// PlaceHolds holds all the orders or none of them, ErrOrderHeld tells that another operator holds one
+func (r *Repository) PlaceHolds(ctx context.Context, holds []models.Hold) error {
+	return r.withRetry(ctx, "PlaceHolds", func(ctx context.Context) error {
+		return r.placeHolds(ctx, holds)
+	})
+}
+
+func (r *Repository) placeHolds(ctx context.Context, holds []models.Hold) error {
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{
+		IsoLevel:   pgx.ReadCommitted,
+		AccessMode: pgx.ReadWrite,
+	})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	ids := make([]string, 0, len(holds))
+	for _, hold := range holds {
+		ids = append(ids, hold.OrderID)
+	}
+	if err = lockOrders(ctx, tx, ids); err != nil {
+		return err
+	}
+
+	for _, hold := range holds {
+		var orderID string
+		err = tx.QueryRow(ctx, placeHoldQuery, hold.OrderID, hold.Operator, hold.CreatedAt, hold.ExpiresAt).Scan(&orderID)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			switch {
+			case errors.Is(err, pgx.ErrNoRows):
+				return util.ErrOrderHeld.Wrap(fmt.Errorf("order %s", hold.OrderID))
+			case errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation:
+				return util.ErrOrderNotFound.Wrap(fmt.Errorf("order %s", hold.OrderID))
+			}
+			logQueryError(ctx, "PlaceHolds", err)
+			return err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return err
+	}
+	for _, hold := range holds {
+		slog.InfoContext(ctx, "order held", "order_id", hold.OrderID, "operator", hold.Operator, "expires_at", hold.ExpiresAt)
+	}
+	return nil
+}
+
+// lockOrdersQuery takes the rows of the orders, a hold can't be placed on an order while it is issued,
+// returned or handed back and the other way round. The id order keeps two transactions from deadlocking
+const lockOrdersQuery = `SELECT id FROM orders WHERE id = ANY($1) ORDER BY id FOR UPDATE`
+
+// lockOrders locks the rows of the orders until the end of tx, missing orders are skipped
+func lockOrders(ctx context.Context, tx pgx.Tx, ids []string) error {
+	if _, err := tx.Exec(ctx, lockOrdersQuery, ids); err != nil {
+		logQueryError(ctx, "lockOrders", err)
+		return err
+	}
+	return nil
+}
+
+// heldByOthersQuery runs in every transaction that issues, returns or hands back orders, the validation
+// checked the holds before the transaction and another operator may have placed one since.
+// A hold inserted for the first time has no row to lock, so checkHolds locks the orders before
+const heldByOthersQuery = `
+		SELECT order_id FROM holds
+		WHERE order_id = ANY($1) AND operator <> $2 AND expires_at > $3
+		LIMIT 1
+		FOR UPDATE
+		`
+
+// checkHolds locks the orders and fails with ErrOrderHeld when another operator than actor holds one of them at now.
+// It has to be the first query of a read committed tx, the locks then make the check see every committed hold
+func checkHolds(ctx context.Context, tx pgx.Tx, ids []string, actor string, now time.Time) error {
+	if err := lockOrders(ctx, tx, ids); err != nil {
+		return err
+	}
+	var held string
+	err := tx.QueryRow(ctx, heldByOthersQuery, ids, actor, now).Scan(&held)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil
+	case err != nil:
+		logQueryError(ctx, "checkHolds", err)
+		return err
+	}
+	return util.ErrOrderHeld.Wrap(fmt.Errorf("order %s", held))
+}
+
+const releaseHoldsQuery = `DELETE FROM holds WHERE order_id = ANY($1) AND operator = $2`
+
+// ReleaseHolds drops the holds the operator has on the orders, holds of others stay
+func (r *Repository) ReleaseHolds(ctx context.Context, ids []string, operator string) error {
+	if _, err := r.pool.Exec(ctx, releaseHoldsQuery, ids, operator); err != nil {
+		logQueryError(ctx, "ReleaseHolds", err)
+		return storageError(err)
+	}
+	return nil
+}
+
+const getHoldsQuery = `
+		SELECT ` + holdColumns + ` FROM holds
+		WHERE expires_at > $1
+		ORDER BY expires_at, order_id
+		`
+
+// GetHolds returns the holds active at now, the expired ones are left for placeHoldQuery to replace
+func (r *Repository) GetHolds(ctx context.Context, now time.Time) ([]models.Hold, error) {
+	return r.holds(ctx, "GetHolds", getHoldsQuery, now)
+}
+
+const getOrderHoldsQuery = `
+		SELECT ` + holdColumns + ` FROM holds
+		WHERE order_id = ANY($1) AND expires_at > $2
+		ORDER BY order_id
+		`
+
+// GetOrderHolds returns the holds active at now on the given orders
+func (r *Repository) GetOrderHolds(ctx context.Context, ids []string, now time.Time) ([]models.Hold, error) {
+	return r.holds(ctx, "GetOrderHolds", getOrderHoldsQuery, ids, now)
+}
+
+func (r *Repository) holds(ctx context.Context, op, query string, args ...any) ([]models.Hold, error) {
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		logQueryError(ctx, op, err)
+		return nil, storageError(err)
+	}
+	defer rows.Close()
+
+	var holds []models.Hold
+	if err := pgxscan.ScanAll(&holds, rows); err != nil {
+		return nil, err
+	}
+	return holds, nil
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"homework/internal/models"
+	"time"
+)
+
+const idempotencyColumns = `operator, key, command, fingerprint, status, response, error_code, error_field, error_message, created_at, expires_at`
+
+const claimIdempotencyKeyQuery = `
+		INSERT INTO idempotency_keys (operator, key, command, fingerprint, status, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (operator, key) DO UPDATE
+		SET command = EXCLUDED.command, fingerprint = EXCLUDED.fingerprint, status = EXCLUDED.status, response = NULL,
+			error_code = '', error_field = '', error_message = '', created_at = now(), expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= now()
+			OR (idempotency_keys.status = $5 AND idempotency_keys.created_at <= now() - $7 * interval '1 millisecond')
+		RETURNING ` + idempotencyColumns
+
+const getIdempotencyKeyQuery = `SELECT ` + idempotencyColumns + ` FROM idempotency_keys WHERE operator = $1 AND key = $2`
+
+// ClaimIdempotencyKey stores a pending record and reports true, or returns the record already kept under the key
+// and false. An expired record is replaced as if it was never there, so is a record left pending for longer than lease,
+// the process that claimed it is taken for dead
+func (r *Repository) ClaimIdempotencyKey(ctx context.Context, record models.IdempotencyRecord, lease time.Duration) (models.IdempotencyRecord, bool, error) {
+	var claimed models.IdempotencyRecord
+	err := pgxscan.Get(ctx, r.pool, &claimed, claimIdempotencyKeyQuery, record.Operator, record.Key, record.Command, record.Fingerprint,
+		models.IdempotencyPending, record.ExpiresAt, lease.Milliseconds())
+	if err == nil {
+		return claimed, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		logQueryError(ctx, "ClaimIdempotencyKey", err)
+		return models.IdempotencyRecord{}, false, storageError(err)
+	}
+
+	var existing models.IdempotencyRecord
+	if err = pgxscan.Get(ctx, r.pool, &existing, getIdempotencyKeyQuery, record.Operator, record.Key); err != nil {
+		logQueryError(ctx, "ClaimIdempotencyKey", err)
+		return models.IdempotencyRecord{}, false, storageError(err)
+	}
+	return existing, false, nil
+}
+
+// completeIdempotencyKeyQuery is fenced by created_at, a claim taken over after its lease changes no row
+const completeIdempotencyKeyQuery = `
+		UPDATE idempotency_keys SET status = $1, response = $2, error_code = $3, error_field = $4, error_message = $5
+		WHERE operator = $6 AND key = $7 AND created_at = $8 AND status = $9
+		`
+
+// CompleteIdempotencyKey stores the outcome of the command under the claim of record, it reports false
+// when the claim was lost to another request after its lease
+func (r *Repository) CompleteIdempotencyKey(ctx context.Context, record models.IdempotencyRecord) (bool, error) {
+	var response any
+	if len(record.Response) > 0 {
+		response = string(record.Response)
+	}
+	tag, err := r.pool.Exec(ctx, completeIdempotencyKeyQuery, models.IdempotencyDone, response, record.ErrorCode, record.ErrorField, record.ErrorMessage,
+		record.Operator, record.Key, record.CreatedAt, models.IdempotencyPending)
+	if err != nil {
+		logQueryError(ctx, "CompleteIdempotencyKey", err)
+		return false, storageError(err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+const (
+	releaseIdempotencyKeyQuery        = `DELETE FROM idempotency_keys WHERE operator = $1 AND key = $2 AND created_at = $3 AND status = $4`
+	deleteExpiredIdempotencyKeysQuery = `DELETE FROM idempotency_keys WHERE expires_at <= now()`
+)
+
+// ReleaseIdempotencyKey forgets the key claimed by record, so the command can be tried again with it,
+// like CompleteIdempotencyKey it reports false for a lost claim and leaves the key alone
+func (r *Repository) ReleaseIdempotencyKey(ctx context.Context, record models.IdempotencyRecord) (bool, error) {
+	tag, err := r.pool.Exec(ctx, releaseIdempotencyKeyQuery, record.Operator, record.Key, record.CreatedAt, models.IdempotencyPending)
+	if err != nil {
+		logQueryError(ctx, "ReleaseIdempotencyKey", err)
+		return false, storageError(err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// DeleteExpiredIdempotencyKeys removes the records past their TTL
+func (r *Repository) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	tag, err := r.pool.Exec(ctx, deleteExpiredIdempotencyKeysQuery)
+	if err != nil {
+		logQueryError(ctx, "DeleteExpiredIdempotencyKeys", err)
+		return 0, storageError(err)
+	}
+	return tag.RowsAffected(), nil
+}
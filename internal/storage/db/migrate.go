@@ -0,0 +1,328 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	upMarker   = "-- +goose Up"
+	downMarker = "-- +goose Down"
+)
+
+// Migration is one SQL file from the migrations directory, named <version>_<name>.sql
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies the embedded migrations and keeps track of them in schema_migrations
+type Migrator struct {
+	pool       *pgxpool.Pool
+	migrations []Migration
+}
+
+func NewMigrator(pool *pgxpool.Pool, migrationsFS fs.FS) (*Migrator, error) {
+	migrations, err := parseMigrations(migrationsFS)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{pool: pool, migrations: migrations}, nil
+}
+
+func parseMigrations(migrationsFS fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(migrationsFS, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]Migration, 0, len(files))
+	for _, file := range files {
+		base := strings.TrimSuffix(path.Base(file), ".sql")
+		versionStr, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: expected <version>_<name>.sql", file)
+		}
+		version, err := strconv.ParseInt(versionStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version: %w", file, err)
+		}
+
+		content, err := fs.ReadFile(migrationsFS, file)
+		if err != nil {
+			return nil, err
+		}
+		up, down, err := splitMigration(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", file, err)
+		}
+
+		migrations = append(migrations, Migration{Version: version, Name: name, Up: up, Down: down})
+	}
+
+	sort.Slice(migrations, func(i, k int) bool {
+		return migrations[i].Version < migrations[k].Version
+	})
+	return migrations, nil
+}
+
+// splitMigration separates the Up and Down sections, the statement markers are kept as comments,
+// each section runs as one multi-statement Exec
+func splitMigration(content string) (string, string, error) {
+	upIdx := strings.Index(content, upMarker)
+	if upIdx < 0 {
+		return "", "", errors.New("no " + upMarker + " section")
+	}
+	downIdx := strings.Index(content, downMarker)
+	if downIdx < 0 {
+		return strings.TrimSpace(content[upIdx+len(upMarker):]), "", nil
+	}
+	if downIdx < upIdx {
+		return "", "", errors.New(downMarker + " must follow " + upMarker)
+	}
+	return strings.TrimSpace(content[upIdx+len(upMarker) : downIdx]), strings.TrimSpace(content[downIdx+len(downMarker):]), nil
+}
+
+// Latest is the version the binary expects the schema to be at
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the last applied migration, 0 for an empty database
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	if err := m.ensureVersionTable(ctx); err != nil {
+		return 0, err
+	}
+
+	var version int64
+	err := m.pool.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	return version, err
+}
+
+// undefinedTable is the SQLSTATE postgres reports for a missing table
+const undefinedTable = "42P01"
+
+// AppliedVersion is Version without the DDL, the readiness probe calls it on every request.
+// A database schema_migrations was never created in is at version 0
+func (m *Migrator) AppliedVersion(ctx context.Context) (int64, error) {
+	var version int64
+	err := m.pool.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == undefinedTable {
+		return 0, nil
+	}
+	return version, err
+}
+
+// Check fails when migrations bundled with the binary are not applied
+func (m *Migrator) Check(ctx context.Context) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	var pending []string
+	for _, status := range statuses {
+		if !status.Applied {
+			pending = append(pending, strconv.FormatInt(status.Version, 10))
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("database schema is outdated, pending migrations: %s, run `migrate up`", strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		appliedAt, ok := applied[migration.Version]
+		statuses = append(statuses, MigrationStatus{Migration: migration, Applied: ok, AppliedAt: appliedAt})
+	}
+	return statuses, nil
+}
+
+// migrateLock is the advisory lock key held while migrating
+const migrateLock = 0x6d696772617465
+
+// locked runs fn holding migrateLock, a second binary migrating at the same time waits for the first
+// and then sees its migrations applied. The lock is a session one, it's held by a connection of its own
+func (m *Migrator) locked(ctx context.Context, fn func(ctx context.Context) error) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err = conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrateLock); err != nil {
+		return fmt.Errorf("taking the migration lock: %w", err)
+	}
+	defer func() {
+		// Unlocked even when ctx is done, a connection that still holds it goes back to the pool
+		if _, err := conn.Exec(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, migrateLock); err != nil {
+			slog.ErrorContext(ctx, "migration lock not released", "err", err)
+			conn.Conn().Close(context.WithoutCancel(ctx))
+		}
+	}()
+
+	return fn(ctx)
+}
+
+// Up applies every pending migration, each one in its own transaction
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.locked(ctx, m.up)
+}
+
+func (m *Migrator) up(ctx context.Context) error {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		err = m.apply(ctx, migration.Version, migration.Up, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, migration.Name)
+		if err != nil {
+			return fmt.Errorf("migration %d_%s up: %w", migration.Version, migration.Name, err)
+		}
+		slog.InfoContext(ctx, "migration applied", "version", migration.Version, "name", migration.Name)
+	}
+	return nil
+}
+
+// Down rolls back the last applied migration
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.locked(ctx, m.down)
+}
+
+func (m *Migrator) down(ctx context.Context) error {
+	version, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if version == 0 {
+		return errors.New("no migrations to roll back")
+	}
+
+	for _, migration := range m.migrations {
+		if migration.Version != version {
+			continue
+		}
+		err = m.apply(ctx, migration.Version, migration.Down, `DELETE FROM schema_migrations WHERE version = $1`)
+		if err != nil {
+			return fmt.Errorf("migration %d_%s down: %w", migration.Version, migration.Name, err)
+		}
+		slog.InfoContext(ctx, "migration rolled back", "version", migration.Version, "name", migration.Name)
+		return nil
+	}
+	return fmt.Errorf("migration %d is applied but not bundled with this binary", version)
+}
+
+func (m *Migrator) apply(ctx context.Context, version int64, sql, bookkeeping string, args ...any) error {
+	tx, err := m.pool.BeginTx(ctx, pgx.TxOptions{
+		IsoLevel:   pgx.ReadCommitted,
+		AccessMode: pgx.ReadWrite,
+	})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if len(sql) > 0 {
+		if _, err = tx.Exec(ctx, sql); err != nil {
+			return err
+		}
+	}
+	if _, err = tx.Exec(ctx, bookkeeping, append([]any{version}, args...)...); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int64]time.Time, error) {
+	if err := m.ensureVersionTable(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.pool.Query(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err = rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// ensureVersionTable creates schema_migrations, on databases set up by goose it takes over goose's history
+func (m *Migrator) ensureVersionTable(ctx context.Context) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL DEFAULT '',
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)
+		`
+	if _, err := m.pool.Exec(ctx, query); err != nil {
+		return err
+	}
+
+	adoptQuery := `
+		INSERT INTO schema_migrations (version, applied_at)
+		SELECT version_id, tstamp FROM (
+			SELECT DISTINCT ON (version_id) version_id, is_applied, tstamp
+			FROM goose_db_version
+			WHERE version_id > 0
+			ORDER BY version_id, id DESC
+		) AS goose
+		WHERE is_applied AND NOT EXISTS (SELECT 1 FROM schema_migrations)
+		`
+
+	var gooseTable *string
+	if err := m.pool.QueryRow(ctx, `SELECT to_regclass('goose_db_version')::text`).Scan(&gooseTable); err != nil {
+		return err
+	}
+	if gooseTable == nil {
+		return nil
+	}
+	_, err := m.pool.Exec(ctx, adoptQuery)
+	return err
+}
@@ -0,0 +1,98 @@
+package db
+
+import (
+	"context"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"homework/internal/models"
+	"time"
+)
+
+const getExpiringQuery = `
+		SELECT id, user_id, storage_until, issued, issued_at, returned, order_price, weight, package_type, package_price, hash, COALESCE(cell_id, '') AS cell_id, COALESCE(courier_id, '') AS courier_id
+		FROM orders
+		WHERE issued = FALSE AND returned = FALSE AND storage_until >= $1 AND storage_until < $2
+		ORDER BY storage_until
+	`
+
+// GetExpiring returns the orders waiting in the pickup point whose storage ends between from and to
+func (r *Repository) GetExpiring(ctx context.Context, from, to time.Time) ([]models.Order, error) {
+	var orders []models.Order
+	if err := pgxscan.Select(ctx, r.pool, &orders, getExpiringQuery, from, to); err != nil {
+		logQueryError(ctx, "GetExpiring", err)
+		return nil, storageError(err)
+	}
+	return orders, nil
+}
+
+const claimNotificationQuery = `
+		INSERT INTO notifications (order_id, user_id, event, channel, message, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (order_id, event, channel) DO UPDATE
+		SET status = EXCLUDED.status, attempts = notifications.attempts + 1, error = '', claimed_at = now()
+		WHERE notifications.status = $7
+			OR (notifications.status = $6 AND notifications.claimed_at <= now() - $8 * interval '1 millisecond')
+		`
+
+// ClaimNotification records a pending notification and reports whether it should be sent:
+// a new one, a failed one or one left pending for longer than lease is claimed, a pending or sent one is a duplicate
+func (r *Repository) ClaimNotification(ctx context.Context, notification models.Notification, lease time.Duration) (bool, error) {
+	tag, err := r.pool.Exec(ctx, claimNotificationQuery,
+		notification.OrderID, notification.UserID, notification.Event, notification.Channel, notification.Message,
+		models.NotificationPending, models.NotificationFailed, lease.Milliseconds())
+	if err != nil {
+		logQueryError(ctx, "ClaimNotification", err)
+		return false, storageError(err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+const updateNotificationQuery = `
+		UPDATE notifications SET status = $1, error = $2, sent_at = $3
+		WHERE order_id = $4 AND event = $5 AND channel = $6
+		`
+
+// UpdateNotification stores the delivery result
+func (r *Repository) UpdateNotification(ctx context.Context, notification models.Notification) error {
+	_, err := r.pool.Exec(ctx, updateNotificationQuery, notification.Status, notification.Error, notification.SentAt, notification.OrderID, notification.Event, notification.Channel)
+	if err != nil {
+		logQueryError(ctx, "UpdateNotification", err)
+		return storageError(err)
+	}
+	return nil
+}
+
+const getNotificationsQuery = `
+		SELECT order_id, user_id, event, channel, message, status, attempts, error, created_at, claimed_at, sent_at
+		FROM notifications
+		WHERE order_id = $1
+		ORDER BY created_at, channel
+	`
+
+func (r *Repository) GetNotifications(ctx context.Context, orderID string) ([]models.Notification, error) {
+	var notifications []models.Notification
+	if err := pgxscan.Select(ctx, r.pool, &notifications, getNotificationsQuery, orderID); err != nil {
+		logQueryError(ctx, "GetNotifications", err)
+		return nil, storageError(err)
+	}
+	return notifications, nil
+}
+
+const getFailedNotificationsQuery = `
+		SELECT order_id, user_id, event, channel, message, status, attempts, error, created_at, claimed_at, sent_at
+		FROM notifications
+		WHERE attempts < $2 AND (status = $1 OR (status = $3 AND claimed_at <= now() - $4 * interval '1 millisecond'))
+		ORDER BY created_at
+		LIMIT $5
+	`
+
+// GetFailedNotifications returns the oldest failed deliveries that still have attempts left. A notification pending
+// for longer than lease is failed too, the process sending it died before it recorded the outcome
+func (r *Repository) GetFailedNotifications(ctx context.Context, maxAttempts int, lease time.Duration, limit int) ([]models.Notification, error) {
+	var notifications []models.Notification
+	if err := pgxscan.Select(ctx, r.pool, &notifications, getFailedNotificationsQuery,
+		models.NotificationFailed, maxAttempts, models.NotificationPending, lease.Milliseconds(), limit); err != nil {
+		logQueryError(ctx, "GetFailedNotifications", err)
+		return nil, storageError(err)
+	}
+	return notifications, nil
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"homework/internal/models"
+)
+
+// outboxLock is the advisory lock key held by the relay, a second instance skips its turn instead of
+// publishing the same events out of order
+const outboxLock = 0x6f7574626f78
+
+// outboxQuery stores an event, it runs in the transaction of the order change
+const outboxQuery = `
+		INSERT INTO outbox (order_id, event_type, payload)
+		VALUES ($1, $2, $3)
+		`
+
+// outboxArgs renders the arguments of outboxQuery, the payload is the order as the API returns it
+func outboxArgs(orderID string, eventType models.OutboxEventType, payload any) ([]any, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("encoding %s event: %w", eventType, err)
+	}
+	return []any{orderID, eventType, string(data)}, nil
+}
+
+func enqueue(ctx context.Context, tx pgx.Tx, orderID string, eventType models.OutboxEventType, payload any) error {
+	args, err := outboxArgs(orderID, eventType, payload)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(ctx, outboxQuery, args...); err != nil {
+		logQueryError(ctx, "enqueue", err)
+		return err
+	}
+	return nil
+}
+
+const (
+	outboxLockQuery      = `SELECT pg_try_advisory_xact_lock($1)`
+	outboxPublishedQuery = `UPDATE outbox SET published_at = now(), attempts = attempts + 1, last_error = '' WHERE id = $1`
+	outboxFailedQuery    = `UPDATE outbox SET attempts = attempts + 1, last_error = $2 WHERE id = $1`
+)
+
+const unpublishedOutboxQuery = `
+		SELECT id, order_id, event_type, payload, created_at, published_at, attempts, last_error
+		FROM outbox
+		WHERE published_at IS NULL
+		ORDER BY id
+		LIMIT $1
+	`
+
+// ProcessOutbox hands up to limit unpublished events to publish in id order and records the outcome,
+// an event publish returned no error for is marked published. The events stay locked until publish returns,
+// if the transaction is lost they are published again, so delivery is at least once.
+// It returns 0 events without calling publish while another relay holds the outbox.
+func (r *Repository) ProcessOutbox(ctx context.Context, limit int, publish func(ctx context.Context, events []models.OutboxEvent) map[int64]error) (int, error) {
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{
+		IsoLevel:   pgx.ReadCommitted,
+		AccessMode: pgx.ReadWrite,
+	})
+	if err != nil {
+		return 0, storageError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	var locked bool
+	if err = tx.QueryRow(ctx, outboxLockQuery, outboxLock).Scan(&locked); err != nil {
+		logQueryError(ctx, "ProcessOutbox", err)
+		return 0, storageError(err)
+	}
+	if !locked {
+		return 0, nil
+	}
+
+	var events []models.OutboxEvent
+	if err = pgxscan.Select(ctx, tx, &events, unpublishedOutboxQuery, limit); err != nil {
+		logQueryError(ctx, "ProcessOutbox", err)
+		return 0, storageError(err)
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	results := publish(ctx, events)
+
+	batch := &pgx.Batch{}
+	published := 0
+	for id, publishErr := range results {
+		if publishErr == nil {
+			batch.Queue(outboxPublishedQuery, id)
+			published++
+			continue
+		}
+		batch.Queue(outboxFailedQuery, id, publishErr.Error())
+	}
+	// The events are out already, record it even if the relay is being stopped
+	ctx = context.WithoutCancel(ctx)
+	if err = tx.SendBatch(ctx, batch).Close(); err != nil {
+		logQueryError(ctx, "ProcessOutbox", err)
+		return 0, storageError(err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return 0, storageError(err)
+	}
+	return published, nil
+}
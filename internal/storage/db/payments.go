@@ -0,0 +1,61 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"homework/internal/models"
+	"homework/internal/util"
+)
+
+// insertPaymentQuery records the payment and links it to every order it paid for,
+// the order ids come as one array
+const insertPaymentQuery = `
+		WITH payment AS (
+			INSERT INTO payments (user_id, operator, created_at, method, total, prepaid, due, paid, change_given, lines)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			RETURNING id
+		), linked AS (
+			INSERT INTO payment_orders (payment_id, order_id)
+			SELECT payment.id, order_id FROM payment, unnest($11::VARCHAR[]) AS order_id
+		)
+		SELECT id FROM payment
+		`
+
+func insertPaymentArgs(payment models.Payment, orders []models.Order) ([]any, error) {
+	lines, err := json.Marshal(payment.Lines)
+	if err != nil {
+		return nil, fmt.Errorf("encoding receipt lines: %w", err)
+	}
+	ids := make([]string, 0, len(orders))
+	for _, order := range orders {
+		ids = append(ids, order.ID)
+	}
+	return []any{payment.UserID, payment.Operator, payment.CreatedAt, payment.Method, payment.Total, payment.Prepaid,
+		payment.Due, payment.Paid, payment.Change, string(lines), ids}, nil
+}
+
+// getOrderPaymentQuery finds the one payment of the order, payment_orders_order_id is unique
+const getOrderPaymentQuery = `
+		SELECT payments.id, user_id, operator, created_at, method, total, prepaid, due, paid, change_given, lines
+		FROM payments
+		JOIN payment_orders ON payment_orders.payment_id = payments.id
+		WHERE payment_orders.order_id = $1
+		`
+
+// GetOrderPayment returns the payment the order was issued with, ErrPaymentNotFound for an order
+// issued before payments were recorded
+func (r *Repository) GetOrderPayment(ctx context.Context, orderID string) (models.Payment, error) {
+	var payment models.Payment
+	if err := pgxscan.Get(ctx, r.pool, &payment, getOrderPaymentQuery, orderID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Payment{}, util.ErrPaymentNotFound
+		}
+		logQueryError(ctx, "GetOrderPayment", err)
+		return models.Payment{}, storageError(err)
+	}
+	return payment, nil
+}
@@ -8,144 +8,502 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"homework/internal/auth"
+	"homework/internal/health"
+	"homework/internal/metrics"
 	"homework/internal/models"
 	"homework/internal/storage"
 	"homework/internal/util"
-	"log"
+	"homework/migrations"
+	"homework/pkg/retry"
+	"log/slog"
+	"time"
 )
 
+var _ storage.Storage = (*Repository)(nil)
+
 type Repository struct {
-	pool *pgxpool.Pool
-	ctx  context.Context
+	pool        *pgxpool.Pool
+	migrator    *Migrator
+	retryPolicy retry.Policy
 }
 
-func NewSQLRepository(ctx context.Context, cfg *models.Config) storage.Storage {
+// Connect opens the pool and pings the db, transient failures are retried with backoff
+func Connect(ctx context.Context, cfg *models.Config) (*pgxpool.Pool, error) {
 	connStr := fmt.Sprintf("postgresql://%s:%s@%s:%s/%s?sslmode=disable", cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.DBName)
+	poolCfg, err := pgxpool.ParseConfig(connStr)
+	if err != nil {
+		return nil, fmt.Errorf("parsing db config: %w", err)
+	}
+	if len(cfg.DBSchema) > 0 {
+		poolCfg.ConnConfig.RuntimeParams["search_path"] = cfg.DBSchema
+	}
+
 	var pool *pgxpool.Pool
-	var err error
+	policy := NewRetryPolicy(cfg)
+	policy.OnRetry = func(attempt int, delay time.Duration, err error) {
+		slog.WarnContext(ctx, "db connection error, retrying", "attempt", attempt, "delay", delay, "err", err)
+	}
 
-	err = util.DoWithTries(func() error {
+	err = retry.Do(ctx, policy, func(ctx context.Context) error {
 		ctxTimeout, cancel := context.WithTimeout(ctx, cfg.Timeout)
 		defer cancel()
 
-		pool, err = pgxpool.New(ctxTimeout, connStr)
+		p, err := pgxpool.NewWithConfig(ctxTimeout, poolCfg)
 		if err != nil {
-			log.Fatal(err, "db connection error")
+			return err
+		}
+		if err = p.Ping(ctxTimeout); err != nil {
+			p.Close()
+			// The per-attempt timeout is not a reason to give up
+			if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+				return fmt.Errorf("%w after %s", errConnectTimeout, cfg.Timeout)
+			}
+			return err
 		}
 
+		pool = p
 		return nil
-	}, cfg.Attempts, cfg.Timeout)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("connecting to db: %w", err)
+	}
+	slog.InfoContext(ctx, "connected to db", "host", cfg.Host, "db", cfg.DBName)
+
+	return pool, nil
+}
+
+// NewSQLRepository connects to the db and refuses to work with a schema older than the bundled migrations
+func NewSQLRepository(ctx context.Context, cfg *models.Config) (*Repository, error) {
+	pool, err := Connect(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
 
+	migrator, err := NewMigrator(pool, migrations.FS)
 	if err != nil {
-		log.Fatal(err, "DoWithTries error")
+		pool.Close()
+		return nil, err
+	}
+	if err = migrator.Check(ctx); err != nil {
+		pool.Close()
+		return nil, err
 	}
-	log.Println("Connected to db")
 
 	return &Repository{
-		pool: pool,
-		ctx:  ctx,
-	}
+		pool:        pool,
+		migrator:    migrator,
+		retryPolicy: NewRetryPolicy(cfg),
+	}, nil
 }
 
-func (r *Repository) Insert(order models.Order) error {
-	query := `
-		INSERT INTO orders (id, user_id, storage_until, issued, issued_at, returned, order_price, weight, package_type, package_price, hash) 
-	    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+// Close waits for the connections in use to be released and closes the pool
+func (r *Repository) Close() {
+	r.pool.Close()
+	slog.Info("db pool closed")
+}
+
+// RegisterMetrics exposes the connection pool stats
+func (r *Repository) RegisterMetrics(registry *metrics.Registry) {
+	registry.NewGaugeFunc("pvz_db_pool_acquired_conns", "Connections currently in use.", func() float64 {
+		return float64(r.pool.Stat().AcquiredConns())
+	})
+	registry.NewGaugeFunc("pvz_db_pool_idle_conns", "Idle connections in the pool.", func() float64 {
+		return float64(r.pool.Stat().IdleConns())
+	})
+	registry.NewGaugeFunc("pvz_db_pool_total_conns", "Connections open in the pool.", func() float64 {
+		return float64(r.pool.Stat().TotalConns())
+	})
+	registry.NewGaugeFunc("pvz_db_pool_max_conns", "Maximum size of the pool.", func() float64 {
+		return float64(r.pool.Stat().MaxConns())
+	})
+	registry.NewCounterFunc("pvz_db_pool_acquires_total", "Connections acquired from the pool.", func() float64 {
+		return float64(r.pool.Stat().AcquireCount())
+	})
+	registry.NewCounterFunc("pvz_db_pool_empty_acquires_total", "Acquires that had to wait for a connection.", func() float64 {
+		return float64(r.pool.Stat().EmptyAcquireCount())
+	})
+	registry.NewCounterFunc("pvz_db_pool_canceled_acquires_total", "Acquires canceled by context.", func() float64 {
+		return float64(r.pool.Stat().CanceledAcquireCount())
+	})
+	registry.NewCounterFunc("pvz_db_pool_acquire_seconds_total", "Total time spent acquiring connections.", func() float64 {
+		return r.pool.Stat().AcquireDuration().Seconds()
+	})
+}
+
+// RegisterHealth adds the db reachability and schema version checks
+func (r *Repository) RegisterHealth(checker *health.Checker) {
+	checker.Register("db", func(ctx context.Context) (string, error) {
+		if err := r.pool.Ping(ctx); err != nil {
+			return "", err
+		}
+		stat := r.pool.Stat()
+		return fmt.Sprintf("%d/%d connections in use", stat.AcquiredConns(), stat.MaxConns()), nil
+	})
+	checker.Register("migrations", func(ctx context.Context) (string, error) {
+		version, err := r.migrator.AppliedVersion(ctx)
+		if err != nil {
+			return "", err
+		}
+		if latest := r.migrator.Latest(); version < latest {
+			return "", fmt.Errorf("schema version %d, expected %d", version, latest)
+		}
+		return fmt.Sprintf("version %d", version), nil
+	})
+}
+
+// Insert stores the order and assigns it the first free cell that fits its package and weight
+func (r *Repository) Insert(ctx context.Context, order *models.Order) error {
+	return r.withRetry(ctx, "Insert", func(ctx context.Context) error {
+		return r.insert(ctx, order)
+	})
+}
+
+const insertOrderQuery = `
+		INSERT INTO orders (id, user_id, storage_until, issued, issued_at, returned, order_price, weight, package_type, package_price, hash, cell_id, courier_id) 
+	    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NULLIF($13, ''))
 	    `
 
-	_, err := r.pool.Exec(r.ctx, query, order.ID, order.UserID, order.StorageUntil, order.Issued, order.IssuedAt, order.Returned, order.OrderPrice, order.Weight, order.PackageType, order.PackagePrice, order.Hash)
+func (r *Repository) insert(ctx context.Context, order *models.Order) error {
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{
+		IsoLevel:   pgx.ReadCommitted,
+		AccessMode: pgx.ReadWrite,
+	})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	cellID, err := r.allocateCell(ctx, tx, order)
 	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			log.Println(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s", pgErr.Code, pgErr.Detail, pgErr.Where))
-		}
 		return err
 	}
+	order.CellID = cellID
+
+	if _, err = tx.Exec(ctx, ensureUserQuery, order.UserID); err != nil {
+		logQueryError(ctx, "Insert", err)
+		return err
+	}
+	_, err = tx.Exec(ctx, insertOrderQuery, order.ID, order.UserID, order.StorageUntil, order.Issued, order.IssuedAt, order.Returned, order.OrderPrice, order.Weight, order.PackageType, order.PackagePrice, order.Hash, order.CellID, order.CourierID)
+	if err != nil {
+		logQueryError(ctx, "Insert", err)
+		return err
+	}
+
+	if _, err = tx.Exec(ctx, auditQuery, order.ID, auditAccept, auth.Actor(ctx)); err != nil {
+		return err
+	}
+	if err = enqueue(ctx, tx, order.ID, models.OrderAccepted, order); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "order inserted", "order_id", order.ID, "cell_id", order.CellID, "operator", auth.Actor(ctx))
 	return nil
 }
 
-func (r *Repository) Update(order models.Order) error {
-	query := `
+const allocateCellQuery = `
+		UPDATE cells SET used_weight = used_weight + $1, orders_count = orders_count + 1
+		WHERE id = (
+			SELECT id FROM cells
+			WHERE package_type = $2 AND (max_weight IS NULL OR used_weight + $1 <= max_weight) AND orders_count < max_orders
+			ORDER BY shelf, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id
+		`
+
+// allocateCell reserves room in a cell for the order, the row lock keeps parallel accepts from overfilling it
+func (r *Repository) allocateCell(ctx context.Context, tx pgx.Tx, order *models.Order) (string, error) {
+	var cellID string
+	if err := tx.QueryRow(ctx, allocateCellQuery, order.Weight, order.PackageType).Scan(&cellID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", util.ErrNoFreeCell
+		}
+		return "", err
+	}
+	return cellID, nil
+}
+
+// logQueryError reports a failed query with its SQLSTATE, the correlation id is taken from ctx
+func logQueryError(ctx context.Context, op string, err error) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		slog.ErrorContext(ctx, "sql error", "op", op, "code", pgErr.Code, "detail", pgErr.Detail, "where", pgErr.Where)
+		return
+	}
+	slog.ErrorContext(ctx, "query failed", "op", op, "err", err)
+}
+
+// uniqueViolation is the SQLSTATE postgres reports for a duplicate key
+const uniqueViolation = "23505"
+
+const (
+	auditAccept          = "accept"
+	auditIssue           = "issue"
+	auditReturn          = "return"
+	auditReturnToCourier = "return_courier"
+)
+
+// auditQuery records which operator changed the order, it runs in the transaction of the change itself
+const auditQuery = `
+		INSERT INTO order_audit (order_id, action, operator)
+		VALUES ($1, $2, $3)
+		`
+
+// releaseCellQuery frees the room taken by the order and detaches it from its cell
+const releaseCellQuery = `
+		WITH released AS (
+			SELECT id, weight, cell_id FROM orders
+			WHERE id = $1 AND cell_id IS NOT NULL
+			FOR UPDATE
+		), freed AS (
+			UPDATE cells SET used_weight = cells.used_weight - released.weight, orders_count = cells.orders_count - 1
+			FROM released
+			WHERE cells.id = released.cell_id
+		)
+		UPDATE orders SET cell_id = NULL
+		FROM released
+		WHERE orders.id = released.id
+		`
+
+// Update marks the order returned and records its refund in one transaction, the refund gets its id.
+// Every return is refunded, a nil refund is a bug of the caller
+func (r *Repository) Update(ctx context.Context, order models.Order, refund *models.Refund) error {
+	if refund == nil {
+		return util.ErrInternal.Wrap(fmt.Errorf("return of order %s without a refund", order.ID))
+	}
+	return r.withRetry(ctx, "Update", func(ctx context.Context) error {
+		return r.update(ctx, order, refund)
+	})
+}
+
+// returnOrderQuery only takes an order back once, a concurrent return of the same order changes no row
+const returnOrderQuery = `
 		UPDATE orders SET returned=$1
-        WHERE id=$2
+        WHERE id=$2 AND returned = FALSE
         `
 
-	_, err := r.pool.Exec(r.ctx, query, order.Returned, order.ID)
+func (r *Repository) update(ctx context.Context, order models.Order, refund *models.Refund) error {
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{
+		IsoLevel:   pgx.ReadCommitted,
+		AccessMode: pgx.ReadWrite,
+	})
 	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err = checkHolds(ctx, tx, []string{order.ID}, auth.Actor(ctx), refund.CreatedAt); err != nil {
+		return err
+	}
+
+	tag, err := tx.Exec(ctx, returnOrderQuery, order.Returned, order.ID)
+	if err != nil {
+		logQueryError(ctx, "Update", err)
+		return err
+	}
+	// Validation saw the order not returned, someone took it back since, or a retry follows a lost commit
+	if tag.RowsAffected() == 0 {
+		return util.ErrOrderReturned
+	}
+
+	if _, err = tx.Exec(ctx, auditQuery, order.ID, auditReturn, auth.Actor(ctx)); err != nil {
+		return err
+	}
+	if err = enqueue(ctx, tx, order.ID, models.OrderReturned, order); err != nil {
+		return err
+	}
+	if err = tx.QueryRow(ctx, insertRefundQuery, insertRefundArgs(*refund)...).Scan(&refund.ID); err != nil {
 		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			log.Println(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s", pgErr.Code, pgErr.Detail, pgErr.Where))
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return util.ErrOrderReturned
 		}
+		logQueryError(ctx, "Update", err)
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
 		return err
 	}
+	slog.InfoContext(ctx, "order returned", "order_id", order.ID, "operator", auth.Actor(ctx))
+	slog.InfoContext(ctx, "refund recorded", "refund_id", refund.ID, "payment_id", refund.PaymentID, "amount", refund.Amount, "operator", auth.Actor(ctx))
 	return nil
 }
 
-func (r *Repository) IssueUpdate(orders []models.Order) error {
-	tx, err := r.pool.BeginTx(r.ctx, pgx.TxOptions{
-		IsoLevel:   pgx.RepeatableRead,
+// IssueUpdate locks the orders first, a concurrent change to the same orders waits for it.
+// The payment is recorded by the same transaction, it gets its id
+func (r *Repository) IssueUpdate(ctx context.Context, orders []models.Order, payment *models.Payment) error {
+	return r.withRetry(ctx, "IssueUpdate", func(ctx context.Context) error {
+		return r.issueUpdate(ctx, orders, payment)
+	})
+}
+
+// dropHoldQuery removes the hold of the issuing operator, or an expired one, a hold of someone else stops the issue before
+const dropHoldQuery = `DELETE FROM holds WHERE order_id = $1 AND (operator = $2 OR expires_at <= $3)`
+
+// issueOrderQuery only issues an order once, a retry after a concurrent issue of the same order changes no row
+const issueOrderQuery = `
+		UPDATE orders SET issued=$1, issued_at=$2
+        WHERE id=$3 AND issued = FALSE AND returned = FALSE
+        `
+
+func (r *Repository) issueUpdate(ctx context.Context, orders []models.Order, payment *models.Payment) error {
+	// Read committed, a repeatable read snapshot is taken before the locks and misses the holds placed while waiting
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{
+		IsoLevel:   pgx.ReadCommitted,
 		AccessMode: pgx.ReadWrite,
 	})
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback(r.ctx)
+	defer tx.Rollback(ctx)
 
-	query := `
-		UPDATE orders SET issued=$1, issued_at=$2
-        WHERE id=$3
-        `
+	actor := auth.Actor(ctx)
+	ids := make([]string, 0, len(orders))
+	for _, order := range orders {
+		ids = append(ids, order.ID)
+	}
+	if err = checkHolds(ctx, tx, ids, actor, payment.CreatedAt); err != nil {
+		return err
+	}
+
+	// Every order takes queriesPerOrder queries of the batch, the issue is the one at issueQueryIndex
+	const queriesPerOrder, issueQueryIndex = 5, 1
 	batch := &pgx.Batch{}
 	for _, order := range orders {
-		batch.Queue(query, order.Issued, order.IssuedAt, order.ID)
-		log.Printf("Order with id:%s issued\n", order.ID)
+		// The cell is released by the batch itself
+		order.CellID = ""
+		event, err := outboxArgs(order.ID, models.OrderIssued, order)
+		if err != nil {
+			return err
+		}
+
+		batch.Queue(releaseCellQuery, order.ID)
+		batch.Queue(issueOrderQuery, order.Issued, order.IssuedAt, order.ID)
+		// The hold was placed for this issue, it has served its purpose
+		batch.Queue(dropHoldQuery, order.ID, actor, payment.CreatedAt)
+		batch.Queue(auditQuery, order.ID, auditIssue, actor)
+		batch.Queue(outboxQuery, event...)
+	}
+	paymentArgs, err := insertPaymentArgs(*payment, orders)
+	if err != nil {
+		return err
 	}
+	batch.Queue(insertPaymentQuery, paymentArgs...)
 
-	br := tx.SendBatch(r.ctx, batch)
-	for _, i := range orders {
-		_, err := br.Exec()
+	br := tx.SendBatch(ctx, batch)
+	// The payment is the last query, it returns its id
+	for i := 0; i < batch.Len()-1; i++ {
+		tag, err := br.Exec()
 		if err != nil {
 			br.Close()
-			return fmt.Errorf("error executing batch at order index %d: %w", i, err)
+			logQueryError(ctx, "IssueUpdate", err)
+			return fmt.Errorf("error executing batch at order index %d: %w", i/queriesPerOrder, err)
+		}
+		// Validation saw the order in storage, someone issued or took it back since
+		if i%queriesPerOrder == issueQueryIndex && tag.RowsAffected() == 0 {
+			br.Close()
+			return util.ErrOrderIssued.Wrap(fmt.Errorf("order %s", orders[i/queriesPerOrder].ID))
 		}
 	}
-	err = br.Close()
+	if err = br.QueryRow().Scan(&payment.ID); err != nil {
+		br.Close()
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return util.ErrOrderIssued
+		}
+		logQueryError(ctx, "IssueUpdate", err)
+		return err
+	}
+	if err = br.Close(); err != nil {
+		return err
+	}
 
-	return tx.Commit(r.ctx)
+	if err = tx.Commit(ctx); err != nil {
+		return err
+	}
+	for _, order := range orders {
+		slog.InfoContext(ctx, "order issued", "order_id", order.ID, "operator", actor)
+	}
+	slog.InfoContext(ctx, "payment recorded", "payment_id", payment.ID, "method", payment.Method, "due", payment.Due, "operator", actor)
+	return nil
 }
 
-func (r *Repository) Delete(id string) error {
-	query := `
+// Delete hands the order back to the courier, it fails with ErrOrderHeld when another operator holds it at now
+func (r *Repository) Delete(ctx context.Context, id string, now time.Time) error {
+	return r.withRetry(ctx, "Delete", func(ctx context.Context) error {
+		return r.delete(ctx, id, now)
+	})
+}
+
+const deleteOrderQuery = `
 		DELETE FROM orders WHERE id=$1
+		RETURNING id, user_id, storage_until, issued, issued_at, returned, order_price, weight, package_type, package_price, hash, COALESCE(cell_id, '') AS cell_id, COALESCE(courier_id, '') AS courier_id
 		`
 
-	_, err := r.pool.Exec(r.ctx, query, id)
+func (r *Repository) delete(ctx context.Context, id string, now time.Time) error {
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{
+		IsoLevel:   pgx.ReadCommitted,
+		AccessMode: pgx.ReadWrite,
+	})
 	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			log.Println(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s", pgErr.Code, pgErr.Detail, pgErr.Where))
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err = checkHolds(ctx, tx, []string{id}, auth.Actor(ctx), now); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(ctx, releaseCellQuery, id); err != nil {
+		return err
+	}
+
+	var order models.Order
+	if err = pgxscan.Get(ctx, tx, &order, deleteOrderQuery, id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return util.ErrOrderNotFound
 		}
+		logQueryError(ctx, "Delete", err)
 		return err
 	}
 
+	if _, err = tx.Exec(ctx, auditQuery, id, auditReturnToCourier, auth.Actor(ctx)); err != nil {
+		return err
+	}
+	if err = enqueue(ctx, tx, id, models.OrderReturnedToCourier, order); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "order returned to courier", "order_id", id, "operator", auth.Actor(ctx))
 	return nil
 }
 
-func (r *Repository) Get(id string) (models.Order, error) {
-	var order models.Order
-	query := `
-		SELECT id, user_id, storage_until, issued, issued_at, returned, order_price, weight, package_type, package_price, hash FROM orders
+const getOrderQuery = `
+		SELECT id, user_id, storage_until, issued, issued_at, returned, order_price, weight, package_type, package_price, hash, COALESCE(cell_id, '') AS cell_id, COALESCE(courier_id, '') AS courier_id FROM orders
 		WHERE id=$1
 		`
-	if err := pgxscan.Get(r.ctx, r.pool, &order, query, id); err != nil {
-		return models.Order{}, err
+
+func (r *Repository) Get(ctx context.Context, id string) (models.Order, error) {
+	var order models.Order
+	if err := pgxscan.Get(ctx, r.pool, &order, getOrderQuery, id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Order{}, util.ErrOrderNotFound
+		}
+		logQueryError(ctx, "Get", err)
+		return models.Order{}, storageError(err)
 	}
 	return order, nil
 }
 
-func (r *Repository) GetReturns(offset, limit int) ([]models.Order, error) {
-	query := `
-        SELECT id, user_id, storage_until, issued, issued_at, returned, order_price, weight, package_type, package_price, hash
+const getReturnsQuery = `
+        SELECT id, user_id, storage_until, issued, issued_at, returned, order_price, weight, package_type, package_price, hash, COALESCE(cell_id, '') AS cell_id, COALESCE(courier_id, '') AS courier_id
         FROM orders
         WHERE returned = TRUE
         ORDER BY id
@@ -153,13 +511,11 @@ func (r *Repository) GetReturns(offset, limit int) ([]models.Order, error) {
  		FETCH NEXT $2 ROWS ONLY
     `
 
-	rows, err := r.pool.Query(r.ctx, query, offset, limit)
+func (r *Repository) GetReturns(ctx context.Context, offset, limit int) ([]models.Order, error) {
+	rows, err := r.pool.Query(ctx, getReturnsQuery, offset, limit)
 	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			log.Println(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s", pgErr.Code, pgErr.Detail, pgErr.Where))
-		}
-		return nil, err
+		logQueryError(ctx, "GetReturns", err)
+		return nil, storageError(err)
 	}
 
 	defer rows.Close()
@@ -171,9 +527,8 @@ func (r *Repository) GetReturns(offset, limit int) ([]models.Order, error) {
 	return returns, nil
 }
 
-func (r *Repository) GetOrders(userId string, offset, limit int) ([]models.Order, error) {
-	query := `
-		SELECT id, user_id, storage_until, issued, issued_at, returned, order_price, weight, package_type, package_price, hash
+const getOrdersQuery = `
+		SELECT id, user_id, storage_until, issued, issued_at, returned, order_price, weight, package_type, package_price, hash, COALESCE(cell_id, '') AS cell_id, COALESCE(courier_id, '') AS courier_id
 		FROM orders
 		WHERE user_id = $1 AND issued = FALSE
 		ORDER BY storage_until
@@ -181,13 +536,11 @@ func (r *Repository) GetOrders(userId string, offset, limit int) ([]models.Order
 		FETCH NEXT $3 ROWS ONLY
 	`
 
-	rows, err := r.pool.Query(r.ctx, query, userId, offset, limit)
+func (r *Repository) GetOrders(ctx context.Context, userId string, offset, limit int) ([]models.Order, error) {
+	rows, err := r.pool.Query(ctx, getOrdersQuery, userId, offset, limit)
 	if err != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			log.Println(fmt.Sprintf("SQL Error: %s, Detail: %s, Where: %s", pgErr.Code, pgErr.Detail, pgErr.Where))
-		}
-		return nil, err
+		logQueryError(ctx, "GetOrders", err)
+		return nil, storageError(err)
 	}
 	defer rows.Close()
 
@@ -197,3 +550,74 @@ func (r *Repository) GetOrders(userId string, offset, limit int) ([]models.Order
 	}
 	return userOrders, err
 }
+
+const getCellsQuery = `
+		SELECT id, shelf, package_type, COALESCE(max_weight, 0) AS max_weight, used_weight, max_orders, orders_count
+		FROM cells
+		ORDER BY shelf, id
+	`
+
+func (r *Repository) GetCells(ctx context.Context) ([]models.Cell, error) {
+	rows, err := r.pool.Query(ctx, getCellsQuery)
+	if err != nil {
+		logQueryError(ctx, "GetCells", err)
+		return nil, storageError(err)
+	}
+	defer rows.Close()
+
+	var cells []models.Cell
+	if err := pgxscan.ScanAll(&cells, rows); err != nil {
+		return nil, err
+	}
+	return cells, nil
+}
+
+const insertOperatorQuery = `
+		INSERT INTO operators (login, role, password_hash, api_key_hash)
+		VALUES ($1, $2, $3, $4)
+		`
+
+func (r *Repository) InsertOperator(ctx context.Context, operator models.Operator) error {
+	_, err := r.pool.Exec(ctx, insertOperatorQuery, operator.Login, operator.Role, operator.PasswordHash, operator.APIKeyHash)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return util.ErrOperatorExists
+		}
+		logQueryError(ctx, "InsertOperator", err)
+		return err
+	}
+	return nil
+}
+
+const getOperatorQuery = `
+		SELECT login, role, password_hash, api_key_hash FROM operators
+		WHERE login=$1
+		`
+
+func (r *Repository) GetOperator(ctx context.Context, login string) (models.Operator, error) {
+	var operator models.Operator
+	if err := pgxscan.Get(ctx, r.pool, &operator, getOperatorQuery, login); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Operator{}, util.ErrOperatorNotFound
+		}
+		return models.Operator{}, storageError(err)
+	}
+	return operator, nil
+}
+
+const getOperatorByKeyQuery = `
+		SELECT login, role, password_hash, api_key_hash FROM operators
+		WHERE api_key_hash=$1
+		`
+
+func (r *Repository) GetOperatorByKey(ctx context.Context, apiKeyHash string) (models.Operator, error) {
+	var operator models.Operator
+	if err := pgxscan.Get(ctx, r.pool, &operator, getOperatorByKeyQuery, apiKeyHash); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Operator{}, util.ErrOperatorNotFound
+		}
+		return models.Operator{}, storageError(err)
+	}
+	return operator, nil
+}
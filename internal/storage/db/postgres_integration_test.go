@@ -0,0 +1,1214 @@
+//go:build integration
+
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"homework/internal/auth"
+	"homework/internal/models"
+	"homework/internal/util"
+	"homework/migrations"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// The tests run against a throwaway cluster started with initdb and pg_ctl from PG_BIN, PATH or the Debian
+// install dir. TEST_POSTGRES_DB points them at an existing server instead, the connection is taken from
+// POSTGRES_USER, POSTGRES_PASSWORD, DB_HOST and DB_PORT and every table of that db is truncated.
+//
+//	go test -tags integration ./internal/storage/db/...
+
+var errNoPostgres = errors.New("no postgres to test against")
+
+var testRepository *Repository
+
+// testCfg is the config of the test database, tests that need a pool of their own connect with it
+var testCfg *models.Config
+
+var storageUntil = time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestMain(m *testing.M) {
+	os.Exit(runTests(m))
+}
+
+func runTests(m *testing.M) int {
+	cfg, stop, err := testDatabase()
+	if err != nil {
+		if errors.Is(err, errNoPostgres) {
+			fmt.Fprintln(os.Stderr, "skipping the repository integration tests:", err)
+			return 0
+		}
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer stop()
+	testCfg = cfg
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	pool, err := Connect(ctx, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	migrator, err := NewMigrator(pool, migrations.FS)
+	if err == nil {
+		err = migrator.Up(ctx)
+	}
+	pool.Close()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "migrating:", err)
+		return 1
+	}
+
+	testRepository, err = NewSQLRepository(ctx, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer testRepository.Close()
+
+	return m.Run()
+}
+
+func testConfig() *models.Config {
+	return &models.Config{
+		User:          "postgres",
+		Host:          "localhost",
+		DBName:        "postgres",
+		Attempts:      3,
+		Timeout:       5 * time.Second,
+		RetryDelay:    50 * time.Millisecond,
+		RetryMaxDelay: time.Second,
+	}
+}
+
+func testDatabase() (*models.Config, func(), error) {
+	if dbName := os.Getenv("TEST_POSTGRES_DB"); len(dbName) > 0 {
+		cfg := testConfig()
+		cfg.DBName = dbName
+		cfg.User = envOr("POSTGRES_USER", cfg.User)
+		cfg.Password = os.Getenv("POSTGRES_PASSWORD")
+		cfg.Host = envOr("DB_HOST", cfg.Host)
+		cfg.Port = envOr("DB_PORT", "5432")
+		return cfg, func() {}, nil
+	}
+	return startPostgres()
+}
+
+// startPostgres runs a cluster in a temp dir on a free port, stop shuts it down and removes the dir
+func startPostgres() (*models.Config, func(), error) {
+	initdb, err := pgBinary("initdb")
+	if err != nil {
+		return nil, nil, err
+	}
+	pgCtl, err := pgBinary("pg_ctl")
+	if err != nil {
+		return nil, nil, err
+	}
+	if os.Geteuid() == 0 {
+		return nil, nil, fmt.Errorf("%w: initdb can't run as root, set TEST_POSTGRES_DB", errNoPostgres)
+	}
+
+	dir, err := os.MkdirTemp("", "pvz-postgres-")
+	if err != nil {
+		return nil, nil, err
+	}
+	data := filepath.Join(dir, "data")
+	if err = command(initdb, "-D", data, "-U", "postgres", "-A", "trust", "-E", "UTF8", "--no-sync"); err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+
+	port, err := freePort()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+	options := fmt.Sprintf("-p %d -k %s -c listen_addresses=localhost -c fsync=off", port, dir)
+	if err = command(pgCtl, "-D", data, "-l", filepath.Join(dir, "postgres.log"), "-o", options, "-w", "start"); err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+
+	stop := func() {
+		if err := command(pgCtl, "-D", data, "-m", "immediate", "-w", "stop"); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		os.RemoveAll(dir)
+	}
+	cfg := testConfig()
+	cfg.Port = strconv.Itoa(port)
+	return cfg, stop, nil
+}
+
+func pgBinary(name string) (string, error) {
+	if dir := os.Getenv("PG_BIN"); len(dir) > 0 {
+		return filepath.Join(dir, name), nil
+	}
+	if path, err := exec.LookPath(name); err == nil {
+		return path, nil
+	}
+	// Debian keeps the server binaries out of PATH, the newest version wins
+	if matches, _ := filepath.Glob(filepath.Join("/usr/lib/postgresql/*/bin", name)); len(matches) > 0 {
+		sort.Strings(matches)
+		return matches[len(matches)-1], nil
+	}
+	return "", fmt.Errorf("%w: %s not found, set PG_BIN or TEST_POSTGRES_DB", errNoPostgres, name)
+}
+
+func command(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %w\n%s", filepath.Base(name), err, out)
+	}
+	return nil
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); len(value) > 0 {
+		return value
+	}
+	return fallback
+}
+
+// repository empties the tables and the cells, every test starts from a migrated db without data
+func repository(t *testing.T) *Repository {
+	t.Helper()
+	_, err := testRepository.pool.Exec(context.Background(), `
+		TRUNCATE orders, users, couriers, courier_manifests, holds, payments, payment_orders, refunds, order_audit, notifications, outbox, idempotency_keys, operators RESTART IDENTITY CASCADE;
+		UPDATE cells SET used_weight = 0, orders_count = 0;
+	`)
+	if err != nil {
+		t.Fatalf("resetting db: %v", err)
+	}
+	return testRepository
+}
+
+func newOrder(id, userID string, packageType models.PackageType, weight models.Weight) *models.Order {
+	return &models.Order{
+		ID:           id,
+		UserID:       userID,
+		StorageUntil: storageUntil,
+		OrderPrice:   100,
+		Weight:       weight,
+		PackageType:  packageType,
+		PackagePrice: 20,
+		Hash:         "hash-" + id,
+	}
+}
+
+func insert(t *testing.T, r *Repository, orders ...*models.Order) {
+	t.Helper()
+	for _, order := range orders {
+		if err := r.Insert(context.Background(), order); err != nil {
+			t.Fatalf("inserting %s: %v", order.ID, err)
+		}
+	}
+}
+
+// issue gives out the orders of one customer paid by card
+func issue(ctx context.Context, r *Repository, orders ...models.Order) error {
+	return r.IssueUpdate(ctx, orders, cardPayment(orders))
+}
+
+// returnOrder takes the order back with a cash refund of its price
+func returnOrder(ctx context.Context, r *Repository, order models.Order) error {
+	refund := &models.Refund{OrderID: order.ID, UserID: order.UserID, Operator: "operator-1", CreatedAt: storageUntil,
+		Method: models.PaymentCash, Goods: order.OrderPrice, Amount: order.OrderPrice}
+	return r.Update(ctx, order, refund)
+}
+
+// cardPayment is what the service records with an issue paid by card
+func cardPayment(orders []models.Order) *models.Payment {
+	payment := &models.Payment{UserID: orders[0].UserID, Operator: "operator-1", CreatedAt: storageUntil, Method: models.PaymentCard}
+	for _, order := range orders {
+		payment.Total += order.OrderPrice
+		payment.Lines = append(payment.Lines, models.ReceiptLine{OrderID: order.ID, Kind: models.LineGoods, Amount: order.OrderPrice})
+	}
+	payment.Due, payment.Paid = payment.Total, payment.Total
+	return payment
+}
+
+func cell(t *testing.T, r *Repository, id string) models.Cell {
+	t.Helper()
+	cells, err := r.GetCells(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cells {
+		if c.ID == id {
+			return c
+		}
+	}
+	t.Fatalf("no cell %s", id)
+	return models.Cell{}
+}
+
+func orderIDs(orders []models.Order) []string {
+	ids := make([]string, 0, len(orders))
+	for _, order := range orders {
+		ids = append(ids, order.ID)
+	}
+	return ids
+}
+
+func outboxEvents(t *testing.T, r *Repository) []models.OutboxEventType {
+	t.Helper()
+	var events []models.OutboxEventType
+	_, err := r.ProcessOutbox(context.Background(), 100, func(ctx context.Context, batch []models.OutboxEvent) map[int64]error {
+		results := make(map[int64]error, len(batch))
+		for _, event := range batch {
+			events = append(events, event.Type)
+			results[event.ID] = nil
+		}
+		return results
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+func TestInsertAndGet(t *testing.T) {
+	r := repository(t)
+	ctx := context.Background()
+
+	order := newOrder("1", "10", "box", 5)
+	insert(t, r, order)
+	if order.CellID != "C-01" {
+		t.Errorf("cell = %q, want the first box cell C-01", order.CellID)
+	}
+
+	got, err := r.Get(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.StorageUntil.Equal(order.StorageUntil) {
+		t.Errorf("storage until = %s, want %s", got.StorageUntil, order.StorageUntil)
+	}
+	got.StorageUntil, got.IssuedAt = order.StorageUntil, order.IssuedAt
+	if got != *order {
+		t.Errorf("got %+v, want %+v", got, *order)
+	}
+
+	if c := cell(t, r, "C-01"); c.OrdersCount != 1 || c.UsedWeight != 5 {
+		t.Errorf("cell holds %d orders of %v kg, want 1 of 5", c.OrdersCount, c.UsedWeight)
+	}
+	if events := outboxEvents(t, r); !slices.Equal(events, []models.OutboxEventType{models.OrderAccepted}) {
+		t.Errorf("outbox = %v, want the accept event", events)
+	}
+}
+
+func TestInsertFillsCells(t *testing.T) {
+	r := repository(t)
+
+	// A box cell keeps two orders
+	first, second, third := newOrder("1", "10", "box", 5), newOrder("2", "10", "box", 5), newOrder("3", "10", "box", 5)
+	insert(t, r, first, second, third)
+	if first.CellID != "C-01" || second.CellID != "C-01" || third.CellID != "C-02" {
+		t.Errorf("cells = %s, %s, %s, want C-01, C-01, C-02", first.CellID, second.CellID, third.CellID)
+	}
+}
+
+func TestInsertFilmHasNoWeightLimit(t *testing.T) {
+	r := repository(t)
+
+	heavy := newOrder("1", "10", "film", 500)
+	insert(t, r, heavy)
+	if c := cell(t, r, heavy.CellID); c.PackageType != "film" || c.MaxWeight != 0 || c.UsedWeight != 500 {
+		t.Errorf("cell = %+v, want a film cell without a weight limit holding 500 kg", c)
+	}
+}
+
+func TestInsertDuplicate(t *testing.T) {
+	r := repository(t)
+
+	insert(t, r, newOrder("1", "10", "box", 5))
+	if err := r.Insert(context.Background(), newOrder("1", "10", "box", 5)); err == nil {
+		t.Fatal("second insert of the same id succeeded")
+	}
+	// The cell taken for the duplicate is given back with the rolled back transaction
+	if c := cell(t, r, "C-01"); c.OrdersCount != 1 {
+		t.Errorf("cell holds %d orders, want 1", c.OrdersCount)
+	}
+}
+
+func TestInsertNoFreeCell(t *testing.T) {
+	r := repository(t)
+
+	err := r.Insert(context.Background(), newOrder("1", "10", "box", 61))
+	if !errors.Is(err, util.ErrNoFreeCell) {
+		t.Fatalf("error = %v, want %v", err, util.ErrNoFreeCell)
+	}
+	if _, err = r.Get(context.Background(), "1"); !errors.Is(err, util.ErrOrderNotFound) {
+		t.Errorf("order without a cell was stored: %v", err)
+	}
+}
+
+func TestGetNotFound(t *testing.T) {
+	r := repository(t)
+
+	if _, err := r.Get(context.Background(), "404"); !errors.Is(err, util.ErrOrderNotFound) {
+		t.Fatalf("error = %v, want %v", err, util.ErrOrderNotFound)
+	}
+}
+
+func TestGetOrders(t *testing.T) {
+	r := repository(t)
+	ctx := context.Background()
+
+	later, sooner, issued, other := newOrder("1", "10", "film", 1), newOrder("2", "10", "film", 1), newOrder("3", "10", "film", 1), newOrder("4", "20", "film", 1)
+	later.StorageUntil = storageUntil.Add(48 * time.Hour)
+	insert(t, r, later, sooner, issued, other)
+	issued.Issued, issued.IssuedAt = true, time.Now()
+	if err := issue(ctx, r, *issued); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		offset, limit int
+		want          []string
+	}{
+		{name: "all", offset: 0, limit: 10, want: []string{"2", "1"}},
+		{name: "first page", offset: 0, limit: 1, want: []string{"2"}},
+		{name: "second page", offset: 1, limit: 1, want: []string{"1"}},
+		{name: "past the end", offset: 2, limit: 1, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orders, err := r.GetOrders(ctx, "10", tt.offset, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ids := orderIDs(orders); !slices.Equal(ids, tt.want) {
+				t.Errorf("got %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestIssueUpdate(t *testing.T) {
+	r := repository(t)
+	ctx := context.Background()
+
+	first, second := newOrder("1", "10", "packet", 2), newOrder("2", "10", "packet", 3)
+	insert(t, r, first, second)
+
+	issuedAt := time.Date(2029, 12, 30, 15, 0, 0, 0, time.UTC)
+	first.Issued, first.IssuedAt = true, issuedAt
+	second.Issued, second.IssuedAt = true, issuedAt
+	payment := cardPayment([]models.Order{*first, *second})
+	if err := r.IssueUpdate(ctx, []models.Order{*first, *second}, payment); err != nil {
+		t.Fatal(err)
+	}
+	if payment.ID != 1 {
+		t.Errorf("payment id = %d, want the first one", payment.ID)
+	}
+	var paid []string
+	if err := r.pool.QueryRow(ctx, `SELECT array_agg(order_id ORDER BY order_id) FROM payment_orders WHERE payment_id = $1`, payment.ID).Scan(&paid); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(paid, []string{"1", "2"}) {
+		t.Errorf("payment covers %v, want both orders", paid)
+	}
+
+	for _, id := range []string{"1", "2"} {
+		got, err := r.Get(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Issued || !got.IssuedAt.Equal(issuedAt) || len(got.CellID) > 0 {
+			t.Errorf("order %s: issued %v at %s in cell %q, want issued at %s without a cell", id, got.Issued, got.IssuedAt, got.CellID, issuedAt)
+		}
+	}
+	if c := cell(t, r, "B-01"); c.OrdersCount != 0 || c.UsedWeight != 0 {
+		t.Errorf("cell still holds %d orders of %v kg", c.OrdersCount, c.UsedWeight)
+	}
+	want := []models.OutboxEventType{models.OrderAccepted, models.OrderAccepted, models.OrderIssued, models.OrderIssued}
+	if events := outboxEvents(t, r); !slices.Equal(events, want) {
+		t.Errorf("outbox = %v, want %v", events, want)
+	}
+
+	// A second issue, like a retry of one that lost a race, records nothing
+	again := cardPayment([]models.Order{*first})
+	if err := r.IssueUpdate(ctx, []models.Order{*first}, again); !errors.Is(err, util.ErrOrderIssued) {
+		t.Errorf("second issue error = %v, want %v", err, util.ErrOrderIssued)
+	}
+	var payments int
+	if err := r.pool.QueryRow(ctx, `SELECT count(*) FROM payments`).Scan(&payments); err != nil {
+		t.Fatal(err)
+	}
+	if payments != 1 {
+		t.Errorf("%d payments, want the first one only", payments)
+	}
+}
+
+func TestUpdateAndGetReturns(t *testing.T) {
+	r := repository(t)
+	ctx := context.Background()
+
+	orders := []*models.Order{newOrder("3", "10", "film", 1), newOrder("1", "10", "film", 1), newOrder("2", "20", "film", 1)}
+	insert(t, r, orders...)
+	for _, order := range orders[:2] {
+		order.Returned = true
+		if err := returnOrder(ctx, r, *order); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	returns, err := r.GetReturns(ctx, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := orderIDs(returns); !slices.Equal(ids, []string{"1", "3"}) {
+		t.Errorf("returns = %v, want [1 3]", ids)
+	}
+	returns, err = r.GetReturns(ctx, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := orderIDs(returns); !slices.Equal(ids, []string{"3"}) {
+		t.Errorf("returns from offset 1 = %v, want [3]", ids)
+	}
+
+	orders[2].Returned = true
+	if err := r.Update(ctx, *orders[2], nil); !errors.Is(err, util.ErrInternal) {
+		t.Errorf("return without a refund error = %v, want %v", err, util.ErrInternal)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	r := repository(t)
+	ctx := context.Background()
+
+	insert(t, r, newOrder("1", "10", "box", 5))
+	if err := r.Delete(ctx, "1", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Get(ctx, "1"); !errors.Is(err, util.ErrOrderNotFound) {
+		t.Errorf("deleted order: %v", err)
+	}
+	if c := cell(t, r, "C-01"); c.OrdersCount != 0 || c.UsedWeight != 0 {
+		t.Errorf("cell still holds %d orders of %v kg", c.OrdersCount, c.UsedWeight)
+	}
+	if err := r.Delete(ctx, "1", time.Now()); !errors.Is(err, util.ErrOrderNotFound) {
+		t.Errorf("second delete error = %v, want %v", err, util.ErrOrderNotFound)
+	}
+}
+
+func TestGetExpiring(t *testing.T) {
+	r := repository(t)
+	ctx := context.Background()
+
+	soon, later, issued := newOrder("1", "10", "film", 1), newOrder("2", "10", "film", 1), newOrder("3", "10", "film", 1)
+	later.StorageUntil = storageUntil.Add(72 * time.Hour)
+	insert(t, r, soon, later, issued)
+	issued.Issued, issued.IssuedAt = true, time.Now()
+	if err := issue(ctx, r, *issued); err != nil {
+		t.Fatal(err)
+	}
+
+	expiring, err := r.GetExpiring(ctx, storageUntil.Add(-time.Hour), storageUntil.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := orderIDs(expiring); !slices.Equal(ids, []string{"1"}) {
+		t.Errorf("expiring = %v, want [1]", ids)
+	}
+}
+
+func TestRefunds(t *testing.T) {
+	r := repository(t)
+	ctx := context.Background()
+
+	paid, unpaid := newOrder("1", "10", "box", 1), newOrder("2", "20", "box", 1)
+	insert(t, r, paid, unpaid)
+	paid.Issued, paid.IssuedAt = true, storageUntil.Add(-time.Hour)
+	payment := cardPayment([]models.Order{*paid})
+	if err := r.IssueUpdate(ctx, []models.Order{*paid}, payment); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := r.GetOrderPayment(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != payment.ID || got.Method != models.PaymentCard || len(got.Lines) != 1 || got.Lines[0].Amount != paid.OrderPrice {
+		t.Errorf("payment = %+v, want %+v", got, *payment)
+	}
+	if _, err = r.GetOrderPayment(ctx, "2"); !errors.Is(err, util.ErrPaymentNotFound) {
+		t.Errorf("payment of an order issued without one: error = %v, want %v", err, util.ErrPaymentNotFound)
+	}
+
+	paid.Returned = true
+	refund := &models.Refund{PaymentID: payment.ID, OrderID: "1", UserID: "10", Operator: "operator-1", CreatedAt: storageUntil,
+		Method: models.PaymentCard, Goods: 80, Amount: 80}
+	if err = r.Update(ctx, *paid, refund); err != nil {
+		t.Fatal(err)
+	}
+	// A second return of the same order, concurrent or retried, must not pay the customer again
+	again := *refund
+	if err = r.Update(ctx, *paid, &again); !errors.Is(err, util.ErrOrderReturned) {
+		t.Errorf("second return: error = %v, want %v", err, util.ErrOrderReturned)
+	}
+	unpaid.Returned = true
+	if err = returnOrder(ctx, r, *unpaid); err != nil {
+		t.Fatal(err)
+	}
+
+	refunds, err := r.GetRefunds(ctx, "", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refunds) != 2 || refunds[0].PaymentID != 0 || refunds[1].ID != refund.ID || refunds[1].PaymentID != payment.ID {
+		t.Errorf("refunds = %+v, want the unlinked one first, then the one of payment %d", refunds, payment.ID)
+	}
+	if refunds, err = r.GetRefunds(ctx, "10", 0, 10); err != nil || len(refunds) != 1 || refunds[0].Amount != 80 {
+		t.Errorf("refunds of 10 = %+v, %v, want the one refund of 80", refunds, err)
+	}
+}
+
+func TestOperators(t *testing.T) {
+	r := repository(t)
+	ctx := context.Background()
+
+	operator := models.Operator{Login: "anna", Role: models.RoleSenior, PasswordHash: "password", APIKeyHash: "key"}
+	if err := r.InsertOperator(ctx, operator); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.InsertOperator(ctx, operator); !errors.Is(err, util.ErrOperatorExists) {
+		t.Errorf("duplicate login error = %v, want %v", err, util.ErrOperatorExists)
+	}
+
+	got, err := r.GetOperator(ctx, "anna")
+	if err != nil || got != operator {
+		t.Errorf("GetOperator = %+v, %v, want %+v", got, err, operator)
+	}
+	got, err = r.GetOperatorByKey(ctx, "key")
+	if err != nil || got != operator {
+		t.Errorf("GetOperatorByKey = %+v, %v, want %+v", got, err, operator)
+	}
+	if _, err = r.GetOperator(ctx, "boris"); !errors.Is(err, util.ErrOperatorNotFound) {
+		t.Errorf("unknown login error = %v, want %v", err, util.ErrOperatorNotFound)
+	}
+}
+
+func TestUsers(t *testing.T) {
+	r := repository(t)
+	ctx := context.Background()
+
+	user := models.User{ID: "10", Name: "Anna", Phone: "+79990000000", CreatedAt: time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)}
+	if err := r.InsertUser(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.InsertUser(ctx, user); !errors.Is(err, util.ErrUserExists) {
+		t.Errorf("duplicate user error = %v, want %v", err, util.ErrUserExists)
+	}
+	got, err := r.GetUser(ctx, "10")
+	if err != nil || got.Name != user.Name || got.Phone != user.Phone || !got.CreatedAt.Equal(user.CreatedAt) {
+		t.Errorf("GetUser = %+v, %v, want %+v", got, err, user)
+	}
+
+	// Accepting an order adds its customer and keeps a known one as is
+	issued, returned := newOrder("1", "10", "box", 5), newOrder("2", "10", "box", 5)
+	insert(t, r, newOrder("3", "10", "packet", 1), issued, returned, newOrder("4", "20", "film", 1))
+	if got, err = r.GetUser(ctx, "10"); err != nil || got.Name != user.Name {
+		t.Errorf("known customer became %+v, %v", got, err)
+	}
+	if got, err = r.GetUser(ctx, "20"); err != nil || got.ID != "20" {
+		t.Errorf("customer of the accepted order = %+v, %v", got, err)
+	}
+	if _, err = r.GetUser(ctx, "30"); !errors.Is(err, util.ErrUserNotFound) {
+		t.Errorf("unknown user error = %v, want %v", err, util.ErrUserNotFound)
+	}
+
+	issued.Issued, issued.IssuedAt = true, time.Now()
+	returned.Issued, returned.IssuedAt = true, time.Now()
+	if err = issue(ctx, r, *issued, *returned); err != nil {
+		t.Fatal(err)
+	}
+	returned.Returned = true
+	if err = returnOrder(ctx, r, *returned); err != nil {
+		t.Fatal(err)
+	}
+
+	orders, err := r.GetUserOrders(ctx, "10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := orderIDs(orders); !slices.Equal(ids, []string{"1", "2", "3"}) {
+		t.Errorf("user orders = %v, want every order of the customer [1 2 3]", ids)
+	}
+}
+
+func TestReturnToCourier(t *testing.T) {
+	r := repository(t)
+	ctx := context.Background()
+
+	for _, id := range []string{"7", "8"} {
+		if err := r.InsertCourier(ctx, models.Courier{ID: id, CreatedAt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.InsertCourier(ctx, models.Courier{ID: "7", CreatedAt: time.Now()}); !errors.Is(err, util.ErrCourierExists) {
+		t.Errorf("duplicate courier error = %v, want %v", err, util.ErrCourierExists)
+	}
+	if _, err := r.GetCourier(ctx, "9"); !errors.Is(err, util.ErrCourierNotFound) {
+		t.Errorf("unknown courier error = %v, want %v", err, util.ErrCourierNotFound)
+	}
+
+	brought := func(order *models.Order, courierID string) *models.Order {
+		order.CourierID = courierID
+		return order
+	}
+	expired := brought(newOrder("1", "10", "box", 5), "7")
+	expired.StorageUntil = time.Now().Add(-24 * time.Hour)
+	kept := brought(newOrder("2", "10", "box", 5), "7")
+	returned := brought(newOrder("3", "10", "film", 1), "7")
+	other := brought(newOrder("4", "10", "film", 1), "8")
+	other.StorageUntil = expired.StorageUntil
+	insert(t, r, expired, kept, returned, other)
+
+	returned.Issued, returned.IssuedAt = true, time.Now()
+	if err := issue(ctx, r, *returned); err != nil {
+		t.Fatal(err)
+	}
+	returned.Returned = true
+	if err := returnOrder(ctx, r, *returned); err != nil {
+		t.Fatal(err)
+	}
+	outboxEvents(t, r)
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	orders, err := r.GetCourierReturnable(ctx, "7", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := orderIDs(orders); !slices.Equal(ids, []string{"1", "3"}) {
+		t.Fatalf("returnable = %v, want the expired and the returned order [1 3]", ids)
+	}
+
+	// An order that isn't returnable anymore spoils the whole manifest
+	stale := models.Manifest{CourierID: "7", CreatedAt: now, Orders: append(slices.Clone(orders), *kept)}
+	if err = r.ReturnToCourier(ctx, &stale); !errors.Is(err, util.ErrManifestStale) {
+		t.Fatalf("stale manifest error = %v, want %v", err, util.ErrManifestStale)
+	}
+	if _, err = r.Get(ctx, "1"); err != nil {
+		t.Errorf("order of the stale manifest is gone: %v", err)
+	}
+
+	manifest := models.Manifest{CourierID: "7", Operator: "anna", CreatedAt: now, Orders: orders}
+	if err = r.ReturnToCourier(ctx, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.ID == 0 {
+		t.Error("manifest id is not set")
+	}
+	for _, id := range []string{"1", "3"} {
+		if _, err = r.Get(ctx, id); !errors.Is(err, util.ErrOrderNotFound) {
+			t.Errorf("order %s after the courier return: %v, want %v", id, err, util.ErrOrderNotFound)
+		}
+	}
+	if c := cell(t, r, expired.CellID); c.OrdersCount != 1 {
+		t.Errorf("cell %s holds %d orders, want the kept one only", c.ID, c.OrdersCount)
+	}
+	if events := outboxEvents(t, r); !slices.Equal(events, []models.OutboxEventType{models.OrderReturnedToCourier, models.OrderReturnedToCourier}) {
+		t.Errorf("outbox = %v, want a courier return event per order", events)
+	}
+}
+
+// TestConcurrentMigrations starts two binaries on an empty schema at once, the second waits for the lock
+// and finds nothing left to apply instead of failing on the tables the first created
+func TestConcurrentMigrations(t *testing.T) {
+	ctx := context.Background()
+
+	if _, err := testRepository.pool.Exec(ctx, `CREATE SCHEMA IF NOT EXISTS concurrent`); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		testRepository.pool.Exec(context.Background(), `DROP SCHEMA IF EXISTS concurrent CASCADE`)
+	})
+	cfg := *testCfg
+	cfg.DBSchema = "concurrent"
+
+	migrators := make([]*Migrator, 2)
+	for i := range migrators {
+		pool, err := Connect(ctx, &cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer pool.Close()
+		if migrators[i], err = NewMigrator(pool, migrations.FS); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	errs := make(chan error, len(migrators))
+	for _, migrator := range migrators {
+		go func(migrator *Migrator) { errs <- migrator.Up(ctx) }(migrator)
+	}
+	for range migrators {
+		if err := <-errs; err != nil {
+			t.Errorf("concurrent migration: %v", err)
+		}
+	}
+
+	version, err := migrators[0].AppliedVersion(ctx)
+	if err != nil || version != migrators[0].Latest() {
+		t.Errorf("version = %d, %v, want the latest %d", version, err, migrators[0].Latest())
+	}
+}
+
+func TestAppliedVersion(t *testing.T) {
+	ctx := context.Background()
+
+	version, err := testRepository.migrator.AppliedVersion(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest := testRepository.migrator.Latest(); version != latest {
+		t.Errorf("version = %d, want the latest %d", version, latest)
+	}
+
+	// A schema never migrated is at version 0, and the probe leaves it without schema_migrations
+	if _, err = testRepository.pool.Exec(ctx, `CREATE SCHEMA IF NOT EXISTS unmigrated`); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		testRepository.pool.Exec(context.Background(), `DROP SCHEMA IF EXISTS unmigrated CASCADE`)
+	})
+	cfg := *testCfg
+	cfg.DBSchema = "unmigrated"
+	pool, err := Connect(ctx, &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	migrator, err := NewMigrator(pool, migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if version, err = migrator.AppliedVersion(ctx); err != nil || version != 0 {
+		t.Errorf("unmigrated version = %d, %v, want 0", version, err)
+	}
+	var table *string
+	if err = pool.QueryRow(ctx, `SELECT to_regclass('unmigrated.schema_migrations')::text`).Scan(&table); err != nil {
+		t.Fatal(err)
+	}
+	if table != nil {
+		t.Errorf("the probe created %s", *table)
+	}
+}
+
+func TestHolds(t *testing.T) {
+	r := repository(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	insert(t, r, newOrder("1", "10", "box", 5), newOrder("2", "10", "box", 5), newOrder("3", "10", "film", 1))
+	hold := func(id, operator string, at time.Time) models.Hold {
+		return models.Hold{OrderID: id, Operator: operator, CreatedAt: at, ExpiresAt: at.Add(10 * time.Minute)}
+	}
+
+	if err := r.PlaceHolds(ctx, []models.Hold{hold("1", "anna", now), hold("2", "anna", now)}); err != nil {
+		t.Fatal(err)
+	}
+	// Nothing is held when one of the orders is taken
+	if err := r.PlaceHolds(ctx, []models.Hold{hold("3", "boris", now), hold("1", "boris", now)}); !errors.Is(err, util.ErrOrderHeld) {
+		t.Fatalf("hold of another operator error = %v, want %v", err, util.ErrOrderHeld)
+	}
+	if err := r.PlaceHolds(ctx, []models.Hold{hold("404", "anna", now)}); !errors.Is(err, util.ErrOrderNotFound) {
+		t.Errorf("hold of an unknown order error = %v, want %v", err, util.ErrOrderNotFound)
+	}
+	// The holder extends, anyone takes an expired hold
+	later := now.Add(time.Minute)
+	if err := r.PlaceHolds(ctx, []models.Hold{hold("1", "anna", later)}); err != nil {
+		t.Errorf("extending own hold: %v", err)
+	}
+	expired := now.Add(11 * time.Minute)
+	if err := r.PlaceHolds(ctx, []models.Hold{hold("2", "boris", expired)}); err != nil {
+		t.Errorf("taking an expired hold: %v", err)
+	}
+
+	holds, err := r.GetHolds(ctx, expired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holds) != 2 || holds[0].OrderID != "1" || holds[1].OrderID != "2" || holds[1].Operator != "boris" {
+		t.Errorf("holds = %+v, want anna's extended 1 and boris' 2", holds)
+	}
+	if holds, err = r.GetOrderHolds(ctx, []string{"2", "3"}, expired); err != nil || len(holds) != 1 || holds[0].OrderID != "2" {
+		t.Errorf("GetOrderHolds = %+v, %v, want the hold of 2", holds, err)
+	}
+
+	// Only the holder releases
+	if err = r.ReleaseHolds(ctx, []string{"1", "2"}, "anna"); err != nil {
+		t.Fatal(err)
+	}
+	if holds, _ = r.GetHolds(ctx, expired); len(holds) != 1 || holds[0].OrderID != "2" {
+		t.Errorf("holds after anna released = %+v, want boris' 2", holds)
+	}
+
+	// The hold stops the writes of other operators even after their validation passed
+	anna := auth.WithOperator(ctx, models.Operator{Login: "anna"})
+	boris := auth.WithOperator(ctx, models.Operator{Login: "boris"})
+	if err = r.PlaceHolds(ctx, []models.Hold{hold("3", "boris", expired)}); err != nil {
+		t.Fatal(err)
+	}
+	issued := newOrder("2", "10", "box", 5)
+	issued.Issued, issued.IssuedAt = true, expired
+	payment := cardPayment([]models.Order{*issued})
+	payment.CreatedAt = expired
+	if err = r.IssueUpdate(anna, []models.Order{*issued}, payment); !errors.Is(err, util.ErrOrderHeld) {
+		t.Errorf("issue of a held order error = %v, want %v", err, util.ErrOrderHeld)
+	}
+	returned := newOrder("3", "10", "film", 1)
+	returned.Returned = true
+	refund := &models.Refund{OrderID: "3", UserID: "10", Operator: "anna", CreatedAt: expired, Method: models.PaymentCash}
+	if err = r.Update(anna, *returned, refund); !errors.Is(err, util.ErrOrderHeld) {
+		t.Errorf("return of a held order error = %v, want %v", err, util.ErrOrderHeld)
+	}
+	if err = r.Delete(anna, "3", expired); !errors.Is(err, util.ErrOrderHeld) {
+		t.Errorf("courier return of a held order error = %v, want %v", err, util.ErrOrderHeld)
+	}
+	if holds, _ = r.GetHolds(ctx, expired); len(holds) != 2 {
+		t.Errorf("holds after the refused writes = %+v, want boris' 2 and 3", holds)
+	}
+
+	// The holder's issue uses the hold up
+	if err = r.IssueUpdate(boris, []models.Order{*issued}, payment); err != nil {
+		t.Fatal(err)
+	}
+	if holds, _ = r.GetHolds(ctx, expired); len(holds) != 1 || holds[0].OrderID != "3" {
+		t.Errorf("holds after issue = %+v, want boris' 3", holds)
+	}
+}
+
+// TestHoldPlacedDuringIssue places the first hold of an order in a transaction that commits while an issue
+// of the order waits for the lock, the issue has to see the hold
+func TestHoldPlacedDuringIssue(t *testing.T) {
+	r := repository(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	insert(t, r, newOrder("1", "10", "box", 5))
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback(ctx)
+	if err = lockOrders(ctx, tx, []string{"1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tx.Exec(ctx, placeHoldQuery, "1", "boris", now, now.Add(10*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	issued := newOrder("1", "10", "box", 5)
+	issued.Issued, issued.IssuedAt = true, now
+	payment := cardPayment([]models.Order{*issued})
+	payment.CreatedAt = now
+	done := make(chan error, 1)
+	go func() {
+		done <- r.IssueUpdate(auth.WithOperator(ctx, models.Operator{Login: "anna"}), []models.Order{*issued}, payment)
+	}()
+	select {
+	case err = <-done:
+		t.Fatalf("IssueUpdate = %v before the hold committed, want it waiting for the order", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err = <-done; !errors.Is(err, util.ErrOrderHeld) {
+		t.Errorf("issue error = %v, want %v", err, util.ErrOrderHeld)
+	}
+}
+
+func TestClaimNotification(t *testing.T) {
+	r := repository(t)
+	ctx := context.Background()
+
+	insert(t, r, newOrder("1", "10", "film", 1))
+	notification := models.Notification{OrderID: "1", UserID: "10", Event: models.EventAccepted, Channel: "file", Message: "accepted"}
+
+	claim := func(want bool) {
+		t.Helper()
+		claimed, err := r.ClaimNotification(ctx, notification, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if claimed != want {
+			t.Fatalf("claimed = %v, want %v", claimed, want)
+		}
+	}
+
+	claim(true)
+	// A pending notification belongs to whoever claimed it
+	claim(false)
+
+	notification.Status, notification.Error = models.NotificationFailed, "channel down"
+	if err := r.UpdateNotification(ctx, notification); err != nil {
+		t.Fatal(err)
+	}
+	failed, err := r.GetFailedNotifications(ctx, 3, time.Hour, 10)
+	if err != nil || len(failed) != 1 || failed[0].Attempts != 1 {
+		t.Fatalf("failed notifications = %+v, %v, want the one with 1 attempt", failed, err)
+	}
+
+	// A failed one is claimed again for the retry
+	claim(true)
+	sentAt := time.Now()
+	notification.Status, notification.Error, notification.SentAt = models.NotificationSent, "", &sentAt
+	if err = r.UpdateNotification(ctx, notification); err != nil {
+		t.Fatal(err)
+	}
+	claim(false)
+
+	notifications, err := r.GetNotifications(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notifications) != 1 || notifications[0].Status != models.NotificationSent || notifications[0].Attempts != 2 {
+		t.Errorf("notifications = %+v, want one sent on the second attempt", notifications)
+	}
+
+	// A notification left pending past the lease lost its sender, the sweeper sees it as failed and claims it again
+	expiring := notification
+	expiring.Event, expiring.Status, expiring.SentAt = models.EventExpiring, "", nil
+	if claimed, err := r.ClaimNotification(ctx, expiring, time.Hour); err != nil || !claimed {
+		t.Fatalf("claim of the expiring notification = %v, %v, want claimed", claimed, err)
+	}
+	if failed, err = r.GetFailedNotifications(ctx, 3, time.Hour, 10); err != nil || len(failed) != 0 {
+		t.Errorf("failed within the lease = %+v, %v, want none", failed, err)
+	}
+	if failed, err = r.GetFailedNotifications(ctx, 3, 0, 10); err != nil || len(failed) != 1 || failed[0].Event != models.EventExpiring {
+		t.Errorf("failed past the lease = %+v, %v, want the pending expiring one", failed, err)
+	}
+	if claimed, err := r.ClaimNotification(ctx, expiring, 0); err != nil || !claimed {
+		t.Errorf("claim past the lease = %v, %v, want claimed", claimed, err)
+	}
+}
+
+func TestIdempotencyKeys(t *testing.T) {
+	r := repository(t)
+	ctx := context.Background()
+
+	record := models.IdempotencyRecord{
+		Operator:    "anna",
+		Key:         "key-1",
+		Command:     "accept",
+		Fingerprint: "fingerprint",
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+
+	first, claimed, err := r.ClaimIdempotencyKey(ctx, record, time.Hour)
+	if err != nil || !claimed {
+		t.Fatalf("first claim = %v, %v, want claimed", claimed, err)
+	}
+	stored, claimed, err := r.ClaimIdempotencyKey(ctx, record, time.Hour)
+	if err != nil || claimed || stored.Status != models.IdempotencyPending {
+		t.Fatalf("second claim = %+v, %v, %v, want the pending record", stored, claimed, err)
+	}
+
+	first.Response = []byte(`{"id":"1"}`)
+	if completed, err := r.CompleteIdempotencyKey(ctx, first); err != nil || !completed {
+		t.Fatalf("complete = %v, %v, want completed", completed, err)
+	}
+	stored, claimed, err = r.ClaimIdempotencyKey(ctx, record, time.Hour)
+	if err != nil || claimed || stored.Status != models.IdempotencyDone || string(stored.Response) != `{"id":"1"}` {
+		t.Fatalf("claim after completion = %+v, %v, %v, want the stored response", stored, claimed, err)
+	}
+
+	// A done key is no claim to release
+	if released, err := r.ReleaseIdempotencyKey(ctx, first); err != nil || released {
+		t.Fatalf("release of a done key = %v, %v, want it kept", released, err)
+	}
+
+	pending := record
+	pending.Key = "key-4"
+	pending, claimed, err = r.ClaimIdempotencyKey(ctx, pending, time.Hour)
+	if err != nil || !claimed {
+		t.Fatalf("claim of key-4 = %v, %v, want claimed", claimed, err)
+	}
+	if released, err := r.ReleaseIdempotencyKey(ctx, pending); err != nil || !released {
+		t.Fatalf("release = %v, %v, want released", released, err)
+	}
+	if _, claimed, err = r.ClaimIdempotencyKey(ctx, pending, time.Hour); err != nil || !claimed {
+		t.Fatalf("claim after release = %v, %v, want claimed", claimed, err)
+	}
+
+	expired := record
+	expired.Key, expired.ExpiresAt = "key-2", time.Now().Add(-time.Minute)
+	if _, claimed, err = r.ClaimIdempotencyKey(ctx, expired, time.Hour); err != nil || !claimed {
+		t.Fatalf("claim of an expired key = %v, %v, want claimed", claimed, err)
+	}
+	if _, claimed, err = r.ClaimIdempotencyKey(ctx, expired, time.Hour); err != nil || !claimed {
+		t.Fatalf("second claim of an expired key = %v, %v, want claimed again", claimed, err)
+	}
+	deleted, err := r.DeleteExpiredIdempotencyKeys(ctx)
+	if err != nil || deleted != 1 {
+		t.Errorf("deleted = %d, %v, want the expired key", deleted, err)
+	}
+
+	// A key left pending past the lease was claimed by a process that died, it is taken over
+	abandoned := record
+	abandoned.Key = "key-3"
+	lost, claimed, err := r.ClaimIdempotencyKey(ctx, abandoned, time.Hour)
+	if err != nil || !claimed {
+		t.Fatalf("claim of key-3 = %v, %v, want claimed", claimed, err)
+	}
+	taken, claimed, err := r.ClaimIdempotencyKey(ctx, abandoned, 0)
+	if err != nil || !claimed {
+		t.Fatalf("claim of a pending key past its lease = %v, %v, want claimed", claimed, err)
+	}
+	// The first claimant finishing late must not overwrite the claim that took over
+	lost.Response = []byte(`{"id":"lost"}`)
+	if completed, err := r.CompleteIdempotencyKey(ctx, lost); err != nil || completed {
+		t.Fatalf("complete of a lost claim = %v, %v, want it refused", completed, err)
+	}
+	if released, err := r.ReleaseIdempotencyKey(ctx, lost); err != nil || released {
+		t.Fatalf("release of a lost claim = %v, %v, want it refused", released, err)
+	}
+	taken.Response = []byte(`{"id":"3"}`)
+	if completed, err := r.CompleteIdempotencyKey(ctx, taken); err != nil || !completed {
+		t.Fatalf("complete of the claim that took over = %v, %v, want completed", completed, err)
+	}
+	if _, claimed, err = r.ClaimIdempotencyKey(ctx, abandoned, 0); err != nil || claimed {
+		t.Errorf("claim of a done key = %v, %v, want the lease to only free pending keys", claimed, err)
+	}
+}
+
+func TestProcessOutbox(t *testing.T) {
+	r := repository(t)
+	ctx := context.Background()
+
+	insert(t, r, newOrder("1", "10", "film", 1), newOrder("2", "10", "film", 1))
+
+	var seen []string
+	published, err := r.ProcessOutbox(ctx, 10, func(ctx context.Context, events []models.OutboxEvent) map[int64]error {
+		results := make(map[int64]error, len(events))
+		for _, event := range events {
+			seen = append(seen, event.OrderID)
+			results[event.ID] = nil
+			if event.OrderID == "2" {
+				results[event.ID] = errors.New("broker down")
+			}
+		}
+		return results
+	})
+	if err != nil || published != 1 {
+		t.Fatalf("published = %d, %v, want 1", published, err)
+	}
+	if !slices.Equal(seen, []string{"1", "2"}) {
+		t.Errorf("events in order %v, want [1 2]", seen)
+	}
+
+	var retried []models.OutboxEvent
+	published, err = r.ProcessOutbox(ctx, 10, func(ctx context.Context, events []models.OutboxEvent) map[int64]error {
+		retried = events
+		return map[int64]error{events[0].ID: nil}
+	})
+	if err != nil || published != 1 {
+		t.Fatalf("retry published = %d, %v, want 1", published, err)
+	}
+	if len(retried) != 1 || retried[0].OrderID != "2" || retried[0].Attempts != 1 || retried[0].LastError != "broker down" {
+		t.Errorf("retried %+v, want the failed event with its error", retried)
+	}
+
+	if published, err = r.ProcessOutbox(ctx, 10, nil); err != nil || published != 0 {
+		t.Errorf("empty outbox published = %d, %v", published, err)
+	}
+}
+
+func TestFind(t *testing.T) {
+	r := repository(t)
+	ctx := context.Background()
+
+	// 1 and 12 wait in the pickup point, 13 was issued, 2 issued and returned
+	kept := newOrder("1", "10", "box", 5)
+	cheap := newOrder("12", "20", "film", 1)
+	cheap.OrderPrice, cheap.StorageUntil = 50, storageUntil.Add(24*time.Hour)
+	issued := newOrder("13", "10", "packet", 2)
+	returned := newOrder("2", "10", "packet", 3)
+	insert(t, r, kept, cheap, issued, returned)
+
+	issuedAt := time.Date(2029, 12, 30, 15, 0, 0, 0, time.UTC)
+	issued.Issued, issued.IssuedAt = true, issuedAt
+	returned.Issued, returned.IssuedAt = true, issuedAt.Add(time.Hour)
+	if err := issue(ctx, r, *issued, *returned); err != nil {
+		t.Fatal(err)
+	}
+	returned.Returned = true
+	if err := returnOrder(ctx, r, *returned); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter models.OrderFilter
+		want   []string
+	}{
+		{name: "everything by id", filter: models.OrderFilter{Limit: 10}, want: []string{"1", "12", "13", "2"}},
+		{name: "stored", filter: models.OrderFilter{Status: models.StatusStored, Limit: 10}, want: []string{"1", "12"}},
+		{name: "issued", filter: models.OrderFilter{Status: models.StatusIssued, Limit: 10}, want: []string{"13"}},
+		{name: "returned", filter: models.OrderFilter{Status: models.StatusReturned, Limit: 10}, want: []string{"2"}},
+		{name: "id prefix", filter: models.OrderFilter{IDPrefix: "1", Limit: 10}, want: []string{"1", "12", "13"}},
+		{name: "like wildcards are literal", filter: models.OrderFilter{IDPrefix: "_", Limit: 10}, want: []string{}},
+		{name: "user", filter: models.OrderFilter{UserID: "10", Limit: 10}, want: []string{"1", "13", "2"}},
+		{name: "package", filter: models.OrderFilter{PackageType: "packet", Limit: 10}, want: []string{"13", "2"}},
+		{
+			name:   "storage range excludes its end",
+			filter: models.OrderFilter{StorageFrom: storageUntil, StorageTo: storageUntil.Add(24 * time.Hour), Limit: 10},
+			want:   []string{"1", "13", "2"},
+		},
+		{
+			name:   "issued range skips orders never issued",
+			filter: models.OrderFilter{IssuedTo: issuedAt.Add(time.Minute), Limit: 10},
+			want:   []string{"13"},
+		},
+		{name: "price range", filter: models.OrderFilter{PriceMin: 50, PriceMax: 99, Limit: 10}, want: []string{"12"}},
+		{name: "weight range", filter: models.OrderFilter{WeightMin: 2, WeightMax: 5, Limit: 10}, want: []string{"1", "13", "2"}},
+		{name: "sorted by weight", filter: models.OrderFilter{SortBy: "weight", Limit: 10}, want: []string{"12", "13", "2", "1"}},
+		{name: "sorted by price descending", filter: models.OrderFilter{SortBy: "order_price", Desc: true, Limit: 10}, want: []string{"1", "13", "2", "12"}},
+		{name: "page", filter: models.OrderFilter{SortBy: "weight", Offset: 1, Limit: 2}, want: []string{"13", "2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orders, err := r.Find(ctx, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ids := orderIDs(orders); !slices.Equal(ids, tt.want) {
+				t.Errorf("got %v, want %v", ids, tt.want)
+			}
+		})
+	}
+
+	if _, err := r.Find(ctx, models.OrderFilter{SortBy: "hash; --"}); !errors.Is(err, util.ErrSortColumnInvalid) {
+		t.Errorf("unknown column error = %v, want %v", err, util.ErrSortColumnInvalid)
+	}
+}
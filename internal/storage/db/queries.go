@@ -0,0 +1,66 @@
+package db
+
+// Query is a statement the repository sends, Name is stable so a stored plan baseline can refer to it
+type Query struct {
+	Name string
+	SQL  string
+}
+
+// Queries lists every statement the repository issues, cmd/explain runs each of them against a seeded schema.
+// A new query has to be added here, the tool refuses to run while it has no arguments for one of them
+func Queries() []Query {
+	return []Query{
+		{Name: "allocateCell", SQL: allocateCellQuery},
+		{Name: "ensureUser", SQL: ensureUserQuery},
+		{Name: "insertOrder", SQL: insertOrderQuery},
+		{Name: "audit", SQL: auditQuery},
+		{Name: "enqueue", SQL: outboxQuery},
+		{Name: "releaseCell", SQL: releaseCellQuery},
+		{Name: "returnOrder", SQL: returnOrderQuery},
+		{Name: "issueOrder", SQL: issueOrderQuery},
+		{Name: "dropHold", SQL: dropHoldQuery},
+		{Name: "insertPayment", SQL: insertPaymentQuery},
+		{Name: "getOrderPayment", SQL: getOrderPaymentQuery},
+		{Name: "insertRefund", SQL: insertRefundQuery},
+		{Name: "getRefunds", SQL: getRefundsQuery},
+		{Name: "getUserRefunds", SQL: getUserRefundsQuery},
+		{Name: "deleteOrder", SQL: deleteOrderQuery},
+		{Name: "getOrder", SQL: getOrderQuery},
+		{Name: "getReturns", SQL: getReturnsQuery},
+		{Name: "getOrders", SQL: getOrdersQuery},
+		{Name: "findByUser", SQL: findByUserQuery},
+		{Name: "findByPrefix", SQL: findByPrefixQuery},
+		{Name: "getExpiring", SQL: getExpiringQuery},
+		{Name: "getCells", SQL: getCellsQuery},
+		{Name: "insertUser", SQL: insertUserQuery},
+		{Name: "getUser", SQL: getUserQuery},
+		{Name: "getUserOrders", SQL: getUserOrdersQuery},
+		{Name: "insertCourier", SQL: insertCourierQuery},
+		{Name: "getCourier", SQL: getCourierQuery},
+		{Name: "getCourierReturnable", SQL: getCourierReturnableQuery},
+		{Name: "deleteReturnable", SQL: deleteReturnableQuery},
+		{Name: "insertManifest", SQL: insertManifestQuery},
+		{Name: "placeHold", SQL: placeHoldQuery},
+		{Name: "releaseHolds", SQL: releaseHoldsQuery},
+		{Name: "heldByOthers", SQL: heldByOthersQuery},
+		{Name: "lockOrders", SQL: lockOrdersQuery},
+		{Name: "getHolds", SQL: getHoldsQuery},
+		{Name: "getOrderHolds", SQL: getOrderHoldsQuery},
+		{Name: "insertOperator", SQL: insertOperatorQuery},
+		{Name: "getOperator", SQL: getOperatorQuery},
+		{Name: "getOperatorByKey", SQL: getOperatorByKeyQuery},
+		{Name: "claimNotification", SQL: claimNotificationQuery},
+		{Name: "updateNotification", SQL: updateNotificationQuery},
+		{Name: "getNotifications", SQL: getNotificationsQuery},
+		{Name: "getFailedNotifications", SQL: getFailedNotificationsQuery},
+		{Name: "outboxLock", SQL: outboxLockQuery},
+		{Name: "unpublishedOutbox", SQL: unpublishedOutboxQuery},
+		{Name: "outboxPublished", SQL: outboxPublishedQuery},
+		{Name: "outboxFailed", SQL: outboxFailedQuery},
+		{Name: "claimIdempotencyKey", SQL: claimIdempotencyKeyQuery},
+		{Name: "getIdempotencyKey", SQL: getIdempotencyKeyQuery},
+		{Name: "completeIdempotencyKey", SQL: completeIdempotencyKeyQuery},
+		{Name: "releaseIdempotencyKey", SQL: releaseIdempotencyKeyQuery},
+		{Name: "deleteExpiredIdempotencyKeys", SQL: deleteExpiredIdempotencyKeysQuery},
+	}
+}
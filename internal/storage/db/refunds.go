@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"homework/internal/models"
+)
+
+// insertRefundQuery runs in the return transaction, NULLIF keeps a refund without a payment unlinked
+const insertRefundQuery = `
+		INSERT INTO refunds (payment_id, order_id, user_id, operator, created_at, method, goods, package_price, withheld, amount)
+		VALUES (NULLIF($1, 0), $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id
+		`
+
+func insertRefundArgs(refund models.Refund) []any {
+	return []any{refund.PaymentID, refund.OrderID, refund.UserID, refund.Operator, refund.CreatedAt, refund.Method,
+		refund.Goods, refund.Package, refund.Withheld, refund.Amount}
+}
+
+const refundColumns = `id, COALESCE(payment_id, 0) AS payment_id, order_id, user_id, operator, created_at, method, goods, package_price, withheld, amount`
+
+const getRefundsQuery = `
+		SELECT ` + refundColumns + `
+		FROM refunds
+		ORDER BY created_at DESC, id DESC
+		OFFSET $1
+		FETCH NEXT $2 ROWS ONLY
+		`
+
+const getUserRefundsQuery = `
+		SELECT ` + refundColumns + `
+		FROM refunds
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		OFFSET $2
+		FETCH NEXT $3 ROWS ONLY
+		`
+
+// GetRefunds returns a page of the refunds, the latest first, only the ones of the customer unless userID is empty
+func (r *Repository) GetRefunds(ctx context.Context, userID string, offset, limit int) ([]models.Refund, error) {
+	query, args := getRefundsQuery, []any{offset, limit}
+	if len(userID) > 0 {
+		query, args = getUserRefundsQuery, []any{userID, offset, limit}
+	}
+
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		logQueryError(ctx, "GetRefunds", err)
+		return nil, storageError(err)
+	}
+	defer rows.Close()
+
+	var refunds []models.Refund
+	if err := pgxscan.ScanAll(&refunds, rows); err != nil {
+		return nil, err
+	}
+	return refunds, nil
+}
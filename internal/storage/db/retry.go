@@ -0,0 +1,89 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5/pgconn"
+	"homework/internal/models"
+	"homework/internal/util"
+	"homework/pkg/retry"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	serializationFailure = "40001"
+	deadlockDetected     = "40P01"
+	tooManyConnections   = "53300"
+	adminShutdown        = "57P01"
+	crashShutdown        = "57P02"
+	cannotConnectNow     = "57P03"
+	// connectionException is the class of 08xxx codes, e.g. 08006 connection_failure
+	connectionException = "08"
+)
+
+// errConnectTimeout replaces the deadline error of a single connection attempt, unlike it it's worth retrying
+var errConnectTimeout = errors.New("db connection timed out")
+
+// IsTransient tells whether the error may go away if the operation is repeated:
+// lost connections, serialization failures and deadlocks
+func IsTransient(err error) bool {
+	if errors.Is(err, errConnectTimeout) {
+		return true
+	}
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case serializationFailure, deadlockDetected, tooManyConnections, adminShutdown, crashShutdown, cannotConnectNow:
+			return true
+		}
+		return strings.HasPrefix(pgErr.Code, connectionException)
+	}
+
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) {
+		return true
+	}
+	if pgconn.SafeToRetry(err) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// NewRetryPolicy builds the backoff used for connecting and for repository operations
+func NewRetryPolicy(cfg *models.Config) retry.Policy {
+	return retry.Policy{
+		Attempts:     cfg.Attempts,
+		InitialDelay: cfg.RetryDelay,
+		MaxDelay:     cfg.RetryMaxDelay,
+		Multiplier:   2,
+		Jitter:       0.2,
+		Retryable:    IsTransient,
+	}
+}
+
+// withRetry repeats a whole operation, transactions included, while it fails with a transient error
+func (r *Repository) withRetry(ctx context.Context, op string, fn func(ctx context.Context) error) error {
+	policy := r.retryPolicy
+	policy.OnRetry = func(attempt int, delay time.Duration, err error) {
+		slog.WarnContext(ctx, "transient db error, retrying", "op", op, "attempt", attempt, "delay", delay, "err", err)
+	}
+	return storageError(retry.Do(ctx, policy, fn))
+}
+
+// storageError marks transient failures as an outage, the services must not read them as a missing row
+func storageError(err error) error {
+	if IsTransient(err) {
+		return util.ErrStorageUnavailable.Wrap(err)
+	}
+	return err
+}
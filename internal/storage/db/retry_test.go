@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
+	"homework/internal/util"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestIsTransient(t *testing.T) {
+	// A real failed dial, ConnectError can't be built outside pgconn
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, connectErr := pgconn.Connect(ctx, "postgres://nobody@127.0.0.1:1/none?connect_timeout=1")
+	var asConnect *pgconn.ConnectError
+	if !errors.As(connectErr, &asConnect) {
+		t.Fatalf("dial error %T, want a ConnectError", connectErr)
+	}
+
+	pg := func(code string) error { return &pgconn.PgError{Code: code} }
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "serialization failure", err: pg("40001"), want: true},
+		{name: "deadlock", err: pg("40P01"), want: true},
+		{name: "connection failure", err: pg("08006"), want: true},
+		{name: "connection exception", err: pg("08000"), want: true},
+		{name: "admin shutdown", err: pg("57P01"), want: true},
+		{name: "crash shutdown", err: pg("57P02"), want: true},
+		{name: "cannot connect now", err: pg("57P03"), want: true},
+		{name: "too many connections", err: pg("53300"), want: true},
+		{name: "wrapped serialization failure", err: fmt.Errorf("issue: %w", pg("40001")), want: true},
+		{name: "connect error", err: connectErr, want: true},
+		{name: "connect timeout", err: fmt.Errorf("%w after 5s", errConnectTimeout), want: true},
+		{name: "connection closed", err: io.ErrUnexpectedEOF, want: true},
+		{name: "unique violation", err: pg("23505"), want: false},
+		{name: "foreign key violation", err: pg("23503"), want: false},
+		{name: "canceled", err: context.Canceled, want: false},
+		{name: "canceled query", err: fmt.Errorf("query: %w", context.Canceled), want: false},
+		{name: "deadline", err: context.DeadlineExceeded, want: false},
+		{name: "domain error", err: util.ErrOrderNotFound, want: false},
+		{name: "nil", err: nil, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTransient(tt.err); got != tt.want {
+				t.Errorf("IsTransient = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStorageError(t *testing.T) {
+	if err := storageError(&pgconn.PgError{Code: "40001"}); !errors.Is(err, util.ErrStorageUnavailable) {
+		t.Errorf("transient error = %v, want %v", err, util.ErrStorageUnavailable)
+	}
+	unique := &pgconn.PgError{Code: "23505"}
+	if err := storageError(unique); err != unique {
+		t.Errorf("permanent error = %v, want it unchanged", err)
+	}
+}
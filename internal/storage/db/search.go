@@ -0,0 +1,144 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"homework/internal/models"
+	"homework/internal/util"
+	"slices"
+	"strings"
+	"time"
+)
+
+const findOrdersSelect = `
+		SELECT id, user_id, storage_until, issued, issued_at, returned, order_price, weight, package_type, package_price, hash, COALESCE(cell_id, '') AS cell_id, COALESCE(courier_id, '') AS courier_id
+		FROM orders`
+
+// likeEscaper makes an id prefix match literally, backslash is the default LIKE escape
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// findQuery renders the filter as one statement with only the conditions it sets, so the planner sees
+// the actual search instead of a generic one with every condition switched off by a NULL
+func findQuery(filter models.OrderFilter) (string, []any, error) {
+	sortBy := filter.SortBy
+	if len(sortBy) == 0 {
+		sortBy = "id"
+	}
+	// The column goes into the statement text, only the known ones may get there
+	if !slices.Contains(models.SortColumns, sortBy) {
+		return "", nil, util.ErrSortColumnInvalid
+	}
+
+	var (
+		conditions []string
+		args       []any
+	)
+	where := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	switch filter.Status {
+	case models.StatusStored:
+		conditions = append(conditions, "issued = FALSE", "returned = FALSE")
+	case models.StatusIssued:
+		conditions = append(conditions, "issued = TRUE", "returned = FALSE")
+	case models.StatusReturned:
+		conditions = append(conditions, "returned = TRUE")
+	}
+	if len(filter.IDPrefix) > 0 {
+		// orders_id_pattern serves the prefix, a short one matches too many ids and the planner may still scan the table
+		where("id LIKE $%d", likeEscaper.Replace(filter.IDPrefix)+"%")
+	}
+	if len(filter.UserID) > 0 {
+		where("user_id = $%d", filter.UserID)
+	}
+	if len(filter.PackageType) > 0 {
+		where("package_type = $%d", filter.PackageType)
+	}
+	if !filter.StorageFrom.IsZero() {
+		where("storage_until >= $%d", filter.StorageFrom)
+	}
+	if !filter.StorageTo.IsZero() {
+		where("storage_until < $%d", filter.StorageTo)
+	}
+	// An order that wasn't issued keeps a zero issued_at, it must not fall into an open range
+	if !filter.IssuedFrom.IsZero() || !filter.IssuedTo.IsZero() {
+		conditions = append(conditions, "issued = TRUE")
+	}
+	if !filter.IssuedFrom.IsZero() {
+		where("issued_at >= $%d", filter.IssuedFrom)
+	}
+	if !filter.IssuedTo.IsZero() {
+		where("issued_at < $%d", filter.IssuedTo)
+	}
+	if filter.PriceMin > 0 {
+		where("order_price >= $%d", filter.PriceMin)
+	}
+	if filter.PriceMax > 0 {
+		where("order_price <= $%d", filter.PriceMax)
+	}
+	if filter.WeightMin > 0 {
+		where("weight >= $%d", filter.WeightMin)
+	}
+	if filter.WeightMax > 0 {
+		where("weight <= $%d", filter.WeightMax)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(findOrdersSelect)
+	if len(conditions) > 0 {
+		sb.WriteString("\n\t\tWHERE ")
+		sb.WriteString(strings.Join(conditions, " AND "))
+	}
+
+	direction := "ASC"
+	if filter.Desc {
+		direction = "DESC"
+	}
+	fmt.Fprintf(&sb, "\n\t\tORDER BY %s %s", sortBy, direction)
+	if sortBy != "id" {
+		sb.WriteString(", id")
+	}
+
+	args = append(args, filter.Offset, filter.Limit)
+	fmt.Fprintf(&sb, "\n\t\tOFFSET $%d\n\t\tFETCH NEXT $%d ROWS ONLY\n\t", len(args)-1, len(args))
+	return sb.String(), args, nil
+}
+
+// Find returns a page of the orders matching the filter
+func (r *Repository) Find(ctx context.Context, filter models.OrderFilter) ([]models.Order, error) {
+	query, args, err := findQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		logQueryError(ctx, "Find", err)
+		return nil, storageError(err)
+	}
+	defer rows.Close()
+
+	var orders []models.Order
+	if err := pgxscan.ScanAll(&orders, rows); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// The searches cmd/explain checks, only the set fields matter and their arguments come in the order of findQuery:
+// the user, the storage range, the offset and the limit for the first, the id prefix and the price range for the second
+var (
+	findByUserQuery   = mustFindQuery(models.OrderFilter{Status: models.StatusStored, UserID: "-", StorageFrom: time.Unix(0, 0), StorageTo: time.Unix(0, 0), SortBy: "storage_until"})
+	findByPrefixQuery = mustFindQuery(models.OrderFilter{IDPrefix: "-", PriceMin: 1, PriceMax: 1})
+)
+
+func mustFindQuery(filter models.OrderFilter) string {
+	query, _, err := findQuery(filter)
+	if err != nil {
+		panic(err)
+	}
+	return query
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"homework/internal/models"
+	"homework/internal/util"
+)
+
+const insertUserQuery = `
+		INSERT INTO users (id, name, phone, created_at)
+		VALUES ($1, $2, $3, $4)
+		`
+
+func (r *Repository) InsertUser(ctx context.Context, user models.User) error {
+	_, err := r.pool.Exec(ctx, insertUserQuery, user.ID, user.Name, user.Phone, user.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return util.ErrUserExists
+		}
+		logQueryError(ctx, "InsertUser", err)
+		return storageError(err)
+	}
+	return nil
+}
+
+// ensureUserQuery adds the customer of an accepted order unless they are known already,
+// whether an unknown one may be accepted at all is up to the validation
+const ensureUserQuery = `
+		INSERT INTO users (id) VALUES ($1)
+		ON CONFLICT (id) DO NOTHING
+		`
+
+const getUserQuery = `
+		SELECT id, name, phone, created_at FROM users
+		WHERE id=$1
+		`
+
+func (r *Repository) GetUser(ctx context.Context, id string) (models.User, error) {
+	var user models.User
+	if err := pgxscan.Get(ctx, r.pool, &user, getUserQuery, id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.User{}, util.ErrUserNotFound
+		}
+		logQueryError(ctx, "GetUser", err)
+		return models.User{}, storageError(err)
+	}
+	return user, nil
+}
+
+const getUserOrdersQuery = `
+		SELECT id, user_id, storage_until, issued, issued_at, returned, order_price, weight, package_type, package_price, hash, COALESCE(cell_id, '') AS cell_id, COALESCE(courier_id, '') AS courier_id
+		FROM orders
+		WHERE user_id = $1
+		ORDER BY storage_until, id
+	`
+
+// GetUserOrders returns every order of the customer, stored, issued and returned alike
+func (r *Repository) GetUserOrders(ctx context.Context, userID string) ([]models.Order, error) {
+	rows, err := r.pool.Query(ctx, getUserOrdersQuery, userID)
+	if err != nil {
+		logQueryError(ctx, "GetUserOrders", err)
+		return nil, storageError(err)
+	}
+	defer rows.Close()
+
+	var orders []models.Order
+	if err := pgxscan.ScanAll(&orders, rows); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
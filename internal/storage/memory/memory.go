@@ -0,0 +1,520 @@
+// Package memory keeps the pickup point in maps, it behaves like the postgres repository for a single process
+// and is used where a database is not worth having: load generation and local experiments
+package memory
+
+import (
+	"context"
+	"fmt"
+	"homework/internal/auth"
+	"homework/internal/models"
+	"homework/internal/storage"
+	"homework/internal/util"
+	"sort"
+	"sync"
+	"time"
+)
+
+var _ storage.Storage = (*Repository)(nil)
+
+type notificationKey struct {
+	orderID string
+	event   models.NotificationEvent
+	channel string
+}
+
+type idempotencyKey struct {
+	operator string
+	key      string
+}
+
+type Repository struct {
+	mu        sync.RWMutex
+	orders    map[string]models.Order
+	users     map[string]models.User
+	couriers  map[string]models.Courier
+	manifests []models.Manifest
+	holds     map[string]models.Hold
+	payments  []models.Payment
+	// paymentOrders links an issued order to its payment
+	paymentOrders map[string]int64
+	refunds       []models.Refund
+	cells         []models.Cell
+	operators     map[string]models.Operator
+	notifications map[notificationKey]models.Notification
+	idempotency   map[idempotencyKey]models.IdempotencyRecord
+	outbox        []models.OutboxEvent
+}
+
+// NewRepository starts with the given cells and no orders
+func NewRepository(cells []models.Cell) *Repository {
+	cellsCopy := make([]models.Cell, len(cells))
+	copy(cellsCopy, cells)
+	sort.Slice(cellsCopy, func(i, k int) bool {
+		if cellsCopy[i].Shelf != cellsCopy[k].Shelf {
+			return cellsCopy[i].Shelf < cellsCopy[k].Shelf
+		}
+		return cellsCopy[i].ID < cellsCopy[k].ID
+	})
+
+	return &Repository{
+		orders:        make(map[string]models.Order),
+		users:         make(map[string]models.User),
+		couriers:      make(map[string]models.Courier),
+		holds:         make(map[string]models.Hold),
+		paymentOrders: make(map[string]int64),
+		cells:         cellsCopy,
+		operators:     make(map[string]models.Operator),
+		notifications: make(map[notificationKey]models.Notification),
+		idempotency:   make(map[idempotencyKey]models.IdempotencyRecord),
+	}
+}
+
+// DefaultCells lays out the shelves like the cells migrations do, perShelf cells on each of them
+func DefaultCells(perShelf int) []models.Cell {
+	shelves := []struct {
+		shelf       string
+		packageType models.PackageType
+		maxWeight   models.Weight
+		maxOrders   int
+	}{
+		{"A", "film", 0, 10},
+		{"B", "packet", 30, 3},
+		{"C", "box", 60, 2},
+	}
+
+	cells := make([]models.Cell, 0, perShelf*len(shelves))
+	for _, s := range shelves {
+		for n := 1; n <= perShelf; n++ {
+			cells = append(cells, models.Cell{
+				ID:          fmt.Sprintf("%s-%02d", s.shelf, n),
+				Shelf:       s.shelf,
+				PackageType: s.packageType,
+				MaxWeight:   s.maxWeight,
+				MaxOrders:   s.maxOrders,
+			})
+		}
+	}
+	return cells
+}
+
+func (r *Repository) Insert(ctx context.Context, order *models.Order) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.orders[order.ID]; ok {
+		return util.ErrOrderExists
+	}
+	cell := r.freeCell(order)
+	if cell == nil {
+		return util.ErrNoFreeCell
+	}
+	cell.UsedWeight += order.Weight
+	cell.OrdersCount++
+	order.CellID = cell.ID
+
+	if _, ok := r.users[order.UserID]; !ok {
+		r.users[order.UserID] = models.User{ID: order.UserID, CreatedAt: time.Now()}
+	}
+	r.orders[order.ID] = *order
+	r.enqueue(order.ID, models.OrderAccepted, *order)
+	return nil
+}
+
+// freeCell returns the first cell in shelf order that fits the order, r.mu must be held
+func (r *Repository) freeCell(order *models.Order) *models.Cell {
+	for i := range r.cells {
+		cell := &r.cells[i]
+		fits := cell.MaxWeight == 0 || cell.UsedWeight+order.Weight <= cell.MaxWeight
+		if cell.PackageType == order.PackageType && fits && cell.OrdersCount < cell.MaxOrders {
+			return cell
+		}
+	}
+	return nil
+}
+
+// releaseCell frees the room taken by the order, r.mu must be held
+func (r *Repository) releaseCell(order *models.Order) {
+	if len(order.CellID) == 0 {
+		return
+	}
+	for i := range r.cells {
+		if r.cells[i].ID == order.CellID {
+			r.cells[i].UsedWeight -= order.Weight
+			r.cells[i].OrdersCount--
+			break
+		}
+	}
+	order.CellID = ""
+}
+
+func (r *Repository) Update(ctx context.Context, order models.Order, refund *models.Refund) error {
+	if refund == nil {
+		return util.ErrInternal.Wrap(fmt.Errorf("return of order %s without a refund", order.ID))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.checkHolds([]string{order.ID}, auth.Actor(ctx), refund.CreatedAt); err != nil {
+		return err
+	}
+	stored, ok := r.orders[order.ID]
+	if !ok {
+		return nil
+	}
+	// Like the conditional update of the postgres repository, an order is refunded once
+	if stored.Returned {
+		return util.ErrOrderReturned
+	}
+	stored.Returned = order.Returned
+	r.orders[order.ID] = stored
+	r.enqueue(order.ID, models.OrderReturned, order)
+
+	refund.ID = int64(len(r.refunds) + 1)
+	r.refunds = append(r.refunds, *refund)
+	return nil
+}
+
+func (r *Repository) IssueUpdate(ctx context.Context, orders []models.Order, payment *models.Payment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := make([]string, 0, len(orders))
+	for _, order := range orders {
+		ids = append(ids, order.ID)
+	}
+	// After the check a hold left on the orders is the issuer's own or an expired one
+	if err := r.checkHolds(ids, auth.Actor(ctx), payment.CreatedAt); err != nil {
+		return err
+	}
+	// Like the conditional update of the postgres repository, an order is issued once
+	for _, order := range orders {
+		if stored, ok := r.orders[order.ID]; ok && (stored.Issued || stored.Returned) {
+			return util.ErrOrderIssued
+		}
+	}
+	for _, order := range orders {
+		stored, ok := r.orders[order.ID]
+		if !ok {
+			continue
+		}
+		r.releaseCell(&stored)
+		delete(r.holds, order.ID)
+		stored.Issued = order.Issued
+		stored.IssuedAt = order.IssuedAt
+		r.orders[order.ID] = stored
+
+		order.CellID = ""
+		r.enqueue(order.ID, models.OrderIssued, order)
+	}
+
+	payment.ID = int64(len(r.payments) + 1)
+	r.payments = append(r.payments, *payment)
+	for _, order := range orders {
+		r.paymentOrders[order.ID] = payment.ID
+	}
+	return nil
+}
+
+func (r *Repository) Delete(ctx context.Context, id string, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.checkHolds([]string{id}, auth.Actor(ctx), now); err != nil {
+		return err
+	}
+	order, ok := r.orders[id]
+	if !ok {
+		return util.ErrOrderNotFound
+	}
+	r.releaseCell(&order)
+	delete(r.orders, id)
+	delete(r.holds, id)
+	r.enqueue(id, models.OrderReturnedToCourier, order)
+	return nil
+}
+
+func (r *Repository) Get(ctx context.Context, id string) (models.Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	order, ok := r.orders[id]
+	if !ok {
+		return models.Order{}, util.ErrOrderNotFound
+	}
+	return order, nil
+}
+
+func (r *Repository) GetReturns(ctx context.Context, offset, limit int) ([]models.Order, error) {
+	returns := r.filter(func(order models.Order) bool {
+		return order.Returned
+	})
+	sort.Slice(returns, func(i, k int) bool {
+		return returns[i].ID < returns[k].ID
+	})
+	return page(returns, offset, limit), nil
+}
+
+func (r *Repository) GetOrders(ctx context.Context, userId string, offset, limit int) ([]models.Order, error) {
+	userOrders := r.filter(func(order models.Order) bool {
+		return order.UserID == userId && !order.Issued
+	})
+	sort.Slice(userOrders, func(i, k int) bool {
+		return userOrders[i].StorageUntil.Before(userOrders[k].StorageUntil)
+	})
+	return page(userOrders, offset, limit), nil
+}
+
+func (r *Repository) GetExpiring(ctx context.Context, from, to time.Time) ([]models.Order, error) {
+	expiring := r.filter(func(order models.Order) bool {
+		return !order.Issued && !order.Returned && !order.StorageUntil.Before(from) && order.StorageUntil.Before(to)
+	})
+	sort.Slice(expiring, func(i, k int) bool {
+		return expiring[i].StorageUntil.Before(expiring[k].StorageUntil)
+	})
+	return expiring, nil
+}
+
+func (r *Repository) filter(match func(order models.Order) bool) []models.Order {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var orders []models.Order
+	for _, order := range r.orders {
+		if match(order) {
+			orders = append(orders, order)
+		}
+	}
+	return orders
+}
+
+func page[T any](items []T, offset, limit int) []T {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(items) {
+		return nil
+	}
+	items = items[offset:]
+	if limit >= 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
+
+func (r *Repository) GetCells(ctx context.Context) ([]models.Cell, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cells := make([]models.Cell, len(r.cells))
+	copy(cells, r.cells)
+	return cells, nil
+}
+
+func (r *Repository) InsertUser(ctx context.Context, user models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[user.ID]; ok {
+		return util.ErrUserExists
+	}
+	r.users[user.ID] = user
+	return nil
+}
+
+func (r *Repository) GetUser(ctx context.Context, id string) (models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return models.User{}, util.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (r *Repository) GetUserOrders(ctx context.Context, userID string) ([]models.Order, error) {
+	orders := r.filter(func(order models.Order) bool {
+		return order.UserID == userID
+	})
+	sort.Slice(orders, func(i, k int) bool {
+		if !orders[i].StorageUntil.Equal(orders[k].StorageUntil) {
+			return orders[i].StorageUntil.Before(orders[k].StorageUntil)
+		}
+		return orders[i].ID < orders[k].ID
+	})
+	return orders, nil
+}
+
+func (r *Repository) InsertCourier(ctx context.Context, courier models.Courier) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.couriers[courier.ID]; ok {
+		return util.ErrCourierExists
+	}
+	r.couriers[courier.ID] = courier
+	return nil
+}
+
+func (r *Repository) GetCourier(ctx context.Context, id string) (models.Courier, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	courier, ok := r.couriers[id]
+	if !ok {
+		return models.Courier{}, util.ErrCourierNotFound
+	}
+	return courier, nil
+}
+
+func (r *Repository) GetCourierReturnable(ctx context.Context, courierID string, now time.Time) ([]models.Order, error) {
+	orders := r.filter(func(order models.Order) bool {
+		return order.CourierID == courierID && order.ReturnableToCourier(now)
+	})
+	sort.Slice(orders, func(i, k int) bool {
+		return orders[i].ID < orders[k].ID
+	})
+	return orders, nil
+}
+
+// ReturnToCourier checks every order of the manifest before it deletes any, so a stale manifest changes nothing
+func (r *Repository) ReturnToCourier(ctx context.Context, manifest *models.Manifest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := make([]string, 0, len(manifest.Orders))
+	for _, order := range manifest.Orders {
+		ids = append(ids, order.ID)
+	}
+	if err := r.checkHolds(ids, auth.Actor(ctx), manifest.CreatedAt); err != nil {
+		return err
+	}
+	for _, order := range manifest.Orders {
+		stored, ok := r.orders[order.ID]
+		if !ok || stored.CourierID != manifest.CourierID || !stored.ReturnableToCourier(manifest.CreatedAt) {
+			return util.ErrManifestStale.Wrap(fmt.Errorf("order %s", order.ID))
+		}
+	}
+	for _, order := range manifest.Orders {
+		stored := r.orders[order.ID]
+		r.releaseCell(&stored)
+		delete(r.orders, order.ID)
+		delete(r.holds, order.ID)
+		r.enqueue(order.ID, models.OrderReturnedToCourier, stored)
+	}
+
+	manifest.ID = int64(len(r.manifests) + 1)
+	r.manifests = append(r.manifests, *manifest)
+	return nil
+}
+
+// checkHolds fails with ErrOrderHeld when another operator than actor holds one of the orders at now,
+// the caller holds the lock
+func (r *Repository) checkHolds(ids []string, actor string, now time.Time) error {
+	for _, id := range ids {
+		if held, ok := r.holds[id]; ok && held.Operator != actor && held.ExpiresAt.After(now) {
+			return util.ErrOrderHeld.Wrap(fmt.Errorf("order %s", id))
+		}
+	}
+	return nil
+}
+
+func (r *Repository) PlaceHolds(ctx context.Context, holds []models.Hold) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, hold := range holds {
+		if _, ok := r.orders[hold.OrderID]; !ok {
+			return util.ErrOrderNotFound.Wrap(fmt.Errorf("order %s", hold.OrderID))
+		}
+		held, ok := r.holds[hold.OrderID]
+		if ok && held.Operator != hold.Operator && held.ExpiresAt.After(hold.CreatedAt) {
+			return util.ErrOrderHeld.Wrap(fmt.Errorf("order %s", hold.OrderID))
+		}
+	}
+	for _, hold := range holds {
+		r.holds[hold.OrderID] = hold
+	}
+	return nil
+}
+
+func (r *Repository) ReleaseHolds(ctx context.Context, ids []string, operator string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range ids {
+		if hold, ok := r.holds[id]; ok && hold.Operator == operator {
+			delete(r.holds, id)
+		}
+	}
+	return nil
+}
+
+func (r *Repository) GetHolds(ctx context.Context, now time.Time) ([]models.Hold, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var holds []models.Hold
+	for _, hold := range r.holds {
+		if hold.ExpiresAt.After(now) {
+			holds = append(holds, hold)
+		}
+	}
+	sort.Slice(holds, func(i, k int) bool {
+		if !holds[i].ExpiresAt.Equal(holds[k].ExpiresAt) {
+			return holds[i].ExpiresAt.Before(holds[k].ExpiresAt)
+		}
+		return holds[i].OrderID < holds[k].OrderID
+	})
+	return holds, nil
+}
+
+func (r *Repository) GetOrderHolds(ctx context.Context, ids []string, now time.Time) ([]models.Hold, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var holds []models.Hold
+	for _, id := range ids {
+		if hold, ok := r.holds[id]; ok && hold.ExpiresAt.After(now) {
+			holds = append(holds, hold)
+		}
+	}
+	sort.Slice(holds, func(i, k int) bool {
+		return holds[i].OrderID < holds[k].OrderID
+	})
+	return holds, nil
+}
+
+func (r *Repository) InsertOperator(ctx context.Context, operator models.Operator) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.operators[operator.Login]; ok {
+		return util.ErrOperatorExists
+	}
+	r.operators[operator.Login] = operator
+	return nil
+}
+
+func (r *Repository) GetOperator(ctx context.Context, login string) (models.Operator, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	operator, ok := r.operators[login]
+	if !ok {
+		return models.Operator{}, util.ErrOperatorNotFound
+	}
+	return operator, nil
+}
+
+func (r *Repository) GetOperatorByKey(ctx context.Context, apiKeyHash string) (models.Operator, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, operator := range r.operators {
+		if operator.APIKeyHash == apiKeyHash {
+			return operator, nil
+		}
+	}
+	return models.Operator{}, util.ErrOperatorNotFound
+}
@@ -0,0 +1,32 @@
+package memory
+
+import (
+	"context"
+	"homework/internal/models"
+	"homework/internal/util"
+)
+
+func (r *Repository) GetOrderPayment(ctx context.Context, orderID string) (models.Payment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	id, ok := r.paymentOrders[orderID]
+	if !ok {
+		return models.Payment{}, util.ErrPaymentNotFound
+	}
+	return r.payments[id-1], nil
+}
+
+// GetRefunds lists the latest refunds first, like the postgres repository
+func (r *Repository) GetRefunds(ctx context.Context, userID string, offset, limit int) ([]models.Refund, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var refunds []models.Refund
+	for i := len(r.refunds) - 1; i >= 0; i-- {
+		if len(userID) == 0 || r.refunds[i].UserID == userID {
+			refunds = append(refunds, r.refunds[i])
+		}
+	}
+	return page(refunds, offset, limit), nil
+}
@@ -0,0 +1,213 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"homework/internal/models"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// ClaimNotification follows the postgres repository: a new, failed or abandoned notification is claimed,
+// a pending or sent one is a duplicate
+func (r *Repository) ClaimNotification(ctx context.Context, notification models.Notification, lease time.Duration) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := notificationKey{notification.OrderID, notification.Event, notification.Channel}
+	stored, ok := r.notifications[key]
+	now := time.Now()
+	switch {
+	case !ok:
+		notification.Attempts = 1
+		notification.CreatedAt = now
+	case stored.Status == models.NotificationFailed || abandoned(stored, lease, now):
+		notification.Attempts = stored.Attempts + 1
+		notification.CreatedAt = stored.CreatedAt
+	default:
+		return false, nil
+	}
+	notification.Status = models.NotificationPending
+	notification.Error = ""
+	notification.ClaimedAt = now
+	r.notifications[key] = notification
+	return true, nil
+}
+
+func (r *Repository) UpdateNotification(ctx context.Context, notification models.Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := notificationKey{notification.OrderID, notification.Event, notification.Channel}
+	stored, ok := r.notifications[key]
+	if !ok {
+		return nil
+	}
+	stored.Status = notification.Status
+	stored.Error = notification.Error
+	stored.SentAt = notification.SentAt
+	r.notifications[key] = stored
+	return nil
+}
+
+func (r *Repository) GetNotifications(ctx context.Context, orderID string) ([]models.Notification, error) {
+	notifications := r.filterNotifications(func(n models.Notification) bool {
+		return n.OrderID == orderID
+	})
+	sort.Slice(notifications, func(i, k int) bool {
+		if !notifications[i].CreatedAt.Equal(notifications[k].CreatedAt) {
+			return notifications[i].CreatedAt.Before(notifications[k].CreatedAt)
+		}
+		return notifications[i].Channel < notifications[k].Channel
+	})
+	return notifications, nil
+}
+
+// abandoned tells if the notification was left pending for longer than lease
+func abandoned(n models.Notification, lease time.Duration, now time.Time) bool {
+	return n.Status == models.NotificationPending && !n.ClaimedAt.Add(lease).After(now)
+}
+
+func (r *Repository) GetFailedNotifications(ctx context.Context, maxAttempts int, lease time.Duration, limit int) ([]models.Notification, error) {
+	now := time.Now()
+	failed := r.filterNotifications(func(n models.Notification) bool {
+		return (n.Status == models.NotificationFailed || abandoned(n, lease, now)) && n.Attempts < maxAttempts
+	})
+	sort.Slice(failed, func(i, k int) bool {
+		return failed[i].CreatedAt.Before(failed[k].CreatedAt)
+	})
+	if limit < len(failed) {
+		failed = failed[:limit]
+	}
+	return failed, nil
+}
+
+func (r *Repository) filterNotifications(match func(n models.Notification) bool) []models.Notification {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var notifications []models.Notification
+	for _, n := range r.notifications {
+		if match(n) {
+			notifications = append(notifications, n)
+		}
+	}
+	return notifications
+}
+
+func (r *Repository) ClaimIdempotencyKey(ctx context.Context, record models.IdempotencyRecord, lease time.Duration) (models.IdempotencyRecord, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := idempotencyKey{record.Operator, record.Key}
+	now := time.Now()
+	if stored, ok := r.idempotency[key]; ok && stored.ExpiresAt.After(now) {
+		abandoned := stored.Status == models.IdempotencyPending && !stored.CreatedAt.Add(lease).After(now)
+		if !abandoned {
+			return stored, false, nil
+		}
+	}
+	record.Status = models.IdempotencyPending
+	record.Response = nil
+	record.ErrorCode, record.ErrorField, record.ErrorMessage = "", "", ""
+	record.CreatedAt = now
+	r.idempotency[key] = record
+	return record, true, nil
+}
+
+func (r *Repository) CompleteIdempotencyKey(ctx context.Context, record models.IdempotencyRecord) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := idempotencyKey{record.Operator, record.Key}
+	stored, ok := r.idempotency[key]
+	if !ok || !holdsClaim(stored, record) {
+		return false, nil
+	}
+	stored.Status = models.IdempotencyDone
+	stored.Response = record.Response
+	stored.ErrorCode, stored.ErrorField, stored.ErrorMessage = record.ErrorCode, record.ErrorField, record.ErrorMessage
+	r.idempotency[key] = stored
+	return true, nil
+}
+
+func (r *Repository) ReleaseIdempotencyKey(ctx context.Context, record models.IdempotencyRecord) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := idempotencyKey{record.Operator, record.Key}
+	stored, ok := r.idempotency[key]
+	if !ok || !holdsClaim(stored, record) {
+		return false, nil
+	}
+	delete(r.idempotency, key)
+	return true, nil
+}
+
+// holdsClaim tells if the stored record is still the pending claim of record, like the created_at fence of postgres
+func holdsClaim(stored, record models.IdempotencyRecord) bool {
+	return stored.Status == models.IdempotencyPending && stored.CreatedAt.Equal(record.CreatedAt)
+}
+
+func (r *Repository) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	now := time.Now()
+	for key, record := range r.idempotency {
+		if !record.ExpiresAt.After(now) {
+			delete(r.idempotency, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// enqueue keeps an order event for ProcessOutbox, r.mu must be held
+func (r *Repository) enqueue(orderID string, eventType models.OutboxEventType, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("outbox event not encoded", "order_id", orderID, "event_type", eventType, "err", err)
+		return
+	}
+	r.outbox = append(r.outbox, models.OutboxEvent{
+		ID:        int64(len(r.outbox) + 1),
+		OrderID:   orderID,
+		Type:      eventType,
+		Payload:   data,
+		CreatedAt: time.Now(),
+	})
+}
+
+// ProcessOutbox hands the unpublished events to publish in id order, like the postgres repository does
+func (r *Repository) ProcessOutbox(ctx context.Context, limit int, publish func(ctx context.Context, events []models.OutboxEvent) map[int64]error) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var events []models.OutboxEvent
+	for _, event := range r.outbox {
+		if event.PublishedAt == nil && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	published := 0
+	for id, publishErr := range publish(ctx, events) {
+		event := &r.outbox[id-1]
+		event.Attempts++
+		if publishErr != nil {
+			event.LastError = publishErr.Error()
+			continue
+		}
+		publishedAt := time.Now()
+		event.PublishedAt = &publishedAt
+		event.LastError = ""
+		published++
+	}
+	return published, nil
+}
@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"homework/internal/models"
+	"homework/internal/util"
+	"slices"
+	"strings"
+)
+
+// columns compares orders by every column the postgres repository can sort by
+var columns = map[string]func(a, b models.Order) int{
+	"id":            func(a, b models.Order) int { return strings.Compare(a.ID, b.ID) },
+	"user_id":       func(a, b models.Order) int { return strings.Compare(a.UserID, b.UserID) },
+	"storage_until": func(a, b models.Order) int { return a.StorageUntil.Compare(b.StorageUntil) },
+	"issued":        func(a, b models.Order) int { return compareBool(a.Issued, b.Issued) },
+	"issued_at":     func(a, b models.Order) int { return a.IssuedAt.Compare(b.IssuedAt) },
+	"returned":      func(a, b models.Order) int { return compareBool(a.Returned, b.Returned) },
+	"order_price":   func(a, b models.Order) int { return cmp.Compare(a.OrderPrice, b.OrderPrice) },
+	"weight":        func(a, b models.Order) int { return cmp.Compare(a.Weight, b.Weight) },
+	"package_type":  func(a, b models.Order) int { return strings.Compare(string(a.PackageType), string(b.PackageType)) },
+	"package_price": func(a, b models.Order) int { return cmp.Compare(a.PackagePrice, b.PackagePrice) },
+	"cell_id":       func(a, b models.Order) int { return strings.Compare(a.CellID, b.CellID) },
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	}
+	return -1
+}
+
+// Find follows the postgres repository: the same conditions, the sort column then the id, and a page of the result
+func (r *Repository) Find(ctx context.Context, filter models.OrderFilter) ([]models.Order, error) {
+	sortBy := filter.SortBy
+	if len(sortBy) == 0 {
+		sortBy = "id"
+	}
+	compare, ok := columns[sortBy]
+	if !ok {
+		return nil, util.ErrSortColumnInvalid
+	}
+
+	orders := r.filter(func(order models.Order) bool {
+		return matches(filter, order)
+	})
+	slices.SortFunc(orders, func(a, b models.Order) int {
+		c := compare(a, b)
+		if filter.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return page(orders, filter.Offset, filter.Limit), nil
+}
+
+func matches(f models.OrderFilter, order models.Order) bool {
+	switch {
+	case len(f.Status) > 0 && order.Status() != f.Status:
+		return false
+	case !strings.HasPrefix(order.ID, f.IDPrefix):
+		return false
+	case len(f.UserID) > 0 && order.UserID != f.UserID:
+		return false
+	case len(f.PackageType) > 0 && order.PackageType != f.PackageType:
+		return false
+	case !f.StorageFrom.IsZero() && order.StorageUntil.Before(f.StorageFrom):
+		return false
+	case !f.StorageTo.IsZero() && !order.StorageUntil.Before(f.StorageTo):
+		return false
+	case (!f.IssuedFrom.IsZero() || !f.IssuedTo.IsZero()) && !order.Issued:
+		return false
+	case !f.IssuedFrom.IsZero() && order.IssuedAt.Before(f.IssuedFrom):
+		return false
+	case !f.IssuedTo.IsZero() && !order.IssuedAt.Before(f.IssuedTo):
+		return false
+	case f.PriceMin > 0 && order.OrderPrice < f.PriceMin:
+		return false
+	case f.PriceMax > 0 && order.OrderPrice > f.PriceMax:
+		return false
+	case f.WeightMin > 0 && order.Weight < f.WeightMin:
+		return false
+	case f.WeightMax > 0 && order.Weight > f.WeightMax:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,245 @@
+// Package mocks has hand-written doubles of the storage interfaces for unit tests
+package mocks
+
+import (
+	"context"
+	"fmt"
+	"homework/internal/models"
+	"homework/internal/storage"
+	"sync"
+	"time"
+)
+
+var _ storage.Storage = (*Storage)(nil)
+
+// Storage answers every method with its Func field, a test sets the ones the code under test should call.
+// A call to a method without a Func panics, so an unexpected query fails the test instead of passing silently
+type Storage struct {
+	InsertFunc                       func(ctx context.Context, order *models.Order) error
+	UpdateFunc                       func(ctx context.Context, order models.Order, refund *models.Refund) error
+	IssueUpdateFunc                  func(ctx context.Context, orders []models.Order, payment *models.Payment) error
+	DeleteFunc                       func(ctx context.Context, id string, now time.Time) error
+	GetFunc                          func(ctx context.Context, id string) (models.Order, error)
+	GetReturnsFunc                   func(ctx context.Context, offset, limit int) ([]models.Order, error)
+	GetOrdersFunc                    func(ctx context.Context, userId string, offset, limit int) ([]models.Order, error)
+	FindFunc                         func(ctx context.Context, filter models.OrderFilter) ([]models.Order, error)
+	GetCellsFunc                     func(ctx context.Context) ([]models.Cell, error)
+	InsertUserFunc                   func(ctx context.Context, user models.User) error
+	GetUserFunc                      func(ctx context.Context, id string) (models.User, error)
+	GetUserOrdersFunc                func(ctx context.Context, userID string) ([]models.Order, error)
+	InsertCourierFunc                func(ctx context.Context, courier models.Courier) error
+	GetCourierFunc                   func(ctx context.Context, id string) (models.Courier, error)
+	GetCourierReturnableFunc         func(ctx context.Context, courierID string, now time.Time) ([]models.Order, error)
+	ReturnToCourierFunc              func(ctx context.Context, manifest *models.Manifest) error
+	PlaceHoldsFunc                   func(ctx context.Context, holds []models.Hold) error
+	ReleaseHoldsFunc                 func(ctx context.Context, ids []string, operator string) error
+	GetHoldsFunc                     func(ctx context.Context, now time.Time) ([]models.Hold, error)
+	GetOrderHoldsFunc                func(ctx context.Context, ids []string, now time.Time) ([]models.Hold, error)
+	GetOrderPaymentFunc              func(ctx context.Context, orderID string) (models.Payment, error)
+	GetRefundsFunc                   func(ctx context.Context, userID string, offset, limit int) ([]models.Refund, error)
+	InsertOperatorFunc               func(ctx context.Context, operator models.Operator) error
+	GetOperatorFunc                  func(ctx context.Context, login string) (models.Operator, error)
+	GetOperatorByKeyFunc             func(ctx context.Context, apiKeyHash string) (models.Operator, error)
+	GetExpiringFunc                  func(ctx context.Context, from, to time.Time) ([]models.Order, error)
+	ClaimNotificationFunc            func(ctx context.Context, notification models.Notification, lease time.Duration) (bool, error)
+	UpdateNotificationFunc           func(ctx context.Context, notification models.Notification) error
+	GetNotificationsFunc             func(ctx context.Context, orderID string) ([]models.Notification, error)
+	GetFailedNotificationsFunc       func(ctx context.Context, maxAttempts int, lease time.Duration, limit int) ([]models.Notification, error)
+	ClaimIdempotencyKeyFunc          func(ctx context.Context, record models.IdempotencyRecord, lease time.Duration) (models.IdempotencyRecord, bool, error)
+	CompleteIdempotencyKeyFunc       func(ctx context.Context, record models.IdempotencyRecord) (bool, error)
+	ReleaseIdempotencyKeyFunc        func(ctx context.Context, record models.IdempotencyRecord) (bool, error)
+	DeleteExpiredIdempotencyKeysFunc func(ctx context.Context) (int64, error)
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+// Calls returns how many times the method was called
+func (s *Storage) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+func (s *Storage) called(method string, set bool) {
+	s.mu.Lock()
+	if s.calls == nil {
+		s.calls = make(map[string]int)
+	}
+	s.calls[method]++
+	s.mu.Unlock()
+
+	if !set {
+		panic(fmt.Sprintf("mocks.Storage: unexpected call to %s", method))
+	}
+}
+
+func (s *Storage) Insert(ctx context.Context, order *models.Order) error {
+	s.called("Insert", s.InsertFunc != nil)
+	return s.InsertFunc(ctx, order)
+}
+
+func (s *Storage) Update(ctx context.Context, order models.Order, refund *models.Refund) error {
+	s.called("Update", s.UpdateFunc != nil)
+	return s.UpdateFunc(ctx, order, refund)
+}
+
+func (s *Storage) IssueUpdate(ctx context.Context, orders []models.Order, payment *models.Payment) error {
+	s.called("IssueUpdate", s.IssueUpdateFunc != nil)
+	return s.IssueUpdateFunc(ctx, orders, payment)
+}
+
+func (s *Storage) Delete(ctx context.Context, id string, now time.Time) error {
+	s.called("Delete", s.DeleteFunc != nil)
+	return s.DeleteFunc(ctx, id, now)
+}
+
+func (s *Storage) Get(ctx context.Context, id string) (models.Order, error) {
+	s.called("Get", s.GetFunc != nil)
+	return s.GetFunc(ctx, id)
+}
+
+func (s *Storage) GetReturns(ctx context.Context, offset, limit int) ([]models.Order, error) {
+	s.called("GetReturns", s.GetReturnsFunc != nil)
+	return s.GetReturnsFunc(ctx, offset, limit)
+}
+
+func (s *Storage) GetOrders(ctx context.Context, userId string, offset, limit int) ([]models.Order, error) {
+	s.called("GetOrders", s.GetOrdersFunc != nil)
+	return s.GetOrdersFunc(ctx, userId, offset, limit)
+}
+
+func (s *Storage) Find(ctx context.Context, filter models.OrderFilter) ([]models.Order, error) {
+	s.called("Find", s.FindFunc != nil)
+	return s.FindFunc(ctx, filter)
+}
+
+func (s *Storage) GetCells(ctx context.Context) ([]models.Cell, error) {
+	s.called("GetCells", s.GetCellsFunc != nil)
+	return s.GetCellsFunc(ctx)
+}
+
+func (s *Storage) InsertUser(ctx context.Context, user models.User) error {
+	s.called("InsertUser", s.InsertUserFunc != nil)
+	return s.InsertUserFunc(ctx, user)
+}
+
+func (s *Storage) GetUser(ctx context.Context, id string) (models.User, error) {
+	s.called("GetUser", s.GetUserFunc != nil)
+	return s.GetUserFunc(ctx, id)
+}
+
+func (s *Storage) GetUserOrders(ctx context.Context, userID string) ([]models.Order, error) {
+	s.called("GetUserOrders", s.GetUserOrdersFunc != nil)
+	return s.GetUserOrdersFunc(ctx, userID)
+}
+
+func (s *Storage) InsertCourier(ctx context.Context, courier models.Courier) error {
+	s.called("InsertCourier", s.InsertCourierFunc != nil)
+	return s.InsertCourierFunc(ctx, courier)
+}
+
+func (s *Storage) GetCourier(ctx context.Context, id string) (models.Courier, error) {
+	s.called("GetCourier", s.GetCourierFunc != nil)
+	return s.GetCourierFunc(ctx, id)
+}
+
+func (s *Storage) GetCourierReturnable(ctx context.Context, courierID string, now time.Time) ([]models.Order, error) {
+	s.called("GetCourierReturnable", s.GetCourierReturnableFunc != nil)
+	return s.GetCourierReturnableFunc(ctx, courierID, now)
+}
+
+func (s *Storage) ReturnToCourier(ctx context.Context, manifest *models.Manifest) error {
+	s.called("ReturnToCourier", s.ReturnToCourierFunc != nil)
+	return s.ReturnToCourierFunc(ctx, manifest)
+}
+
+func (s *Storage) PlaceHolds(ctx context.Context, holds []models.Hold) error {
+	s.called("PlaceHolds", s.PlaceHoldsFunc != nil)
+	return s.PlaceHoldsFunc(ctx, holds)
+}
+
+func (s *Storage) ReleaseHolds(ctx context.Context, ids []string, operator string) error {
+	s.called("ReleaseHolds", s.ReleaseHoldsFunc != nil)
+	return s.ReleaseHoldsFunc(ctx, ids, operator)
+}
+
+func (s *Storage) GetHolds(ctx context.Context, now time.Time) ([]models.Hold, error) {
+	s.called("GetHolds", s.GetHoldsFunc != nil)
+	return s.GetHoldsFunc(ctx, now)
+}
+
+func (s *Storage) GetOrderHolds(ctx context.Context, ids []string, now time.Time) ([]models.Hold, error) {
+	s.called("GetOrderHolds", s.GetOrderHoldsFunc != nil)
+	return s.GetOrderHoldsFunc(ctx, ids, now)
+}
+
+func (s *Storage) GetOrderPayment(ctx context.Context, orderID string) (models.Payment, error) {
+	s.called("GetOrderPayment", s.GetOrderPaymentFunc != nil)
+	return s.GetOrderPaymentFunc(ctx, orderID)
+}
+
+func (s *Storage) GetRefunds(ctx context.Context, userID string, offset, limit int) ([]models.Refund, error) {
+	s.called("GetRefunds", s.GetRefundsFunc != nil)
+	return s.GetRefundsFunc(ctx, userID, offset, limit)
+}
+
+func (s *Storage) InsertOperator(ctx context.Context, operator models.Operator) error {
+	s.called("InsertOperator", s.InsertOperatorFunc != nil)
+	return s.InsertOperatorFunc(ctx, operator)
+}
+
+func (s *Storage) GetOperator(ctx context.Context, login string) (models.Operator, error) {
+	s.called("GetOperator", s.GetOperatorFunc != nil)
+	return s.GetOperatorFunc(ctx, login)
+}
+
+func (s *Storage) GetOperatorByKey(ctx context.Context, apiKeyHash string) (models.Operator, error) {
+	s.called("GetOperatorByKey", s.GetOperatorByKeyFunc != nil)
+	return s.GetOperatorByKeyFunc(ctx, apiKeyHash)
+}
+
+func (s *Storage) GetExpiring(ctx context.Context, from, to time.Time) ([]models.Order, error) {
+	s.called("GetExpiring", s.GetExpiringFunc != nil)
+	return s.GetExpiringFunc(ctx, from, to)
+}
+
+func (s *Storage) ClaimNotification(ctx context.Context, notification models.Notification, lease time.Duration) (bool, error) {
+	s.called("ClaimNotification", s.ClaimNotificationFunc != nil)
+	return s.ClaimNotificationFunc(ctx, notification, lease)
+}
+
+func (s *Storage) UpdateNotification(ctx context.Context, notification models.Notification) error {
+	s.called("UpdateNotification", s.UpdateNotificationFunc != nil)
+	return s.UpdateNotificationFunc(ctx, notification)
+}
+
+func (s *Storage) GetNotifications(ctx context.Context, orderID string) ([]models.Notification, error) {
+	s.called("GetNotifications", s.GetNotificationsFunc != nil)
+	return s.GetNotificationsFunc(ctx, orderID)
+}
+
+func (s *Storage) GetFailedNotifications(ctx context.Context, maxAttempts int, lease time.Duration, limit int) ([]models.Notification, error) {
+	s.called("GetFailedNotifications", s.GetFailedNotificationsFunc != nil)
+	return s.GetFailedNotificationsFunc(ctx, maxAttempts, lease, limit)
+}
+
+func (s *Storage) ClaimIdempotencyKey(ctx context.Context, record models.IdempotencyRecord, lease time.Duration) (models.IdempotencyRecord, bool, error) {
+	s.called("ClaimIdempotencyKey", s.ClaimIdempotencyKeyFunc != nil)
+	return s.ClaimIdempotencyKeyFunc(ctx, record, lease)
+}
+
+func (s *Storage) CompleteIdempotencyKey(ctx context.Context, record models.IdempotencyRecord) (bool, error) {
+	s.called("CompleteIdempotencyKey", s.CompleteIdempotencyKeyFunc != nil)
+	return s.CompleteIdempotencyKeyFunc(ctx, record)
+}
+
+func (s *Storage) ReleaseIdempotencyKey(ctx context.Context, record models.IdempotencyRecord) (bool, error) {
+	s.called("ReleaseIdempotencyKey", s.ReleaseIdempotencyKeyFunc != nil)
+	return s.ReleaseIdempotencyKeyFunc(ctx, record)
+}
+
+func (s *Storage) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	s.called("DeleteExpiredIdempotencyKeys", s.DeleteExpiredIdempotencyKeysFunc != nil)
+	return s.DeleteExpiredIdempotencyKeysFunc(ctx)
+}
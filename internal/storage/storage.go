@@ -1,14 +1,55 @@
 package storage
 
-import "homework/internal/models"
+import (
+	"context"
+	"homework/internal/models"
+	"time"
+)
 
-//go:generate mockery --name Storage
+// Storage is implemented by db.Repository and memory.Repository, mocks.Storage is its double for unit tests
 type Storage interface {
-	Insert(order models.Order) error
-	Update(order models.Order) error
-	IssueUpdate(orders []models.Order) error
-	Delete(id string) error
-	Get(id string) (models.Order, error)
-	GetReturns(offset, limit int) ([]models.Order, error)
-	GetOrders(userId string, offset, limit int) ([]models.Order, error)
+	Insert(ctx context.Context, order *models.Order) error
+	// Update marks the order returned and records its refund in one transaction, it sets the refund id
+	Update(ctx context.Context, order models.Order, refund *models.Refund) error
+	// IssueUpdate issues the orders and records their payment in one transaction, it sets the payment id
+	IssueUpdate(ctx context.Context, orders []models.Order, payment *models.Payment) error
+	// Delete hands the order back to the courier, ErrOrderHeld when another operator holds it at now
+	Delete(ctx context.Context, id string, now time.Time) error
+	Get(ctx context.Context, id string) (models.Order, error)
+	GetReturns(ctx context.Context, offset, limit int) ([]models.Order, error)
+	GetOrders(ctx context.Context, userId string, offset, limit int) ([]models.Order, error)
+	Find(ctx context.Context, filter models.OrderFilter) ([]models.Order, error)
+	GetCells(ctx context.Context) ([]models.Cell, error)
+	InsertUser(ctx context.Context, user models.User) error
+	GetUser(ctx context.Context, id string) (models.User, error)
+	GetUserOrders(ctx context.Context, userID string) ([]models.Order, error)
+	InsertCourier(ctx context.Context, courier models.Courier) error
+	GetCourier(ctx context.Context, id string) (models.Courier, error)
+	GetCourierReturnable(ctx context.Context, courierID string, now time.Time) ([]models.Order, error)
+	// ReturnToCourier deletes the orders of the manifest and records it in one transaction, it sets the manifest id
+	ReturnToCourier(ctx context.Context, manifest *models.Manifest) error
+	// PlaceHolds holds all the orders or none, ErrOrderHeld when another operator has an active hold on one
+	PlaceHolds(ctx context.Context, holds []models.Hold) error
+	ReleaseHolds(ctx context.Context, ids []string, operator string) error
+	GetHolds(ctx context.Context, now time.Time) ([]models.Hold, error)
+	GetOrderHolds(ctx context.Context, ids []string, now time.Time) ([]models.Hold, error)
+	// GetOrderPayment is the payment the order was issued with, ErrPaymentNotFound if it was issued without one
+	GetOrderPayment(ctx context.Context, orderID string) (models.Payment, error)
+	GetRefunds(ctx context.Context, userID string, offset, limit int) ([]models.Refund, error)
+	InsertOperator(ctx context.Context, operator models.Operator) error
+	GetOperator(ctx context.Context, login string) (models.Operator, error)
+	GetOperatorByKey(ctx context.Context, apiKeyHash string) (models.Operator, error)
+	GetExpiring(ctx context.Context, from, to time.Time) ([]models.Order, error)
+	// ClaimNotification takes over a notification left pending for longer than lease
+	ClaimNotification(ctx context.Context, notification models.Notification, lease time.Duration) (bool, error)
+	UpdateNotification(ctx context.Context, notification models.Notification) error
+	GetNotifications(ctx context.Context, orderID string) ([]models.Notification, error)
+	// GetFailedNotifications counts a notification left pending for longer than lease as failed
+	GetFailedNotifications(ctx context.Context, maxAttempts int, lease time.Duration, limit int) ([]models.Notification, error)
+	// ClaimIdempotencyKey takes over a record left pending for longer than lease
+	ClaimIdempotencyKey(ctx context.Context, record models.IdempotencyRecord, lease time.Duration) (models.IdempotencyRecord, bool, error)
+	// CompleteIdempotencyKey and ReleaseIdempotencyKey only touch the claim of record, false means it was taken over
+	CompleteIdempotencyKey(ctx context.Context, record models.IdempotencyRecord) (bool, error)
+	ReleaseIdempotencyKey(ctx context.Context, record models.IdempotencyRecord) (bool, error)
+	DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error)
 }
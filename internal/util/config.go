@@ -0,0 +1,322 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+	"homework/internal/models"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// configFileEnv points to an optional YAML or JSON file, the -config flag takes precedence
+	configFileEnv = "CONFIG_FILE"
+	// envFileEnv points to a dotenv file, by default .env next to the binary's working dir
+	// and the one kept with the sources are tried
+	envFileEnv = "ENV_FILE"
+	masked     = "***"
+)
+
+var defaultEnvFiles = []string{".env", "internal/util/.env"}
+
+// LoadConfig builds the config from defaults, the dotenv file, the config file, environment variables and flags,
+// in increasing priority, arguments left after the flags are returned for subcommands
+func LoadConfig(args []string) (*models.Config, []string, error) {
+	cfg := &models.Config{}
+	fields := configFields(cfg)
+
+	fs := flag.NewFlagSet("cli", flag.ContinueOnError)
+	configFile := fs.String("config", "", "path to a YAML or JSON config file")
+	envFile := fs.String("env_file", "", "path to a dotenv file")
+	flagValues := make(map[string]*string)
+	for _, field := range fields {
+		flagValues[field.key] = fs.String(field.key, "", fmt.Sprintf("overrides %s", field.env))
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	dotenv, dotenvPath, err := readEnvFile(*envFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var errs []error
+	for _, field := range fields {
+		if value, ok := field.tag.Lookup("default"); ok {
+			errs = append(errs, field.set(value, "default"))
+		}
+	}
+
+	for _, field := range fields {
+		if value, ok := dotenv[field.env]; ok {
+			errs = append(errs, field.set(value, dotenvPath))
+		}
+	}
+
+	if len(*configFile) == 0 {
+		*configFile = os.Getenv(configFileEnv)
+	}
+	if len(*configFile) == 0 {
+		*configFile = dotenv[configFileEnv]
+	}
+	if len(*configFile) > 0 {
+		values, err := readConfigFile(*configFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, field := range fields {
+			if value, ok := values[field.key]; ok {
+				errs = append(errs, field.set(value, *configFile))
+			}
+		}
+	}
+
+	for _, field := range fields {
+		if value, ok := os.LookupEnv(field.env); ok {
+			errs = append(errs, field.set(value, "env "+field.env))
+		}
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		for _, field := range fields {
+			if field.key == f.Name {
+				errs = append(errs, field.set(*flagValues[field.key], "flag -"+field.key))
+			}
+		}
+	})
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, nil, err
+	}
+	if err := validateConfig(cfg, fields); err != nil {
+		return nil, nil, err
+	}
+
+	return cfg, fs.Args(), nil
+}
+
+// PrintConfig writes the effective config sorted by variable name, secrets are masked
+func PrintConfig(w io.Writer, cfg *models.Config) {
+	fields := configFields(cfg)
+	sort.Slice(fields, func(i, k int) bool {
+		return fields[i].env < fields[k].env
+	})
+	for _, field := range fields {
+		value := fmt.Sprint(field.value.Interface())
+		if t, ok := field.value.Interface().(time.Time); ok {
+			value = formatTime(t)
+		}
+		if field.tag.Get("secret") == "true" && len(value) > 0 {
+			value = masked
+		}
+		fmt.Fprintf(w, "%s=%s\n", field.env, value)
+	}
+}
+
+// readEnvFile reads the dotenv file into a layer of its own, it stays out of the process environment
+// so the config file still overrides it. It returns the path it read, empty when there is no file
+func readEnvFile(path string) (map[string]string, string, error) {
+	if len(path) == 0 {
+		path = os.Getenv(envFileEnv)
+	}
+	if len(path) == 0 {
+		for _, candidate := range defaultEnvFiles {
+			if _, err := os.Stat(candidate); err == nil {
+				path = candidate
+				break
+			}
+		}
+	}
+	if len(path) == 0 {
+		return nil, "", nil
+	}
+
+	values, err := godotenv.Read(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("config: reading env file %s: %w", path, err)
+	}
+	return values, path, nil
+}
+
+func readConfigFile(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: reading %s: %w", path, err)
+	}
+
+	raw := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &raw)
+	case ".json":
+		err = json.Unmarshal(content, &raw)
+	default:
+		return nil, fmt.Errorf("config: %s: unsupported format, use .yaml, .yml or .json", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config: parsing %s: %w", path, err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		values[strings.ToLower(key)] = fmt.Sprint(value)
+	}
+	return values, nil
+}
+
+func validateConfig(cfg *models.Config, fields []configField) error {
+	var errs []error
+	for _, field := range fields {
+		if field.tag.Get("required") == "true" && field.value.IsZero() {
+			errs = append(errs, fmt.Errorf("config: %s is required", field.env))
+		}
+	}
+	if cfg.Attempts < 1 {
+		errs = append(errs, fmt.Errorf("config: ATTEMPTS must be > 0, got %d", cfg.Attempts))
+	}
+	if cfg.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("config: TIMEOUT must be positive, got %s", cfg.Timeout))
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("config: SHUTDOWN_TIMEOUT must be positive, got %s", cfg.ShutdownTimeout))
+	}
+	if cfg.HealthMaxBacklog < 1 {
+		errs = append(errs, fmt.Errorf("config: HEALTH_MAX_BACKLOG must be > 0, got %d", cfg.HealthMaxBacklog))
+	}
+	if cfg.NotifyExpiryWindow <= 0 {
+		errs = append(errs, fmt.Errorf("config: NOTIFY_EXPIRY_WINDOW must be positive, got %s", cfg.NotifyExpiryWindow))
+	}
+	if cfg.NotifySweepInterval <= 0 {
+		errs = append(errs, fmt.Errorf("config: NOTIFY_SWEEP_INTERVAL must be positive, got %s", cfg.NotifySweepInterval))
+	}
+	if cfg.OutboxInterval <= 0 {
+		errs = append(errs, fmt.Errorf("config: OUTBOX_INTERVAL must be positive, got %s", cfg.OutboxInterval))
+	}
+	if cfg.HoldTTL <= 0 {
+		errs = append(errs, fmt.Errorf("config: HOLD_TTL must be positive, got %s", cfg.HoldTTL))
+	}
+	if cfg.StorageFeePerDay < 0 {
+		errs = append(errs, fmt.Errorf("config: STORAGE_FEE_PER_DAY must be >= 0, got %v", cfg.StorageFeePerDay))
+	}
+	if cfg.OutboxBatchSize < 1 {
+		errs = append(errs, fmt.Errorf("config: OUTBOX_BATCH_SIZE must be > 0, got %d", cfg.OutboxBatchSize))
+	}
+	if cfg.IdempotencyTTL <= 0 {
+		errs = append(errs, fmt.Errorf("config: IDEMPOTENCY_TTL must be positive, got %s", cfg.IdempotencyTTL))
+	}
+	if cfg.IdempotencyLease <= 0 || cfg.IdempotencyLease > cfg.IdempotencyTTL {
+		errs = append(errs, fmt.Errorf("config: IDEMPOTENCY_LEASE must be positive and at most IDEMPOTENCY_TTL, got %s", cfg.IdempotencyLease))
+	}
+	if budget := time.Duration(cfg.Attempts) * cfg.RetryMaxDelay; cfg.IdempotencyLease < budget {
+		errs = append(errs, fmt.Errorf("config: IDEMPOTENCY_LEASE must cover the retries, ATTEMPTS * RETRY_MAX_DELAY = %s, got %s", budget, cfg.IdempotencyLease))
+	}
+	if cfg.LogFormat != "text" && cfg.LogFormat != "json" {
+		errs = append(errs, fmt.Errorf("config: LOG_FORMAT must be text or json, got %q", cfg.LogFormat))
+	}
+	return errors.Join(errs...)
+}
+
+type configField struct {
+	env   string
+	key   string
+	tag   reflect.StructTag
+	value reflect.Value
+}
+
+func configFields(cfg *models.Config) []configField {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+
+	fields := make([]configField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		env, ok := t.Field(i).Tag.Lookup("env")
+		if !ok {
+			continue
+		}
+		fields = append(fields, configField{
+			env:   env,
+			key:   strings.ToLower(env),
+			tag:   t.Field(i).Tag,
+			value: v.Field(i),
+		})
+	}
+	return fields
+}
+
+// timeLayouts are accepted for time fields, a value without a zone is local time
+var timeLayouts = []string{time.RFC3339, time.DateTime, time.DateOnly}
+
+func parseTime(raw string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, raw, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("use one of %s", strings.Join(timeLayouts, ", "))
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// set parses raw into the field, source tells the user where a bad value came from
+func (f configField) set(raw, source string) error {
+	durationType := reflect.TypeOf(time.Duration(0))
+	timeType := reflect.TypeOf(time.Time{})
+
+	switch {
+	case f.value.Type() == timeType:
+		if len(raw) == 0 {
+			f.value.Set(reflect.ValueOf(time.Time{}))
+			return nil
+		}
+		t, err := parseTime(raw)
+		if err != nil {
+			return fmt.Errorf("config: %s from %s: invalid time %q, %w", f.env, source, raw, err)
+		}
+		f.value.Set(reflect.ValueOf(t))
+	case f.value.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("config: %s from %s: invalid duration %q", f.env, source, raw)
+		}
+		f.value.SetInt(int64(d))
+	case f.value.Kind() == reflect.String:
+		f.value.SetString(raw)
+	case f.value.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("config: %s from %s: invalid number %q", f.env, source, raw)
+		}
+		f.value.SetInt(int64(n))
+	case f.value.Kind() == reflect.Float64:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("config: %s from %s: invalid number %q", f.env, source, raw)
+		}
+		f.value.SetFloat(n)
+	case f.value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("config: %s from %s: invalid bool %q", f.env, source, raw)
+		}
+		f.value.SetBool(b)
+	default:
+		return fmt.Errorf("config: %s has unsupported type %s", f.env, f.value.Type())
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// unsetenv clears variables of the developer's environment the test relies on not being set
+func unsetenv(t *testing.T, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		if value, ok := os.LookupEnv(key); ok {
+			os.Unsetenv(key)
+			t.Cleanup(func() { os.Setenv(key, value) })
+		}
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	unsetenv(t, "POSTGRES_USER", "POSTGRES_DB", "DB_HOST", "HOLD_TTL", "OUTBOX_BATCH_SIZE", configFileEnv, envFileEnv)
+
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, "test.env")
+	configFile := filepath.Join(dir, "config.yaml")
+	write := func(path, content string) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(envFile, "POSTGRES_USER=dotenv\nPOSTGRES_DB=dotenv\nDB_HOST=dotenv\nDB_PORT=1111\nATTEMPTS=2\nHOLD_TTL=1m\n")
+	write(configFile, "db_host: file\ndb_port: 2222\nattempts: 3\n")
+	t.Setenv("DB_PORT", "3333")
+	t.Setenv("ATTEMPTS", "4")
+
+	cfg, _, err := LoadConfig([]string{"-env_file=" + envFile, "-config=" + configFile, "-attempts=5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{name: "dotenv over defaults", got: cfg.HoldTTL.String(), want: "1m0s"},
+		{name: "dotenv alone", got: cfg.User, want: "dotenv"},
+		{name: "file over dotenv", got: cfg.Host, want: "file"},
+		{name: "env over file", got: cfg.Port, want: "3333"},
+		{name: "flag over env", got: cfg.Attempts, want: 5},
+		{name: "default", got: cfg.OutboxBatchSize, want: 100},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	// The dotenv file is a layer of the config, it doesn't leak into the environment
+	if _, ok := os.LookupEnv("POSTGRES_USER"); ok {
+		t.Error("POSTGRES_USER set in the environment from the dotenv file")
+	}
+}
+
+func TestLoadConfigValidation(t *testing.T) {
+	unsetenv(t, "POSTGRES_USER", "POSTGRES_DB", "HOLD_TTL", "STORAGE_FEE_PER_DAY", configFileEnv, envFileEnv)
+
+	envFile := filepath.Join(t.TempDir(), "test.env")
+	if err := os.WriteFile(envFile, []byte("POSTGRES_USER=u\nPOSTGRES_DB=db\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		flag    string
+		wantErr string
+	}{
+		{name: "hold ttl zero", flag: "-hold_ttl=0s", wantErr: "HOLD_TTL must be positive"},
+		{name: "hold ttl negative", flag: "-hold_ttl=-1m", wantErr: "HOLD_TTL must be positive"},
+		{name: "negative storage fee", flag: "-storage_fee_per_day=-1", wantErr: "STORAGE_FEE_PER_DAY must be >= 0"},
+		{name: "free storage", flag: "-storage_fee_per_day=0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := LoadConfig([]string{"-env_file=" + envFile, tt.flag})
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
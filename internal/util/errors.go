@@ -1,25 +1,169 @@
 package util
 
-import "errors"
+import (
+	"context"
+	"errors"
+)
+
+// Code is a stable error class, clients may rely on it while messages change
+type Code string
+
+const (
+	CodeInvalidArgument    Code = "invalid_argument"
+	CodeNotFound           Code = "not_found"
+	CodeConflict           Code = "conflict"
+	CodeFailedPrecondition Code = "failed_precondition"
+	CodeUnauthenticated    Code = "unauthenticated"
+	CodePermissionDenied   Code = "permission_denied"
+	CodeUnavailable        Code = "unavailable"
+	CodeInternal           Code = "internal"
+)
+
+// Error is a domain error: Code tells what went wrong, Field which input caused it,
+// Message is shown to the user and Err keeps the underlying cause for the logs
+type Error struct {
+	Code    Code
+	Field   string
+	Message string
+	Err     error
+
+	// kind is the sentinel the error was derived from, errors.Is matches it
+	kind *Error
+}
+
+// sentinels keeps every error made by NewError by its message, see Restore
+var sentinels = make(map[string]*Error)
+
+// NewError makes a sentinel, it's meant for package level variables
+func NewError(code Code, field, message string) *Error {
+	e := &Error{Code: code, Field: field, Message: message}
+	sentinels[message] = e
+	return e
+}
+
+// Restore turns a stored error back into the sentinel it was derived from, so errors.Is keeps working
+// for an outcome replayed from storage
+func Restore(code Code, field, message string) *Error {
+	if sentinel, ok := sentinels[message]; ok && sentinel.Code == code {
+		return sentinel.WithField(field)
+	}
+	return &Error{Code: code, Field: field, Message: message}
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	return e.Message + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is makes errors derived with Wrap or WithField match their sentinel
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && (e == t || e.kind == t)
+}
+
+// Wrap returns a copy of the error carrying the cause
+func (e *Error) Wrap(cause error) *Error {
+	derived := e.derive()
+	derived.Err = cause
+	return derived
+}
+
+// WithField returns a copy of the error that points to another input
+func (e *Error) WithField(field string) *Error {
+	derived := e.derive()
+	derived.Field = field
+	return derived
+}
+
+// Kind returns the sentinel the error was derived from, or the error itself if it is one
+func (e *Error) Kind() *Error {
+	if e.kind != nil {
+		return e.kind
+	}
+	return e
+}
+
+func (e *Error) derive() *Error {
+	derived := *e
+	if derived.kind == nil {
+		derived.kind = e
+	}
+	return &derived
+}
+
+// AsError finds the domain error in the chain, a canceled context is reported as such and any other error as internal
+func AsError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return ErrCanceled.Wrap(err)
+	}
+	return ErrInternal.Wrap(err)
+}
 
 var (
-	ErrPriceNotProvided    = errors.New("error - price not provided")
-	ErrOrderPriceInvalid   = errors.New("error - invalid order price")
-	ErrWeightNotProvided   = errors.New("error - weight not provided")
-	ErrWeightExceeds       = errors.New("error - weight exceeds limit for this type of package")
-	ErrWeightInvalid       = errors.New("error - invalid weight")
-	ErrPackageTypeInvalid  = errors.New("error - invalid package type")
-	ErrDateInvalid         = errors.New("error - invalid date")
-	ErrOrderExists         = errors.New("error - order already exists")
-	ErrOrderNotFound       = errors.New("error - order not found")
-	ErrOrderIdInvalid      = errors.New("error - order id must be number")
-	ErrOrderExpired        = errors.New("error - order expired")
-	ErrOrderNotIssued      = errors.New("error - order not issued")
-	ErrOrderIssued         = errors.New("error - order issued")
-	ErrOrderIdNotProvided  = errors.New("error - order id not provided")
-	ErrUserIdNotProvided   = errors.New("error - user ids not provided")
-	ErrOrdersUserDiffers   = errors.New("error - order's user differs")
-	ErrOrderReturned       = errors.New("error - order has been returned")
-	ErrOrderDoesNotBelong  = errors.New("error - order does not belong to user")
-	ErrReturnPeriodExpired = errors.New("error - order cant be returned (period is expired)")
+	ErrPriceNotProvided      = NewError(CodeInvalidArgument, "price", "error - price not provided")
+	ErrOrderPriceInvalid     = NewError(CodeInvalidArgument, "price", "error - invalid order price")
+	ErrWeightNotProvided     = NewError(CodeInvalidArgument, "weight", "error - weight not provided")
+	ErrWeightExceeds         = NewError(CodeInvalidArgument, "weight", "error - weight exceeds limit for this type of package")
+	ErrWeightInvalid         = NewError(CodeInvalidArgument, "weight", "error - invalid weight")
+	ErrPackageTypeInvalid    = NewError(CodeInvalidArgument, "package_type", "error - invalid package type")
+	ErrDateInvalid           = NewError(CodeInvalidArgument, "storage_until", "error - invalid date")
+	ErrOrderExists           = NewError(CodeConflict, "id", "error - order already exists")
+	ErrOrderNotFound         = NewError(CodeNotFound, "id", "error - order not found")
+	ErrOrderIdInvalid        = NewError(CodeInvalidArgument, "id", "error - order id must be number")
+	ErrOrderExpired          = NewError(CodeFailedPrecondition, "id", "error - order expired")
+	ErrOrderNotIssued        = NewError(CodeFailedPrecondition, "id", "error - order not issued")
+	ErrOrderIssued           = NewError(CodeFailedPrecondition, "id", "error - order issued")
+	ErrOrderIdNotProvided    = NewError(CodeInvalidArgument, "id", "error - order id not provided")
+	ErrUserIdNotProvided     = NewError(CodeInvalidArgument, "user_id", "error - user ids not provided")
+	ErrOrdersUserDiffers     = NewError(CodeFailedPrecondition, "ids", "error - order's user differs")
+	ErrOrderReturned         = NewError(CodeFailedPrecondition, "id", "error - order has been returned")
+	ErrOrderDoesNotBelong    = NewError(CodeFailedPrecondition, "user_id", "error - order does not belong to user")
+	ErrReturnPeriodExpired   = NewError(CodeFailedPrecondition, "id", "error - order cant be returned (period is expired)")
+	ErrNoFreeCell            = NewError(CodeConflict, "package_type", "error - no free cell for this package")
+	ErrListParamInvalid      = NewError(CodeInvalidArgument, "", "error - offset must be a number >= 0, limit a number from 1 to 100")
+	ErrArgumentsInvalid      = NewError(CodeInvalidArgument, "", "error - invalid arguments")
+	ErrNotLoggedIn           = NewError(CodeUnauthenticated, "", "error - login required")
+	ErrPermissionDenied      = NewError(CodePermissionDenied, "", "error - permission denied")
+	ErrInvalidCredentials    = NewError(CodeUnauthenticated, "", "error - invalid login or password")
+	ErrOperatorExists        = NewError(CodeConflict, "login", "error - operator already exists")
+	ErrOperatorNotFound      = NewError(CodeNotFound, "login", "error - operator not found")
+	ErrRoleInvalid           = NewError(CodeInvalidArgument, "role", "error - invalid role")
+	ErrLoginNotProvided      = NewError(CodeInvalidArgument, "login", "error - login not provided")
+	ErrIdempotencyKeyReused  = NewError(CodeConflict, "idempotency_key", "error - idempotency key was used for another request")
+	ErrIdempotencyInProgress = NewError(CodeConflict, "idempotency_key", "error - request with this idempotency key is in progress")
+	ErrUserNotFound          = NewError(CodeNotFound, "user_id", "error - user not found")
+	ErrUserExists            = NewError(CodeConflict, "user_id", "error - user already exists")
+	ErrCourierNotFound       = NewError(CodeNotFound, "courier_id", "error - courier not found")
+	ErrCourierExists         = NewError(CodeConflict, "courier_id", "error - courier already exists")
+	ErrCourierIdNotProvided  = NewError(CodeInvalidArgument, "courier_id", "error - courier id not provided")
+	ErrCourierIdInvalid      = NewError(CodeInvalidArgument, "courier_id", "error - courier id may only have letters, digits, - and _")
+	ErrManifestEmpty         = NewError(CodeFailedPrecondition, "courier_id", "error - courier has no orders to return")
+	ErrManifestStale         = NewError(CodeConflict, "courier_id", "error - orders changed while the manifest was built, try again")
+	ErrManifestKeyNotSet     = NewError(CodeFailedPrecondition, "", "error - MANIFEST_KEY is not set, manifests can't be signed")
+	ErrOrderHeld             = NewError(CodeConflict, "id", "error - order is held by another operator")
+	ErrPaymentMethodInvalid  = NewError(CodeInvalidArgument, "method", "error - payment method must be cash or card")
+	ErrPaidInvalid           = NewError(CodeInvalidArgument, "paid", "error - paid amount must be a non-negative number")
+	ErrPrepaidInvalid        = NewError(CodeInvalidArgument, "prepaid", "error - prepaid amount must be a non-negative number")
+	ErrPrepaidExceedsTotal   = NewError(CodeInvalidArgument, "prepaid", "error - prepaid amount exceeds the orders total")
+	ErrPaymentInsufficient   = NewError(CodeFailedPrecondition, "paid", "error - paid amount is less than the amount due")
+	ErrCardAmountMismatch    = NewError(CodeInvalidArgument, "paid", "error - card is charged exactly the amount due")
+	ErrPaymentNotFound       = NewError(CodeNotFound, "id", "error - order was issued without a recorded payment")
+	ErrStatusInvalid         = NewError(CodeInvalidArgument, "status", "error - status must be stored, issued or returned")
+	ErrSortColumnInvalid     = NewError(CodeInvalidArgument, "sort", "error - orders can't be sorted by this column")
+	ErrRangeInvalid          = NewError(CodeInvalidArgument, "", "error - range start is after its end")
+	// ErrStorageUnavailable wraps db failures, so an outage is not mistaken for a missing order
+	ErrStorageUnavailable = NewError(CodeUnavailable, "", "error - storage unavailable, try again later")
+	ErrCanceled           = NewError(CodeUnavailable, "", "error - command canceled")
+	// ErrInternal hides unexpected errors from the user, the cause is only logged
+	ErrInternal = NewError(CodeInternal, "", "error - internal error")
 )
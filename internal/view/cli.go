@@ -2,11 +2,20 @@ package view
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"homework/internal/auth"
+	"homework/internal/health"
+	"homework/internal/i18n"
+	"homework/internal/logger"
+	"homework/internal/metrics"
+	"homework/internal/models"
 	"homework/internal/service"
-	"log"
+	"homework/internal/util"
+	"homework/pkg/limiter"
+	"log/slog"
 	"os"
 	"os/signal"
 	"runtime"
@@ -15,289 +24,775 @@ import (
 	"sync"
 	"sync/atomic"
 	"syscall"
+	"time"
 )
 
 type CLI struct {
-	validationService service.ValidationService
-	orderService      service.OrderService
-	commandList       []command
+	validationService   service.ValidationService
+	orderService        service.OrderService
+	locationService     service.LocationService
+	operatorService     service.OperatorService
+	notificationService service.NotificationService
+	idempotencyService  service.IdempotencyService
+	userService         service.UserService
+	courierService      service.CourierService
+	holdService         service.HoldService
+	paymentService      service.PaymentService
+	refundService       service.RefundService
+	commandList         []command
 
-	maxGoroutines    uint64
-	activeGoroutines uint64
+	// operator is the one logged in at this terminal, nil until login
+	operator atomic.Pointer[models.Operator]
+
+	// limiter bounds the commands processed at once, set_mg resizes it
+	limiter   *limiter.Limiter
+	jobs      *jobRegistry
+	workerSeq uint64
+
+	// shutdownTimeout is how long running commands may take after exit or a signal
+	shutdownTimeout time.Duration
+	shutdown        *shutdown
+
+	checker *health.Checker
 }
 
-func NewCLI(os service.OrderService, vs service.ValidationService) *CLI {
+func NewCLI(os service.OrderService, vs service.ValidationService, ls service.LocationService, ops service.OperatorService, ns service.NotificationService, is service.IdempotencyService, us service.UserService, cs service.CourierService, hs service.HoldService, ps service.PaymentService, rs service.RefundService, checker *health.Checker, shutdownTimeout time.Duration) *CLI {
 	return &CLI{
-		orderService:      os,
-		validationService: vs,
+		shutdownTimeout:     shutdownTimeout,
+		shutdown:            newShutdown(),
+		checker:             checker,
+		orderService:        os,
+		validationService:   vs,
+		locationService:     ls,
+		operatorService:     ops,
+		notificationService: ns,
+		idempotencyService:  is,
+		userService:         us,
+		courierService:      cs,
+		holdService:         hs,
+		paymentService:      ps,
+		refundService:       rs,
+		limiter:             limiter.New(runtime.GOMAXPROCS(0)),
+		jobs:                newJobRegistry(),
 		commandList: []command{
 			{
 				name:        help,
-				description: "Справка",
+				description: i18n.CmdHelp,
 			},
 			{
 				name:        acceptOrder,
-				description: "Принять заказ: accept -id=12345 -u_id=54321 -date=2077-06-06",
+				description: i18n.CmdAccept,
+				example:     "accept -id=12345 -u_id=54321 -date=2077-06-06 -courier=7",
 			},
 			{
 				name:        returnOrderToCourier,
-				description: "Вернуть заказ курьеру: return_courier -id=12345",
+				description: i18n.CmdReturnToCourier,
+				example:     "return_courier -id=12345",
+			},
+			{
+				name:        courierReturn,
+				description: i18n.CmdCourierReturn,
+				example:     "courier_return -id=7 -key=courier-7-0601",
+			},
+			{
+				name:        addCourier,
+				description: i18n.CmdAddCourier,
+				example:     "add_courier -id=7 -name=Oleg -phone=+79990000007",
 			},
 			{
 				name:        issueOrders,
-				description: "Выдать заказ клиенту: issue -ids=1,2,3",
+				description: i18n.CmdIssue,
+				example:     "issue -ids=1,2,3 -method=cash -paid=5000 -prepaid=1000 -key=issue-1-2-3",
+			},
+			{
+				name:        holdOrders,
+				description: i18n.CmdHold,
+				example:     "hold -ids=1,2,3",
+			},
+			{
+				name:        releaseHolds,
+				description: i18n.CmdRelease,
+				example:     "release -ids=1,2,3",
+			},
+			{
+				name:        listHolds,
+				description: i18n.CmdHolds,
+				example:     "holds",
 			},
 			{
 				name:        acceptReturn,
-				description: "Принять возврат: accept_return -id=1 -u_id=2",
+				description: i18n.CmdAcceptReturn,
+				example:     "accept_return -id=1 -u_id=2",
 			},
 			{
 				name:        listReturns,
-				description: "Список возвратов: list_returns -lmt=10 -ofs=0",
+				description: i18n.CmdListReturns,
+				example:     "list_returns -lmt=10 -ofs=0",
+			},
+			{
+				name:        listRefunds,
+				description: i18n.CmdRefunds,
+				example:     "refunds -u_id=1 -lmt=10 -ofs=0",
 			},
 			{
 				name:        listOrders,
-				description: "Список заказов: list_orders -u_id=1 -lmt=10 -ofs=0",
+				description: i18n.CmdListOrders,
+				example:     "list_orders -u_id=1 -lmt=10 -ofs=0",
+			},
+			{
+				name:        searchOrders,
+				description: i18n.CmdSearch,
+				example:     "search -status=stored -until_to=2024-07-31 -p=box -sort=storage_until -desc -lmt=20",
+			},
+			{
+				name:        showUser,
+				description: i18n.CmdUser,
+				example:     "user -id=54321",
+			},
+			{
+				name:        addUser,
+				description: i18n.CmdAddUser,
+				example:     "add_user -id=54321 -name=Ivan -phone=+79990000000",
+			},
+			{
+				name:        listLocations,
+				description: i18n.CmdLocations,
+				example:     "locations",
 			},
 			{
 				name:        setMaxGoroutines,
-				description: "Максимальное кол-во горутин: set_mg -n=1",
+				description: i18n.CmdSetMaxGoroutines,
+				example:     "set_mg -n=1",
+			},
+			{
+				name:        status,
+				description: i18n.CmdStatus,
+				example:     "status",
+			},
+			{
+				name:        listJobs,
+				description: i18n.CmdJobs,
+				example:     "jobs",
+			},
+			{
+				name:        listNotifications,
+				description: i18n.CmdNotifications,
+				example:     "notifications -id=12345",
+			},
+			{
+				name:        healthCheck,
+				description: i18n.CmdHealth,
+				example:     "health",
+			},
+			{
+				name:        login,
+				description: i18n.CmdLogin,
+				example:     "login -u=admin -p=secret",
+			},
+			{
+				name:        logout,
+				description: i18n.CmdLogout,
+				example:     "logout",
+			},
+			{
+				name:        addOperator,
+				description: i18n.CmdAddOperator,
+				example:     "add_operator -u=ivan -p=secret -role=clerk",
 			},
 			{
 				name:        exit,
-				description: "Выход",
+				description: i18n.CmdExit,
 			},
 		},
 	}
 }
 
+// RegisterMetrics exposes the worker pool load
+func (c *CLI) RegisterMetrics(registry *metrics.Registry) {
+	registry.NewGaugeFunc("pvz_active_goroutines", "Workers holding a slot and processing a command.", func() float64 {
+		return float64(c.limiter.Active())
+	})
+	registry.NewGaugeFunc("pvz_max_goroutines", "Maximum number of commands processed in parallel.", func() float64 {
+		return float64(c.limiter.Limit())
+	})
+	registry.NewGaugeFunc("pvz_queued_commands", "Workers waiting for a free slot.", func() float64 {
+		return float64(c.limiter.Waiting())
+	})
+	registry.NewGaugeFunc("pvz_hash_backlog", "Order hashes being generated.", func() float64 {
+		return float64(c.orderService.HashBacklog())
+	})
+}
+
+// RegisterHealth adds the shutdown state and the worker backlog checks,
+// the app stops being ready once more than maxBacklog commands and hashes are waiting
+func (c *CLI) RegisterHealth(checker *health.Checker, maxBacklog int) {
+	checker.Register("shutdown", func(context.Context) (string, error) {
+		if c.shutdown.stopping() {
+			return "", errors.New("shutting down")
+		}
+		return "running", nil
+	})
+	checker.Register("backlog", func(context.Context) (string, error) {
+		queued, hashing := c.limiter.Waiting(), c.orderService.HashBacklog()
+		detail := fmt.Sprintf("%d commands queued, %d hashes in progress", queued, hashing)
+		if queued+hashing > maxBacklog {
+			return "", fmt.Errorf("%s, limit is %d", detail, maxBacklog)
+		}
+		return detail, nil
+	})
+}
+
 func (c *CLI) Run() error {
-	semaphore := make(chan struct{}, 1)
 	commandChannel := make(chan string)
-	done := make(chan struct{})
+	sd := c.shutdown
+	defer sd.cancelRun()
 
-	signalChannel := make(chan os.Signal, 1)
+	signalChannel := make(chan os.Signal, 2)
 	signal.Notify(signalChannel, syscall.SIGINT, syscall.SIGTERM)
-	if err := c.setMaxGoroutines(fmt.Sprintf(
-		"set_mg -n=%s", strconv.Itoa(runtime.GOMAXPROCS(0))),
-		&semaphore); err != nil {
-		return err
-	}
+	defer signal.Stop(signalChannel)
 
-	var wg sync.WaitGroup
-
-	go signalListener(signalChannel, done)
+	go sd.listen(signalChannel, func() {
+		fmt.Printf("\n%s\n", i18n.T(i18n.MsgShutdownSignal, c.shutdownTimeout))
+		slog.Info("received shutdown signal", "grace_period", c.shutdownTimeout)
+	}, func() {
+		fmt.Printf("\n%s\n", i18n.T(i18n.MsgForcedExit))
+		slog.Warn("forced exit")
+		c.printAborted(c.jobs.list())
+		os.Exit(1)
+	})
 
 	//Reader
 	go func() {
 		scanner := bufio.NewScanner(os.Stdin)
 		for scanner.Scan() {
-			commandChannel <- scanner.Text()
+			select {
+			case commandChannel <- scanner.Text():
+			case <-sd.stop:
+				return
+			}
 		}
 	}()
 
-	//Handler
-	go c.commandHandler(commandChannel, semaphore, done, &wg)
-
-	<-done
+	var wg sync.WaitGroup
 
-	wg.Wait()
+	//Handler, returns once shutdown is requested so no worker is started after drain begins
+	c.commandHandler(commandChannel, sd, &wg)
 
-	//Close where created
-	close(semaphore)
-	fmt.Println("All goroutines finished. Exiting...")
+	if sd.drain(&wg, c.shutdownTimeout) {
+		slog.Warn("grace period is over, running commands canceled", "grace_period", c.shutdownTimeout)
+	}
+	c.printAborted(c.jobs.listAborted())
+	fmt.Println(i18n.T(i18n.MsgExiting))
 
 	return nil
 }
 
-func signalListener(signalChannel chan os.Signal, done chan struct{}) {
-	for {
-		<-signalChannel
-		fmt.Println("\nReceived shutdown signal")
-		done <- struct{}{}
+func (c *CLI) printAborted(jobs []job) {
+	if len(jobs) == 0 {
+		return
+	}
+
+	fmt.Println(i18n.T(i18n.MsgAbortedCommands))
+	for _, j := range jobs {
+		fmt.Printf("  #%d %-8s %s [%s]\n", j.ID, j.State, j.Command, j.CorrelationID)
+		slog.Warn("command aborted", "job", j.ID, "state", j.State, "command", j.Command, logger.CorrelationKey, j.CorrelationID)
 	}
 }
 
-func (c *CLI) commandHandler(commandChannel chan string, semaphore chan struct{}, done chan struct{}, wg *sync.WaitGroup) {
+// commandHandler runs pool management commands in place, so they are not queued behind the very workers they inspect,
+// everything else goes to a worker
+func (c *CLI) commandHandler(commandChannel chan string, sd *shutdown, wg *sync.WaitGroup) {
 	for {
-		cmd := <-commandChannel
+		var cmd string
+		select {
+		case <-sd.stop:
+			return
+		case cmd = <-commandChannel:
+		}
+		if sd.stopping() {
+			return
+		}
+
+		ctx := c.sessionContext(logger.WithCorrelationID(sd.runCtx, logger.NewCorrelationID()))
+		slog.InfoContext(ctx, "command received", "command", commandLine(cmd), "operator", auth.Actor(ctx))
 
-		if strings.HasPrefix(cmd, exit) {
-			done <- struct{}{}
-		} else if strings.HasPrefix(cmd, setMaxGoroutines) {
-			if err := c.setMaxGoroutines(cmd, &semaphore); err != nil {
-				log.Fatal(err)
+		commandName := strings.SplitN(cmd, " ", 2)[0]
+		switch commandName {
+		case exit:
+			sd.request()
+			return
+		case setMaxGoroutines, status, listJobs, healthCheck:
+			if err := c.poolCommand(ctx, commandName, cmd); err != nil {
+				c.printError(ctx, err)
 			}
-		} else {
+		default:
+			id := atomic.AddUint64(&c.workerSeq, 1)
+			c.jobs.add(&job{
+				ID:            id,
+				Command:       redact(cmd),
+				Operator:      auth.Actor(ctx),
+				CorrelationID: logger.CorrelationID(ctx),
+			})
 			wg.Add(1)
-			atomic.AddUint64(&c.activeGoroutines, 1)
-			id := atomic.LoadUint64(&c.activeGoroutines)
 
-			go c.worker(cmd, id, semaphore, wg)
+			go c.worker(sd.queueCtx, ctx, cmd, id, wg)
 		}
 	}
 }
 
-func (c *CLI) worker(cmd string, id uint64, semaphore chan struct{}, wg *sync.WaitGroup) {
+func (c *CLI) poolCommand(ctx context.Context, commandName, cmd string) error {
+	if err := auth.Authorize(ctx, commandRoles[commandName]); err != nil {
+		return err
+	}
+
+	switch commandName {
+	case setMaxGoroutines:
+		return c.setMaxGoroutines(cmd)
+	case status:
+		c.status()
+	case listJobs:
+		c.listJobs()
+	case healthCheck:
+		c.health(ctx)
+	}
+	return nil
+}
+
+// worker waits for a slot while queueCtx is alive and runs the command with ctx,
+// a command that didn't get a slot or was canceled mid-way is reported as aborted on shutdown
+func (c *CLI) worker(queueCtx, ctx context.Context, cmd string, id uint64, wg *sync.WaitGroup) {
 	defer wg.Done()
-	log.Printf("Worker %d: Waiting to acquire semaphore\n", id)
-	semaphore <- struct{}{}
 
-	log.Printf("Worker %d: Working\n", id)
-	c.processCommand(cmd)
+	slog.DebugContext(ctx, "waiting to acquire semaphore", "worker", id)
+	waitStart := time.Now()
+	if err := c.limiter.Acquire(queueCtx); err != nil {
+		slog.WarnContext(ctx, "dropped from the queue", "worker", id, "err", err)
+		c.jobs.abort(id)
+		return
+	}
+	metrics.SemaphoreWait.Observe(time.Since(waitStart).Seconds())
+	c.jobs.start(id)
+
+	slog.DebugContext(ctx, "working", "worker", id)
+	start := time.Now()
+	c.processCommand(ctx, cmd)
+	slog.InfoContext(ctx, "command finished", "worker", id, "duration", time.Since(start))
+
+	slog.DebugContext(ctx, "semaphore released", "worker", id)
+	c.limiter.Release()
 
-	log.Printf("Worker %d: Semaphore released\n\n", id)
-	<-semaphore
+	if ctx.Err() != nil {
+		c.jobs.abort(id)
+		return
+	}
+	c.jobs.remove(id)
 }
 
-func (c *CLI) setMaxGoroutines(input string, semaphore *chan struct{}) error {
+func (c *CLI) setMaxGoroutines(input string) error {
 	args := strings.Split(input, " ")
 	args = args[1:]
 	var ns string
 	fs := flag.NewFlagSet(setMaxGoroutines, flag.ContinueOnError)
 	fs.StringVar(&ns, "n", "0", "use -n=1")
 	if err := fs.Parse(args); err != nil {
-		return err
+		return util.ErrArgumentsInvalid.Wrap(err)
 	}
 
-	if len(ns) == 0 {
-		return errors.New("number of goroutines is required")
-	}
 	n, err := strconv.Atoi(ns)
 	if err != nil {
-		return errors.Join(err, errors.New("invalid argument"))
+		return errGoroutinesInvalid.Wrap(err)
 	}
 	if n < 1 {
-		return errors.New("number of goroutines must be > 0")
+		return errGoroutinesInvalid
 	}
 
-	atomic.StoreUint64(&c.maxGoroutines, uint64(n))
-	*semaphore = make(chan struct{}, n)
+	c.limiter.SetLimit(n)
 
-	fmt.Printf("Number of goroutines set to %d\n", n)
+	fmt.Println(i18n.T(i18n.MsgGoroutinesSet, n))
+	slog.Info("max goroutines changed", "n", n)
 	return nil
 }
 
-func (c *CLI) processCommand(input string) {
+func (c *CLI) status() {
+	var running, queued int
+	for _, j := range c.jobs.list() {
+		if j.State == jobRunning {
+			running++
+		} else {
+			queued++
+		}
+	}
+
+	fmt.Println(i18n.T(i18n.MsgStatusLimit, c.limiter.Limit()))
+	fmt.Println(i18n.T(i18n.MsgStatusRunning, running))
+	fmt.Println(i18n.T(i18n.MsgStatusQueued, queued))
+	if operator := c.operator.Load(); operator != nil {
+		fmt.Println(i18n.T(i18n.MsgStatusOperator, operator.Login, operator.Role))
+	}
+}
+
+func (c *CLI) listJobs() {
+	jobs := c.jobs.list()
+	now := time.Now()
+
+	fmt.Printf("%-6s%-10s%-15s%-14s%-10s%s\n", i18n.T(i18n.ColumnJob), i18n.T(i18n.ColumnState), i18n.T(i18n.ColumnOperator), i18n.T(i18n.ColumnCorrelation), i18n.T(i18n.ColumnAge), i18n.T(i18n.ColumnCommand))
+	fmt.Println(strings.Repeat("-", 80))
+	for _, j := range jobs {
+		since := j.QueuedAt
+		if j.State == jobRunning {
+			since = j.StartedAt
+		}
+		fmt.Printf("%-6d%-10s%-15s%-14s%-10s%s\n",
+			j.ID,
+			j.State,
+			j.Operator,
+			j.CorrelationID,
+			now.Sub(since).Truncate(time.Millisecond),
+			j.Command)
+	}
+	fmt.Printf("\n")
+}
+
+// health prints the same checks as /readyz
+func (c *CLI) health(ctx context.Context) {
+	report := c.checker.Run(ctx)
+
+	fmt.Printf("%-12s%-8s%s\n", i18n.T(i18n.ColumnCheck), i18n.T(i18n.ColumnStatus), i18n.T(i18n.ColumnDetail))
+	fmt.Println(strings.Repeat("-", 60))
+	for _, result := range report.Checks {
+		fmt.Printf("%-12s%-8s%s\n", result.Name, result.Status, result.Detail)
+	}
+	readiness := i18n.MsgReady
+	if !report.Ready() {
+		readiness = i18n.MsgNotReady
+	}
+	fmt.Printf("\n%s\n", i18n.T(readiness))
+}
+
+func (c *CLI) processCommand(ctx context.Context, input string) {
 	args := strings.Split(input, " ")
 	commandName := args[0]
 
+	start := time.Now()
+	err := c.executeCommand(ctx, commandName, args[1:])
+
+	// Arbitrary input must not turn into label values
+	label := commandName
+	if _, ok := c.findCommand(commandName); !ok {
+		label = "unknown"
+	}
+	status := metrics.StatusOK
+	if err != nil {
+		status = metrics.StatusError
+		c.printError(ctx, err)
+	}
+	metrics.CommandsTotal.Inc(label, status)
+	metrics.CommandDuration.Observe(time.Since(start).Seconds(), label)
+}
+
+func (c *CLI) executeCommand(ctx context.Context, commandName string, args []string) error {
+	if err := auth.Authorize(ctx, commandRoles[commandName]); err != nil {
+		return err
+	}
+
 	switch commandName {
 	case acceptOrder:
-		if err := c.acceptOrder(args[1:]); err != nil {
-			log.Println(err)
-		} else {
-			log.Println("Order accepted.")
-		}
+		return c.acceptOrder(ctx, args)
 	case issueOrders:
-		if err := c.issueOrders(args[1:]); err != nil {
-			log.Println(err)
-		}
+		return c.issueOrders(ctx, args)
 	case acceptReturn:
-		if err := c.acceptReturn(args[1:]); err != nil {
-			log.Println(err)
-		} else {
-			log.Println("Return accepted.")
+		if err := c.acceptReturn(ctx, args); err != nil {
+			return err
 		}
+		fmt.Println(i18n.T(i18n.MsgReturnAccepted))
 	case returnOrderToCourier:
-		if err := c.returnOrderToCourier(args[1:]); err != nil {
-			log.Println(err)
-		} else {
-			log.Println("Order returned.")
+		if err := c.returnOrderToCourier(ctx, args); err != nil {
+			return err
 		}
+		fmt.Println(i18n.T(i18n.MsgOrderReturned))
 	case listReturns:
-		if err := c.listReturns(args[1:]); err != nil {
-			log.Println(err)
-		}
+		return c.listReturns(ctx, args)
+	case listRefunds:
+		return c.listRefunds(ctx, args)
 	case listOrders:
-		if err := c.listOrders(args[1:]); err != nil {
-			log.Println(err)
-		}
+		return c.listOrders(ctx, args)
+	case searchOrders:
+		return c.searchOrders(ctx, args)
+	case holdOrders:
+		return c.holdOrders(ctx, args)
+	case releaseHolds:
+		return c.releaseHolds(ctx, args)
+	case listHolds:
+		return c.listHolds(ctx)
+	case courierReturn:
+		return c.courierReturn(ctx, args)
+	case addCourier:
+		return c.addCourier(ctx, args)
+	case showUser:
+		return c.showUser(ctx, args)
+	case addUser:
+		return c.addUser(ctx, args)
+	case listLocations:
+		return c.listLocations(ctx)
+	case listNotifications:
+		return c.listNotifications(ctx, args)
+	case login:
+		return c.login(ctx, args)
+	case logout:
+		c.logout()
+	case addOperator:
+		return c.addOperator(ctx, args)
 	case help:
 		c.help()
 	default:
-		fmt.Println("Unknown command. Type 'help' for a list of commands.")
+		fmt.Println(i18n.T(i18n.MsgUnknownCommand))
 	}
+	return nil
 }
 
-func (c *CLI) acceptOrder(args []string) error {
-	var idStr, userId, dateStr, pkgTypeStr, weightStr, orderPriceStr string
+func (c *CLI) findCommand(name string) (command, bool) {
+	for _, cmd := range c.commandList {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func (c *CLI) acceptOrder(ctx context.Context, args []string) error {
+	var idStr, userId, dateStr, pkgTypeStr, weightStr, orderPriceStr, courierId, key string
 	fs := flag.NewFlagSet(acceptOrder, flag.ContinueOnError)
+	fs.StringVar(&key, "key", "", idempotencyKeyUsage)
 	fs.StringVar(&idStr, "id", "", "use -id=12345")
 	fs.StringVar(&userId, "u_id", "", "use -u_id=54321")
 	fs.StringVar(&dateStr, "date", "", "use -date=2024-06-06")
 	fs.StringVar(&orderPriceStr, "price", "", "use -price=999.99")
 	fs.StringVar(&weightStr, "w", "", "use -w=10.0")
 	fs.StringVar(&pkgTypeStr, "p", "", "use -p=box")
+	fs.StringVar(&courierId, "courier", "", "use -courier=7 for the courier who brought the order")
 
 	if err := fs.Parse(args); err != nil {
-		return err
+		return util.ErrArgumentsInvalid.Wrap(err)
 	}
 
-	order, err := c.validationService.ValidateAccept(idStr, userId, dateStr, orderPriceStr, weightStr, pkgTypeStr)
+	fingerprint := service.Fingerprint(idStr, userId, dateStr, orderPriceStr, weightStr, pkgTypeStr, courierId)
+	order, _, err := service.Idempotent(ctx, c.idempotencyService, key, acceptOrder, fingerprint, func(ctx context.Context) (*models.Order, error) {
+		order, err := c.validationService.ValidateAccept(ctx, idStr, userId, dateStr, orderPriceStr, weightStr, pkgTypeStr, courierId)
+		if err != nil {
+			return nil, err
+		}
+		return order, c.orderService.Accept(ctx, order, pkgTypeStr, os.Stdout)
+	})
 	if err != nil {
 		return err
 	}
 
-	return c.orderService.Accept(order, pkgTypeStr)
+	fmt.Println(i18n.T(i18n.MsgOrderAccepted, order.CellID))
+	return nil
 }
 
-func (c *CLI) issueOrders(args []string) error {
-	var idString string
+func (c *CLI) issueOrders(ctx context.Context, args []string) error {
+	var idString, method, paid, prepaid, receiptFormat, key string
 	fs := flag.NewFlagSet(issueOrders, flag.ContinueOnError)
 	fs.StringVar(&idString, "ids", "", "use -ids=1,2,3")
+	fs.StringVar(&method, "method", "", "use -method=cash or -method=card, not needed for prepaid orders")
+	fs.StringVar(&paid, "paid", "", "use -paid=5000, the cash handed over, a card is charged the amount due")
+	fs.StringVar(&prepaid, "prepaid", "", "use -prepaid=1000, paid before pickup")
+	fs.StringVar(&receiptFormat, "receipt", "text", "use -receipt=text or -receipt=json")
+	fs.StringVar(&key, "key", "", idempotencyKeyUsage)
 	if err := fs.Parse(args); err != nil {
+		return util.ErrArgumentsInvalid.Wrap(err)
+	}
+	// Checked before the issue, a bad format must not fail the command after the orders are given out
+	if receiptFormat != "text" && receiptFormat != "json" {
+		return util.ErrArgumentsInvalid
+	}
+	ids := strings.Split(idString, ",")
+
+	fingerprint := service.Fingerprint(idString, method, paid, prepaid)
+	receipt, _, err := service.Idempotent(ctx, c.idempotencyService, key, issueOrders, fingerprint, func(ctx context.Context) (models.Receipt, error) {
+		tender, err := c.validationService.ValidateTender(method, paid, prepaid)
+		if err != nil {
+			return models.Receipt{}, err
+		}
+		ordersToIssue, err := c.validationService.ValidateIssue(ctx, ids)
+		if err != nil {
+			return models.Receipt{}, err
+		}
+		payment, err := c.paymentService.Charge(*ordersToIssue, tender)
+		if err != nil {
+			return models.Receipt{}, err
+		}
+		if err = c.orderService.Issue(ctx, ordersToIssue, &payment); err != nil {
+			return models.Receipt{}, err
+		}
+		return models.Receipt{Payment: payment, Orders: *ordersToIssue}, nil
+	})
+	if err != nil {
 		return err
 	}
+
+	for _, order := range receipt.Orders {
+		fmt.Println(i18n.T(i18n.MsgOrderIssued, order.ID, order.CellID))
+	}
+	return c.paymentService.PrintReceipt(receipt.Payment, receiptFormat == "json")
+}
+
+func (c *CLI) holdOrders(ctx context.Context, args []string) error {
+	var idString string
+	fs := flag.NewFlagSet(holdOrders, flag.ContinueOnError)
+	fs.StringVar(&idString, "ids", "", "use -ids=1,2,3")
+	if err := fs.Parse(args); err != nil {
+		return util.ErrArgumentsInvalid.Wrap(err)
+	}
 	ids := strings.Split(idString, ",")
 
-	ordersToIssue, err := c.validationService.ValidateIssue(ids)
+	if err := c.validationService.ValidateHold(ctx, ids); err != nil {
+		return err
+	}
+	holds, err := c.holdService.Hold(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, hold := range holds {
+		fmt.Println(i18n.T(i18n.MsgOrderHeld, hold.OrderID, hold.ExpiresAt.Format(time.DateTime)))
+	}
+	return nil
+}
+
+func (c *CLI) releaseHolds(ctx context.Context, args []string) error {
+	var idString string
+	fs := flag.NewFlagSet(releaseHolds, flag.ContinueOnError)
+	fs.StringVar(&idString, "ids", "", "use -ids=1,2,3")
+	if err := fs.Parse(args); err != nil {
+		return util.ErrArgumentsInvalid.Wrap(err)
+	}
+
+	if err := c.holdService.Release(ctx, strings.Split(idString, ",")); err != nil {
+		return err
+	}
+
+	fmt.Println(i18n.T(i18n.MsgHoldsReleased))
+	return nil
+}
+
+func (c *CLI) listHolds(ctx context.Context) error {
+	holds, err := c.holdService.List(ctx)
 	if err != nil {
 		return err
 	}
-	return c.orderService.Issue(ordersToIssue)
+
+	c.holdService.PrintList(holds)
+
+	return nil
 }
 
-func (c *CLI) acceptReturn(args []string) error {
-	var id, userId string
+func (c *CLI) acceptReturn(ctx context.Context, args []string) error {
+	var id, userId, key string
 	fs := flag.NewFlagSet(acceptReturn, flag.ContinueOnError)
 	fs.StringVar(&id, "id", "0", "use -id=12345")
 	fs.StringVar(&userId, "u_id", "0", "use -u_id=54321")
+	fs.StringVar(&key, "key", "", idempotencyKeyUsage)
 	if err := fs.Parse(args); err != nil {
-		return err
+		return util.ErrArgumentsInvalid.Wrap(err)
 	}
 
-	orderToReturn, err := c.validationService.ValidateAcceptReturn(id, userId)
+	receipt, _, err := service.Idempotent(ctx, c.idempotencyService, key, acceptReturn, service.Fingerprint(id, userId), func(ctx context.Context) (models.ReturnReceipt, error) {
+		orderToReturn, err := c.validationService.ValidateAcceptReturn(ctx, id, userId)
+		if err != nil {
+			return models.ReturnReceipt{}, err
+		}
+		refund, err := c.refundService.Calculate(ctx, *orderToReturn)
+		if err != nil {
+			return models.ReturnReceipt{}, err
+		}
+		if err = c.orderService.Return(ctx, orderToReturn, &refund); err != nil {
+			return models.ReturnReceipt{}, err
+		}
+		return models.ReturnReceipt{Order: *orderToReturn, Refund: refund}, nil
+	})
 	if err != nil {
 		return err
 	}
-	return c.orderService.Return(orderToReturn)
+
+	c.refundService.PrintRefund(receipt.Refund)
+	return nil
 }
 
-func (c *CLI) returnOrderToCourier(args []string) error {
-	var id string
+func (c *CLI) returnOrderToCourier(ctx context.Context, args []string) error {
+	var id, key string
 	fs := flag.NewFlagSet(returnOrderToCourier, flag.ContinueOnError)
 	fs.StringVar(&id, "id", "0", "use -id=12345")
+	fs.StringVar(&key, "key", "", idempotencyKeyUsage)
+
+	if err := fs.Parse(args); err != nil {
+		return util.ErrArgumentsInvalid.Wrap(err)
+	}
+
+	_, _, err := service.Idempotent(ctx, c.idempotencyService, key, returnOrderToCourier, service.Fingerprint(id), func(ctx context.Context) (struct{}, error) {
+		if err := c.validationService.ValidateReturnToCourier(ctx, id); err != nil {
+			return struct{}{}, err
+		}
+		return struct{}{}, c.orderService.ReturnToCourier(ctx, id)
+	})
+	return err
+}
+
+func (c *CLI) courierReturn(ctx context.Context, args []string) error {
+	var courierId, key string
+	fs := flag.NewFlagSet(courierReturn, flag.ContinueOnError)
+	fs.StringVar(&courierId, "id", "", "use -id=7")
+	fs.StringVar(&key, "key", "", idempotencyKeyUsage)
 
 	if err := fs.Parse(args); err != nil {
+		return util.ErrArgumentsInvalid.Wrap(err)
+	}
+
+	manifest, _, err := service.Idempotent(ctx, c.idempotencyService, key, courierReturn, service.Fingerprint(courierId), func(ctx context.Context) (models.Manifest, error) {
+		orders, err := c.validationService.ValidateCourierReturn(ctx, courierId)
+		if err != nil {
+			return models.Manifest{}, err
+		}
+		return c.courierService.Return(ctx, courierId, orders)
+	})
+	if err != nil {
 		return err
 	}
 
-	if err := c.validationService.ValidateReturnToCourier(id); err != nil {
+	c.courierService.PrintManifest(manifest)
+
+	return nil
+}
+
+func (c *CLI) addCourier(ctx context.Context, args []string) error {
+	var id, name, phone string
+	fs := flag.NewFlagSet(addCourier, flag.ContinueOnError)
+	fs.StringVar(&id, "id", "", "use -id=7")
+	fs.StringVar(&name, "name", "", "use -name=Oleg")
+	fs.StringVar(&phone, "phone", "", "use -phone=+79990000007")
+
+	if err := fs.Parse(args); err != nil {
+		return util.ErrArgumentsInvalid.Wrap(err)
+	}
+
+	courier, err := c.courierService.Create(ctx, id, name, phone)
+	if err != nil {
 		return err
 	}
 
-	return c.orderService.ReturnToCourier(id)
+	fmt.Println(i18n.T(i18n.MsgCourierCreated, courier.ID))
+	return nil
 }
 
-func (c *CLI) listReturns(args []string) error {
+func (c *CLI) listReturns(ctx context.Context, args []string) error {
 	var offsetStr, limitStr string
 	fs := flag.NewFlagSet(listReturns, flag.ContinueOnError)
 	fs.StringVar(&offsetStr, "ofs", "0", "use -ofs=0")
-	fs.StringVar(&limitStr, "lmt", "0", "use -lmt=10")
+	fs.StringVar(&limitStr, "lmt", "10", "use -lmt=10")
 
 	if err := fs.Parse(args); err != nil {
-		return err
+		return util.ErrArgumentsInvalid.Wrap(err)
 	}
 
 	offset, limit, err := c.validationService.ValidateList(offsetStr, limitStr)
@@ -305,7 +800,7 @@ func (c *CLI) listReturns(args []string) error {
 		return err
 	}
 
-	orderIDs, err := c.orderService.ListReturns(offset, limit)
+	orderIDs, err := c.orderService.ListReturns(ctx, offset, limit)
 	if err != nil {
 		return err
 	}
@@ -315,15 +810,39 @@ func (c *CLI) listReturns(args []string) error {
 	return nil
 }
 
-func (c *CLI) listOrders(args []string) error {
+func (c *CLI) listRefunds(ctx context.Context, args []string) error {
+	var userId, offsetStr, limitStr string
+	fs := flag.NewFlagSet(listRefunds, flag.ContinueOnError)
+	fs.StringVar(&userId, "u_id", "", "use -u_id=1, all customers without it")
+	fs.StringVar(&offsetStr, "ofs", "0", "use -ofs=0")
+	fs.StringVar(&limitStr, "lmt", "10", "use -lmt=10")
+	if err := fs.Parse(args); err != nil {
+		return util.ErrArgumentsInvalid.Wrap(err)
+	}
+
+	offset, limit, err := c.validationService.ValidateList(offsetStr, limitStr)
+	if err != nil {
+		return err
+	}
+
+	refunds, err := c.refundService.List(ctx, userId, offset, limit)
+	if err != nil {
+		return err
+	}
+
+	c.refundService.PrintList(refunds)
+	return nil
+}
+
+func (c *CLI) listOrders(ctx context.Context, args []string) error {
 	var userId, offsetStr, limitStr string
 	fs := flag.NewFlagSet(listOrders, flag.ContinueOnError)
 	fs.StringVar(&userId, "u_id", "0", "use -u_id=1")
 	fs.StringVar(&offsetStr, "ofs", "0", "use -ofs=0")
-	fs.StringVar(&limitStr, "lmt", "0", "use -lmt=10")
+	fs.StringVar(&limitStr, "lmt", "10", "use -lmt=10")
 
 	if err := fs.Parse(args); err != nil {
-		return err
+		return util.ErrArgumentsInvalid.Wrap(err)
 	}
 
 	offset, limit, err := c.validationService.ValidateList(offsetStr, limitStr)
@@ -331,7 +850,7 @@ func (c *CLI) listOrders(args []string) error {
 		return err
 	}
 
-	orders, err := c.orderService.ListOrders(userId, offset, limit)
+	orders, err := c.orderService.ListOrders(ctx, userId, offset, limit)
 	if err != nil {
 		return err
 	}
@@ -341,20 +860,185 @@ func (c *CLI) listOrders(args []string) error {
 	return nil
 }
 
+func (c *CLI) searchOrders(ctx context.Context, args []string) error {
+	var params service.SearchParams
+	fs := flag.NewFlagSet(searchOrders, flag.ContinueOnError)
+	fs.StringVar(&params.Status, "status", "", "use -status=stored, issued or returned")
+	fs.StringVar(&params.IDPrefix, "id", "", "use -id=123 for the ids starting with it")
+	fs.StringVar(&params.UserID, "u_id", "", "use -u_id=54321")
+	fs.StringVar(&params.PackageType, "p", "", "use -p=box")
+	fs.StringVar(&params.StorageFrom, "until_from", "", "use -until_from=2024-06-01")
+	fs.StringVar(&params.StorageTo, "until_to", "", "use -until_to=2024-06-30")
+	fs.StringVar(&params.IssuedFrom, "issued_from", "", "use -issued_from=2024-06-01")
+	fs.StringVar(&params.IssuedTo, "issued_to", "", "use -issued_to=2024-06-30")
+	fs.StringVar(&params.PriceMin, "price_min", "", "use -price_min=100")
+	fs.StringVar(&params.PriceMax, "price_max", "", "use -price_max=999.99")
+	fs.StringVar(&params.WeightMin, "w_min", "", "use -w_min=1")
+	fs.StringVar(&params.WeightMax, "w_max", "", "use -w_max=10")
+	fs.StringVar(&params.SortBy, "sort", "id", "use -sort=storage_until")
+	fs.BoolVar(&params.Desc, "desc", false, "use -desc to sort in descending order")
+	fs.StringVar(&params.Offset, "ofs", "0", "use -ofs=0")
+	fs.StringVar(&params.Limit, "lmt", "10", "use -lmt=10")
+
+	if err := fs.Parse(args); err != nil {
+		return util.ErrArgumentsInvalid.Wrap(err)
+	}
+
+	filter, err := c.validationService.ValidateSearch(params)
+	if err != nil {
+		return err
+	}
+
+	orders, err := c.orderService.Search(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	c.orderService.PrintList(orders)
+
+	return nil
+}
+
+func (c *CLI) showUser(ctx context.Context, args []string) error {
+	var id string
+	fs := flag.NewFlagSet(showUser, flag.ContinueOnError)
+	fs.StringVar(&id, "id", "", "use -id=54321")
+
+	if err := fs.Parse(args); err != nil {
+		return util.ErrArgumentsInvalid.Wrap(err)
+	}
+
+	summary, err := c.userService.Summary(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	c.userService.PrintSummary(summary)
+
+	return nil
+}
+
+func (c *CLI) addUser(ctx context.Context, args []string) error {
+	var id, name, phone string
+	fs := flag.NewFlagSet(addUser, flag.ContinueOnError)
+	fs.StringVar(&id, "id", "", "use -id=54321")
+	fs.StringVar(&name, "name", "", "use -name=Ivan")
+	fs.StringVar(&phone, "phone", "", "use -phone=+79990000000")
+
+	if err := fs.Parse(args); err != nil {
+		return util.ErrArgumentsInvalid.Wrap(err)
+	}
+
+	user, err := c.userService.Create(ctx, id, name, phone)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(i18n.T(i18n.MsgUserCreated, user.ID))
+	return nil
+}
+
+func (c *CLI) listLocations(ctx context.Context) error {
+	cells, err := c.locationService.ListCells(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.locationService.PrintCells(cells)
+
+	return nil
+}
+
+func (c *CLI) listNotifications(ctx context.Context, args []string) error {
+	var id string
+	fs := flag.NewFlagSet(listNotifications, flag.ContinueOnError)
+	fs.StringVar(&id, "id", "", "use -id=12345")
+
+	if err := fs.Parse(args); err != nil {
+		return util.ErrArgumentsInvalid.Wrap(err)
+	}
+	if len(id) == 0 {
+		return util.ErrOrderIdNotProvided
+	}
+
+	notifications, err := c.notificationService.List(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	c.notificationService.PrintList(notifications)
+
+	return nil
+}
+
+func (c *CLI) login(ctx context.Context, args []string) error {
+	var loginStr, password string
+	fs := flag.NewFlagSet(login, flag.ContinueOnError)
+	fs.StringVar(&loginStr, "u", "", "use -u=admin")
+	fs.StringVar(&password, "p", "", "use -p=secret")
+
+	if err := fs.Parse(args); err != nil {
+		return util.ErrArgumentsInvalid.Wrap(err)
+	}
+
+	operator, err := c.operatorService.Login(ctx, loginStr, password)
+	if err != nil {
+		return err
+	}
+	c.operator.Store(&operator)
+
+	fmt.Println(i18n.T(i18n.MsgLoggedIn, operator.Login, operator.Role))
+	return nil
+}
+
+func (c *CLI) logout() {
+	if operator := c.operator.Swap(nil); operator != nil {
+		fmt.Println(i18n.T(i18n.MsgLoggedOut, operator.Login))
+	}
+}
+
+func (c *CLI) addOperator(ctx context.Context, args []string) error {
+	var loginStr, password, role string
+	fs := flag.NewFlagSet(addOperator, flag.ContinueOnError)
+	fs.StringVar(&loginStr, "u", "", "use -u=ivan")
+	fs.StringVar(&password, "p", "", "use -p=secret")
+	fs.StringVar(&role, "role", string(models.RoleClerk), "use -role=clerk")
+
+	if err := fs.Parse(args); err != nil {
+		return util.ErrArgumentsInvalid.Wrap(err)
+	}
+
+	apiKey, err := c.operatorService.Create(ctx, loginStr, password, models.Role(role))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(i18n.T(i18n.MsgOperatorCreated, loginStr, apiKey))
+	return nil
+}
+
+// printError shows the error to the operator along with the correlation id to look the command up in the logs,
+// the cause is only logged
+func (c *CLI) printError(ctx context.Context, err error) {
+	id := logger.CorrelationID(ctx)
+	slog.WarnContext(ctx, "command failed", "err", err)
+	resp := newErrorResponse(err)
+	fmt.Printf("%s [%s %s]\n", resp.message(), resp.Code, id)
+}
+
+// sessionContext carries the logged in operator to the services, so they can authorize and audit the command
+func (c *CLI) sessionContext(ctx context.Context) context.Context {
+	if operator := c.operator.Load(); operator != nil {
+		ctx = auth.WithOperator(ctx, *operator)
+	}
+	return ctx
+}
+
 func (c *CLI) help() {
-	fmt.Println("Command list:")
-	fmt.Printf("%-15s | %-30s | %s\n", "Command", "Description", "Example")
+	fmt.Println(i18n.T(i18n.HelpTitle))
+	fmt.Printf("%-15s | %-30s | %s\n", i18n.T(i18n.HelpColumnCommand), i18n.T(i18n.HelpColumnDescription), i18n.T(i18n.HelpColumnExample))
 	fmt.Println("---------------------------------------------------------------------------------------------------")
 	for _, cmd := range c.commandList {
-		parts := strings.SplitN(cmd.description, ":", 2)
-		description := ""
-		example := ""
-		if len(parts) > 0 {
-			description = strings.TrimSpace(parts[0])
-		}
-		if len(parts) > 1 {
-			example = strings.TrimSpace(parts[1])
-		}
-		fmt.Printf("%-15s | %-30s | %s\n", cmd.name, description, example)
+		fmt.Printf("%-15s | %-30s | %s\n", cmd.name, i18n.T(cmd.description), cmd.example)
 	}
 }
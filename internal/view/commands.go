@@ -1,18 +1,119 @@
 package view
 
+import (
+	"homework/internal/i18n"
+	"homework/internal/models"
+	"log/slog"
+	"strings"
+)
+
 const (
 	help                 = "help"
 	acceptOrder          = "accept"
 	returnOrderToCourier = "return_courier"
+	courierReturn        = "courier_return"
+	addCourier           = "add_courier"
 	issueOrders          = "issue"
+	holdOrders           = "hold"
+	releaseHolds         = "release"
+	listHolds            = "holds"
 	acceptReturn         = "accept_return"
 	listReturns          = "list_returns"
+	listRefunds          = "refunds"
 	listOrders           = "list_orders"
+	searchOrders         = "search"
+	listLocations        = "locations"
+	showUser             = "user"
+	addUser              = "add_user"
 	setMaxGoroutines     = "set_mg"
+	status               = "status"
+	listJobs             = "jobs"
+	healthCheck          = "health"
+	listNotifications    = "notifications"
+	login                = "login"
+	logout               = "logout"
+	addOperator          = "add_operator"
 	exit                 = "exit"
 )
 
+// commandRoles is the minimal role required to run a command from the CLI or HTTP,
+// commands missing here are available without login
+var commandRoles = map[string]models.Role{
+	acceptOrder:          models.RoleClerk,
+	issueOrders:          models.RoleClerk,
+	holdOrders:           models.RoleClerk,
+	releaseHolds:         models.RoleClerk,
+	listHolds:            models.RoleClerk,
+	acceptReturn:         models.RoleClerk,
+	listReturns:          models.RoleClerk,
+	listRefunds:          models.RoleClerk,
+	listOrders:           models.RoleClerk,
+	searchOrders:         models.RoleClerk,
+	listLocations:        models.RoleClerk,
+	showUser:             models.RoleClerk,
+	addUser:              models.RoleClerk,
+	returnOrderToCourier: models.RoleSenior,
+	courierReturn:        models.RoleSenior,
+	addCourier:           models.RoleSenior,
+	setMaxGoroutines:     models.RoleSenior,
+	status:               models.RoleClerk,
+	listJobs:             models.RoleClerk,
+	healthCheck:          models.RoleClerk,
+	listNotifications:    models.RoleClerk,
+	addOperator:          models.RoleAdmin,
+}
+
+// idempotencyKeyUsage describes the -key flag of the mutating commands, a retry with the same key
+// returns the outcome of the first run instead of running the command again
+const idempotencyKeyUsage = "use -key=accept-12345 to make retries safe"
+
 type command struct {
 	name        string
-	description string
+	description i18n.Key
+	example     string
+}
+
+// secretFlags are the password flags of the commands that take one, their values must not reach the logs or the jobs list
+var secretFlags = map[string]string{
+	login:       "p",
+	addOperator: "p",
+}
+
+// redact masks the password in both forms the flag package accepts, -p=secret and -p secret, with one or two dashes.
+// The flags of login and add_operator all take a value, so a flag without = always takes the next argument.
+// A password typed after a non-flag argument is masked too, although the flag package would not parse it
+func redact(cmd string) string {
+	args := strings.Split(cmd, " ")
+	secret, ok := secretFlags[args[0]]
+	if !ok {
+		return cmd
+	}
+	for i := 1; i < len(args); i++ {
+		flag, isFlag := strings.CutPrefix(args[i], "-")
+		if !isFlag || flag == "" || flag == "-" {
+			continue
+		}
+		name, _, inline := strings.Cut(strings.TrimPrefix(flag, "-"), "=")
+		switch {
+		case inline:
+			if name == secret {
+				prefix, _, _ := strings.Cut(args[i], "=")
+				args[i] = prefix + "=***"
+			}
+		case i+1 < len(args):
+			// The value is the next argument, it is skipped over with its flag
+			if name == secret {
+				args[i+1] = "***"
+			}
+			i++
+		}
+	}
+	return strings.Join(args, " ")
+}
+
+// commandLine is a command as typed, it redacts itself when logged so no log line gets a password
+type commandLine string
+
+func (c commandLine) LogValue() slog.Value {
+	return slog.StringValue(redact(string(c)))
 }
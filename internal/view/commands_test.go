@@ -0,0 +1,48 @@
+package view
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestCommandLineLogValue(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(slog.NewTextHandler(&buf, nil))
+
+	log.Info("command received", "command", commandLine("login -u=admin -p=hunter2"))
+	if strings.Contains(buf.String(), "hunter2") {
+		t.Errorf("password logged: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "-u=admin") {
+		t.Errorf("login dropped from the log: %s", buf.String())
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		{cmd: "login -u=admin -p=secret", want: "login -u=admin -p=***"},
+		{cmd: "login -u=admin --p=secret", want: "login -u=admin --p=***"},
+		{cmd: "login -u admin -p secret", want: "login -u admin -p ***"},
+		{cmd: "login --p secret -u admin", want: "login --p *** -u admin"},
+		{cmd: "add_operator -u=ivan -p secret -role=admin", want: "add_operator -u=ivan -p *** -role=admin"},
+		{cmd: "add_operator -u ivan --p=secret", want: "add_operator -u ivan --p=***"},
+		// A login that looks like a flag is a value, not the password flag
+		{cmd: "login -u -p -p=secret", want: "login -u -p -p=***"},
+		{cmd: "login -p", want: "login -p"},
+		{cmd: "login -p=", want: "login -p=***"},
+		{cmd: "search -p=10", want: "search -p=10"},
+		{cmd: "accept -id=1 -p secret", want: "accept -id=1 -p secret"},
+		{cmd: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := redact(tt.cmd); got != tt.want {
+			t.Errorf("redact(%q) = %q, want %q", tt.cmd, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package view
+
+import (
+	"homework/internal/i18n"
+	"homework/internal/util"
+	"net/http"
+)
+
+// errGoroutinesInvalid is only used by set_mg, it's not a domain error
+var errGoroutinesInvalid = util.NewError(util.CodeInvalidArgument, "n", "error - number of goroutines must be > 0")
+
+// errorResponse is how both the CLI and the HTTP API present an error, the cause never reaches the user
+type errorResponse struct {
+	Error string    `json:"error"`
+	Code  util.Code `json:"code"`
+	Field string    `json:"field,omitempty"`
+}
+
+var codeStatus = map[util.Code]int{
+	util.CodeInvalidArgument:    http.StatusBadRequest,
+	util.CodeFailedPrecondition: http.StatusBadRequest,
+	util.CodeNotFound:           http.StatusNotFound,
+	util.CodeConflict:           http.StatusConflict,
+	util.CodeUnauthenticated:    http.StatusUnauthorized,
+	util.CodePermissionDenied:   http.StatusForbidden,
+	util.CodeUnavailable:        http.StatusServiceUnavailable,
+	util.CodeInternal:           http.StatusInternalServerError,
+}
+
+// errorMessages translates the sentinels, an error missing here keeps its English message
+var errorMessages = map[*util.Error]i18n.Key{
+	util.ErrPriceNotProvided:      i18n.ErrPriceNotProvided,
+	util.ErrOrderPriceInvalid:     i18n.ErrOrderPriceInvalid,
+	util.ErrWeightNotProvided:     i18n.ErrWeightNotProvided,
+	util.ErrWeightExceeds:         i18n.ErrWeightExceeds,
+	util.ErrWeightInvalid:         i18n.ErrWeightInvalid,
+	util.ErrPackageTypeInvalid:    i18n.ErrPackageTypeInvalid,
+	util.ErrDateInvalid:           i18n.ErrDateInvalid,
+	util.ErrOrderExists:           i18n.ErrOrderExists,
+	util.ErrOrderNotFound:         i18n.ErrOrderNotFound,
+	util.ErrOrderIdInvalid:        i18n.ErrOrderIdInvalid,
+	util.ErrOrderExpired:          i18n.ErrOrderExpired,
+	util.ErrOrderNotIssued:        i18n.ErrOrderNotIssued,
+	util.ErrOrderIssued:           i18n.ErrOrderIssued,
+	util.ErrOrderIdNotProvided:    i18n.ErrOrderIdNotProvided,
+	util.ErrUserIdNotProvided:     i18n.ErrUserIdNotProvided,
+	util.ErrOrdersUserDiffers:     i18n.ErrOrdersUserDiffers,
+	util.ErrOrderReturned:         i18n.ErrOrderReturned,
+	util.ErrOrderDoesNotBelong:    i18n.ErrOrderDoesNotBelong,
+	util.ErrReturnPeriodExpired:   i18n.ErrReturnPeriodExpired,
+	util.ErrNoFreeCell:            i18n.ErrNoFreeCell,
+	util.ErrListParamInvalid:      i18n.ErrListParamInvalid,
+	util.ErrArgumentsInvalid:      i18n.ErrArgumentsInvalid,
+	errGoroutinesInvalid:          i18n.ErrGoroutinesInvalid,
+	util.ErrNotLoggedIn:           i18n.ErrNotLoggedIn,
+	util.ErrPermissionDenied:      i18n.ErrPermissionDenied,
+	util.ErrInvalidCredentials:    i18n.ErrInvalidCredentials,
+	util.ErrOperatorExists:        i18n.ErrOperatorExists,
+	util.ErrOperatorNotFound:      i18n.ErrOperatorNotFound,
+	util.ErrRoleInvalid:           i18n.ErrRoleInvalid,
+	util.ErrLoginNotProvided:      i18n.ErrLoginNotProvided,
+	util.ErrIdempotencyKeyReused:  i18n.ErrIdempotencyKeyReused,
+	util.ErrIdempotencyInProgress: i18n.ErrIdempotencyInProgress,
+	util.ErrUserNotFound:          i18n.ErrUserNotFound,
+	util.ErrUserExists:            i18n.ErrUserExists,
+	util.ErrCourierNotFound:       i18n.ErrCourierNotFound,
+	util.ErrCourierExists:         i18n.ErrCourierExists,
+	util.ErrCourierIdNotProvided:  i18n.ErrCourierIdNotProvided,
+	util.ErrCourierIdInvalid:      i18n.ErrCourierIdInvalid,
+	util.ErrManifestEmpty:         i18n.ErrManifestEmpty,
+	util.ErrManifestStale:         i18n.ErrManifestStale,
+	util.ErrManifestKeyNotSet:     i18n.ErrManifestKeyNotSet,
+	util.ErrOrderHeld:             i18n.ErrOrderHeld,
+	util.ErrPaymentMethodInvalid:  i18n.ErrPaymentMethodInvalid,
+	util.ErrPaidInvalid:           i18n.ErrPaidInvalid,
+	util.ErrPrepaidInvalid:        i18n.ErrPrepaidInvalid,
+	util.ErrPrepaidExceedsTotal:   i18n.ErrPrepaidExceedsTotal,
+	util.ErrPaymentInsufficient:   i18n.ErrPaymentInsufficient,
+	util.ErrCardAmountMismatch:    i18n.ErrCardAmountMismatch,
+	util.ErrPaymentNotFound:       i18n.ErrPaymentNotFound,
+	util.ErrStatusInvalid:         i18n.ErrStatusInvalid,
+	util.ErrSortColumnInvalid:     i18n.ErrSortColumnInvalid,
+	util.ErrRangeInvalid:          i18n.ErrRangeInvalid,
+	util.ErrStorageUnavailable:    i18n.ErrStorageUnavailable,
+	util.ErrCanceled:              i18n.ErrCanceled,
+	util.ErrInternal:              i18n.ErrInternal,
+}
+
+func newErrorResponse(err error) errorResponse {
+	e := util.AsError(err)
+	message := e.Message
+	if key, ok := errorMessages[e.Kind()]; ok {
+		message = i18n.T(key)
+	}
+	return errorResponse{
+		Error: message,
+		Code:  e.Code,
+		Field: e.Field,
+	}
+}
+
+func (r errorResponse) status() int {
+	if status, ok := codeStatus[r.Code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// message is the error with the field it refers to, as printed by the CLI
+func (r errorResponse) message() string {
+	if len(r.Field) == 0 {
+		return r.Error
+	}
+	return r.Error + " (" + r.Field + ")"
+}
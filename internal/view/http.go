@@ -0,0 +1,487 @@
+package view
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"homework/internal/auth"
+	"homework/internal/logger"
+	"homework/internal/metrics"
+	"homework/internal/models"
+	"homework/internal/service"
+	"homework/internal/util"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	// apiKeyHeader carries the operator's API key, see add_operator
+	apiKeyHeader = "X-API-Key"
+	// correlationHeader lets the client pass its own id, otherwise a new one is returned in the response
+	correlationHeader = "X-Correlation-ID"
+	// idempotencyHeader makes a retried mutating request return the outcome of the first one
+	idempotencyHeader = "Idempotency-Key"
+	// replayedHeader marks a response replayed from an idempotency key
+	replayedHeader = "Idempotent-Replayed"
+)
+
+// Server exposes the CLI commands over HTTP for operators working through other tools
+type Server struct {
+	server              *http.Server
+	validationService   service.ValidationService
+	orderService        service.OrderService
+	locationService     service.LocationService
+	operatorService     service.OperatorService
+	notificationService service.NotificationService
+	idempotencyService  service.IdempotencyService
+	userService         service.UserService
+	courierService      service.CourierService
+	holdService         service.HoldService
+	paymentService      service.PaymentService
+	refundService       service.RefundService
+}
+
+type handlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+type acceptRequest struct {
+	ID           string      `json:"id"`
+	UserID       string      `json:"user_id"`
+	StorageUntil string      `json:"storage_until"`
+	Price        json.Number `json:"price"`
+	Weight       json.Number `json:"weight"`
+	PackageType  string      `json:"package_type"`
+	CourierID    string      `json:"courier_id"`
+}
+
+// issueRequest is also the body of the hold requests, they only read the ids
+type issueRequest struct {
+	IDs     []string    `json:"ids"`
+	Method  string      `json:"method"`
+	Paid    json.Number `json:"paid"`
+	Prepaid json.Number `json:"prepaid"`
+}
+
+type userRequest struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Phone string `json:"phone"`
+}
+
+type returnRequest struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+}
+
+func NewServer(addr string, os service.OrderService, vs service.ValidationService, ls service.LocationService, ops service.OperatorService, ns service.NotificationService, is service.IdempotencyService, us service.UserService, cs service.CourierService, hs service.HoldService, ps service.PaymentService, rs service.RefundService) *Server {
+	s := &Server{
+		orderService:        os,
+		validationService:   vs,
+		locationService:     ls,
+		operatorService:     ops,
+		notificationService: ns,
+		idempotencyService:  is,
+		userService:         us,
+		courierService:      cs,
+		holdService:         hs,
+		paymentService:      ps,
+		refundService:       rs,
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /orders", s.authorized(acceptOrder, s.acceptOrder))
+	mux.Handle("POST /orders/issue", s.authorized(issueOrders, s.issueOrders))
+	mux.Handle("DELETE /orders/{id}", s.authorized(returnOrderToCourier, s.returnOrderToCourier))
+	mux.Handle("POST /holds", s.authorized(holdOrders, s.holdOrders))
+	mux.Handle("POST /holds/release", s.authorized(releaseHolds, s.releaseHolds))
+	mux.Handle("GET /holds", s.authorized(listHolds, s.listHolds))
+	mux.Handle("POST /couriers", s.authorized(addCourier, s.addCourier))
+	mux.Handle("POST /couriers/{id}/return", s.authorized(courierReturn, s.courierReturn))
+	mux.Handle("POST /returns", s.authorized(acceptReturn, s.acceptReturn))
+	mux.Handle("GET /returns", s.authorized(listReturns, s.listReturns))
+	mux.Handle("GET /refunds", s.authorized(listRefunds, s.listRefunds))
+	mux.Handle("GET /users/{id}/orders", s.authorized(listOrders, s.listOrders))
+	mux.Handle("GET /users/{id}", s.authorized(showUser, s.showUser))
+	mux.Handle("POST /users", s.authorized(addUser, s.addUser))
+	mux.Handle("GET /orders", s.authorized(searchOrders, s.searchOrders))
+	mux.Handle("GET /locations", s.authorized(listLocations, s.listLocations))
+	mux.Handle("GET /orders/{id}/notifications", s.authorized(listNotifications, s.listNotifications))
+
+	s.server = &http.Server{
+		Addr:    addr,
+		Handler: withCorrelationID(mux),
+	}
+	return s
+}
+
+func (s *Server) Run() error {
+	slog.Info("HTTP server listening", "addr", s.server.Addr)
+	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+// withCorrelationID tags the request so that its records can be found in the logs like a CLI command
+func withCorrelationID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(correlationHeader)
+		if len(id) == 0 {
+			id = logger.NewCorrelationID()
+		}
+		w.Header().Set(correlationHeader, id)
+
+		ctx := logger.WithCorrelationID(r.Context(), id)
+		slog.InfoContext(ctx, "request received", "method", r.Method, "path", r.URL.Path)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authorized resolves the operator by API key and checks the role the command requires
+func (s *Server) authorized(commandName string, next handlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		operator, err := s.operatorService.Authenticate(r.Context(), r.Header.Get(apiKeyHeader))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		ctx := auth.WithOperator(r.Context(), operator)
+		if err = auth.Authorize(ctx, commandRoles[commandName]); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		start := time.Now()
+		status := metrics.StatusOK
+		if err = next(w, r.WithContext(ctx)); err != nil {
+			status = metrics.StatusError
+			slog.WarnContext(ctx, "request failed", "command", commandName, "err", err)
+			writeError(w, err)
+		}
+		metrics.CommandsTotal.Inc(commandName, status)
+		metrics.CommandDuration.Observe(time.Since(start).Seconds(), commandName)
+	})
+}
+
+func (s *Server) acceptOrder(w http.ResponseWriter, r *http.Request) error {
+	var req acceptRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return util.ErrArgumentsInvalid.Wrap(err)
+	}
+
+	fingerprint := service.Fingerprint(req.ID, req.UserID, req.StorageUntil, req.Price.String(), req.Weight.String(), req.PackageType, req.CourierID)
+	order, replayed, err := service.Idempotent(r.Context(), s.idempotencyService, r.Header.Get(idempotencyHeader), acceptOrder, fingerprint, func(ctx context.Context) (*models.Order, error) {
+		order, err := s.validationService.ValidateAccept(ctx, req.ID, req.UserID, req.StorageUntil, req.Price.String(), req.Weight.String(), req.PackageType, req.CourierID)
+		if err != nil {
+			return nil, err
+		}
+		// The progress dots are for the operator at the terminal, not for a client of the API
+		return order, s.orderService.Accept(ctx, order, req.PackageType, io.Discard)
+	})
+	if err != nil {
+		return err
+	}
+
+	markReplayed(w, replayed)
+	return writeJSON(w, http.StatusCreated, order)
+}
+
+func (s *Server) issueOrders(w http.ResponseWriter, r *http.Request) error {
+	var req issueRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return util.ErrArgumentsInvalid.Wrap(err)
+	}
+
+	fingerprint := service.Fingerprint(append([]string{req.Method, req.Paid.String(), req.Prepaid.String()}, req.IDs...)...)
+	receipt, replayed, err := service.Idempotent(r.Context(), s.idempotencyService, r.Header.Get(idempotencyHeader), issueOrders, fingerprint, func(ctx context.Context) (models.Receipt, error) {
+		tender, err := s.validationService.ValidateTender(req.Method, req.Paid.String(), req.Prepaid.String())
+		if err != nil {
+			return models.Receipt{}, err
+		}
+		ordersToIssue, err := s.validationService.ValidateIssue(ctx, req.IDs)
+		if err != nil {
+			return models.Receipt{}, err
+		}
+		payment, err := s.paymentService.Charge(*ordersToIssue, tender)
+		if err != nil {
+			return models.Receipt{}, err
+		}
+		if err = s.orderService.Issue(ctx, ordersToIssue, &payment); err != nil {
+			return models.Receipt{}, err
+		}
+		return models.Receipt{Payment: payment, Orders: *ordersToIssue}, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	markReplayed(w, replayed)
+	return writeJSON(w, http.StatusOK, receipt)
+}
+
+func (s *Server) acceptReturn(w http.ResponseWriter, r *http.Request) error {
+	var req returnRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return util.ErrArgumentsInvalid.Wrap(err)
+	}
+
+	receipt, replayed, err := service.Idempotent(r.Context(), s.idempotencyService, r.Header.Get(idempotencyHeader), acceptReturn, service.Fingerprint(req.ID, req.UserID), func(ctx context.Context) (models.ReturnReceipt, error) {
+		orderToReturn, err := s.validationService.ValidateAcceptReturn(ctx, req.ID, req.UserID)
+		if err != nil {
+			return models.ReturnReceipt{}, err
+		}
+		refund, err := s.refundService.Calculate(ctx, *orderToReturn)
+		if err != nil {
+			return models.ReturnReceipt{}, err
+		}
+		if err = s.orderService.Return(ctx, orderToReturn, &refund); err != nil {
+			return models.ReturnReceipt{}, err
+		}
+		return models.ReturnReceipt{Order: *orderToReturn, Refund: refund}, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	markReplayed(w, replayed)
+	return writeJSON(w, http.StatusOK, receipt)
+}
+
+func (s *Server) returnOrderToCourier(w http.ResponseWriter, r *http.Request) error {
+	id := r.PathValue("id")
+	_, replayed, err := service.Idempotent(r.Context(), s.idempotencyService, r.Header.Get(idempotencyHeader), returnOrderToCourier, service.Fingerprint(id), func(ctx context.Context) (struct{}, error) {
+		if err := s.validationService.ValidateReturnToCourier(ctx, id); err != nil {
+			return struct{}{}, err
+		}
+		return struct{}{}, s.orderService.ReturnToCourier(ctx, id)
+	})
+	if err != nil {
+		return err
+	}
+
+	markReplayed(w, replayed)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (s *Server) holdOrders(w http.ResponseWriter, r *http.Request) error {
+	var req issueRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return util.ErrArgumentsInvalid.Wrap(err)
+	}
+
+	if err := s.validationService.ValidateHold(r.Context(), req.IDs); err != nil {
+		return err
+	}
+	holds, err := s.holdService.Hold(r.Context(), req.IDs)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusCreated, holds)
+}
+
+func (s *Server) releaseHolds(w http.ResponseWriter, r *http.Request) error {
+	var req issueRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return util.ErrArgumentsInvalid.Wrap(err)
+	}
+
+	if err := s.holdService.Release(r.Context(), req.IDs); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (s *Server) listHolds(w http.ResponseWriter, r *http.Request) error {
+	holds, err := s.holdService.List(r.Context())
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, holds)
+}
+
+// courierReturn answers with the manifest, the signed file stays on the server in MANIFEST_DIR
+func (s *Server) courierReturn(w http.ResponseWriter, r *http.Request) error {
+	courierId := r.PathValue("id")
+	manifest, replayed, err := service.Idempotent(r.Context(), s.idempotencyService, r.Header.Get(idempotencyHeader), courierReturn, service.Fingerprint(courierId), func(ctx context.Context) (models.Manifest, error) {
+		orders, err := s.validationService.ValidateCourierReturn(ctx, courierId)
+		if err != nil {
+			return models.Manifest{}, err
+		}
+		return s.courierService.Return(ctx, courierId, orders)
+	})
+	if err != nil {
+		return err
+	}
+
+	markReplayed(w, replayed)
+	return writeJSON(w, http.StatusOK, manifest)
+}
+
+func (s *Server) addCourier(w http.ResponseWriter, r *http.Request) error {
+	var req userRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return util.ErrArgumentsInvalid.Wrap(err)
+	}
+
+	courier, err := s.courierService.Create(r.Context(), req.ID, req.Name, req.Phone)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusCreated, courier)
+}
+
+func (s *Server) listReturns(w http.ResponseWriter, r *http.Request) error {
+	offset, limit, err := s.validationService.ValidateList(r.URL.Query().Get("ofs"), r.URL.Query().Get("lmt"))
+	if err != nil {
+		return err
+	}
+
+	orders, err := s.orderService.ListReturns(r.Context(), offset, limit)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, orders)
+}
+
+func (s *Server) listRefunds(w http.ResponseWriter, r *http.Request) error {
+	offset, limit, err := s.validationService.ValidateList(r.URL.Query().Get("ofs"), r.URL.Query().Get("lmt"))
+	if err != nil {
+		return err
+	}
+
+	refunds, err := s.refundService.List(r.Context(), r.URL.Query().Get("user_id"), offset, limit)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, refunds)
+}
+
+func (s *Server) listOrders(w http.ResponseWriter, r *http.Request) error {
+	offset, limit, err := s.validationService.ValidateList(r.URL.Query().Get("ofs"), r.URL.Query().Get("lmt"))
+	if err != nil {
+		return err
+	}
+
+	orders, err := s.orderService.ListOrders(r.Context(), r.PathValue("id"), offset, limit)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, orders)
+}
+
+// searchOrders takes the parameters of the search command from the query string, ?status=stored&sort=weight&desc=true
+func (s *Server) searchOrders(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+	params := service.SearchParams{
+		Status:      query.Get("status"),
+		IDPrefix:    query.Get("id"),
+		UserID:      query.Get("u_id"),
+		PackageType: query.Get("p"),
+		StorageFrom: query.Get("until_from"),
+		StorageTo:   query.Get("until_to"),
+		IssuedFrom:  query.Get("issued_from"),
+		IssuedTo:    query.Get("issued_to"),
+		PriceMin:    query.Get("price_min"),
+		PriceMax:    query.Get("price_max"),
+		WeightMin:   query.Get("w_min"),
+		WeightMax:   query.Get("w_max"),
+		SortBy:      query.Get("sort"),
+		Desc:        query.Get("desc") == "true",
+		Offset:      valueOr(query.Get("ofs"), "0"),
+		Limit:       valueOr(query.Get("lmt"), "10"),
+	}
+
+	filter, err := s.validationService.ValidateSearch(params)
+	if err != nil {
+		return err
+	}
+
+	orders, err := s.orderService.Search(r.Context(), filter)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, orders)
+}
+
+func valueOr(value, fallback string) string {
+	if len(value) == 0 {
+		return fallback
+	}
+	return value
+}
+
+func (s *Server) showUser(w http.ResponseWriter, r *http.Request) error {
+	summary, err := s.userService.Summary(r.Context(), r.PathValue("id"))
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, summary)
+}
+
+func (s *Server) addUser(w http.ResponseWriter, r *http.Request) error {
+	var req userRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return util.ErrArgumentsInvalid.Wrap(err)
+	}
+
+	user, err := s.userService.Create(r.Context(), req.ID, req.Name, req.Phone)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusCreated, user)
+}
+
+func (s *Server) listLocations(w http.ResponseWriter, r *http.Request) error {
+	cells, err := s.locationService.ListCells(r.Context())
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, cells)
+}
+
+func (s *Server) listNotifications(w http.ResponseWriter, r *http.Request) error {
+	notifications, err := s.notificationService.List(r.Context(), r.PathValue("id"))
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, notifications)
+}
+
+func markReplayed(w http.ResponseWriter, replayed bool) {
+	if replayed {
+		w.Header().Set(replayedHeader, "true")
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	resp := newErrorResponse(err)
+	if err = writeJSON(w, resp.status(), resp); err != nil {
+		slog.Error("writing error response", "err", err)
+	}
+}
@@ -0,0 +1,101 @@
+package view
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type jobState string
+
+const (
+	jobQueued  jobState = "queued"
+	jobRunning jobState = "running"
+)
+
+// job is a command handed to a worker, it's listed by the jobs command until it finishes
+type job struct {
+	ID            uint64
+	Command       string
+	Operator      string
+	CorrelationID string
+	State         jobState
+	QueuedAt      time.Time
+	StartedAt     time.Time
+}
+
+type jobRegistry struct {
+	mu      sync.Mutex
+	jobs    map[uint64]*job
+	aborted []job
+}
+
+func newJobRegistry() *jobRegistry {
+	return &jobRegistry{
+		jobs: make(map[uint64]*job),
+	}
+}
+
+func (r *jobRegistry) add(j *job) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	j.State = jobQueued
+	j.QueuedAt = time.Now()
+	r.jobs[j.ID] = j
+}
+
+func (r *jobRegistry) start(id uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if j, ok := r.jobs[id]; ok {
+		j.State = jobRunning
+		j.StartedAt = time.Now()
+	}
+}
+
+func (r *jobRegistry) remove(id uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.jobs, id)
+}
+
+// abort removes the job and keeps it for the shutdown report
+func (r *jobRegistry) abort(id uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if j, ok := r.jobs[id]; ok {
+		r.aborted = append(r.aborted, *j)
+		delete(r.jobs, id)
+	}
+}
+
+func (r *jobRegistry) listAborted() []job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	aborted := make([]job, len(r.aborted))
+	copy(aborted, r.aborted)
+	sort.Slice(aborted, func(i, k int) bool {
+		return aborted[i].ID < aborted[k].ID
+	})
+	return aborted
+}
+
+// list returns copies of the jobs in the order they were received
+func (r *jobRegistry) list() []job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	jobs := make([]job, 0, len(r.jobs))
+	for _, j := range r.jobs {
+		jobs = append(jobs, *j)
+	}
+	sort.Slice(jobs, func(i, k int) bool {
+		return jobs[i].ID < jobs[k].ID
+	})
+	return jobs
+}
@@ -0,0 +1,41 @@
+package view
+
+import (
+	"context"
+	"errors"
+	"homework/internal/health"
+	"homework/internal/metrics"
+	"log/slog"
+	"net/http"
+)
+
+// OpsServer serves the metrics and the probes, it's kept apart from the API so it needs no API key
+type OpsServer struct {
+	server *http.Server
+}
+
+func NewOpsServer(addr string, registry *metrics.Registry, checker *health.Checker) *OpsServer {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", registry.Handler())
+	mux.Handle("GET /healthz", checker.LiveHandler())
+	mux.Handle("GET /readyz", checker.ReadyHandler())
+
+	return &OpsServer{
+		server: &http.Server{
+			Addr:    addr,
+			Handler: mux,
+		},
+	}
+}
+
+func (s *OpsServer) Run() error {
+	slog.Info("ops server listening", "addr", s.server.Addr)
+	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *OpsServer) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
@@ -0,0 +1,79 @@
+package view
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+)
+
+// shutdown stops the CLI in stages: new commands are refused, queued ones are dropped
+// and running ones get a grace period to finish before their context is canceled
+type shutdown struct {
+	stop chan struct{}
+	once sync.Once
+
+	// queueCtx is canceled first, workers still waiting for the limiter give up
+	queueCtx    context.Context
+	cancelQueue context.CancelFunc
+	// runCtx is the parent of every command context, it's canceled when the grace period is over
+	runCtx    context.Context
+	cancelRun context.CancelFunc
+}
+
+func newShutdown() *shutdown {
+	s := &shutdown{
+		stop: make(chan struct{}),
+	}
+	s.queueCtx, s.cancelQueue = context.WithCancel(context.Background())
+	s.runCtx, s.cancelRun = context.WithCancel(context.Background())
+	return s
+}
+
+// request starts the shutdown, it's safe to call more than once
+func (s *shutdown) request() {
+	s.once.Do(func() {
+		close(s.stop)
+	})
+}
+
+func (s *shutdown) stopping() bool {
+	select {
+	case <-s.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// listen starts the shutdown on the first signal and calls force on the second one
+func (s *shutdown) listen(signals <-chan os.Signal, onFirst, onForce func()) {
+	<-signals
+	onFirst()
+	s.request()
+
+	<-signals
+	onForce()
+}
+
+// drain waits for the workers for at most grace, then cancels the running commands and waits for them to return,
+// it reports whether the commands had to be canceled
+func (s *shutdown) drain(wg *sync.WaitGroup, grace time.Duration) bool {
+	s.cancelQueue()
+
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		s.cancelRun()
+		return false
+	case <-time.After(grace):
+		s.cancelRun()
+		<-finished
+		return true
+	}
+}
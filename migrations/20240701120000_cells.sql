@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS cells (
+    id VARCHAR(255) PRIMARY KEY,
+    shelf VARCHAR(255) NOT NULL,
+    package_type VARCHAR(255) NOT NULL,
+    max_weight FLOAT NOT NULL,
+    used_weight FLOAT NOT NULL DEFAULT 0,
+    max_orders INT NOT NULL,
+    orders_count INT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX cells_package_type_asc ON cells (package_type, shelf, id ASC);
+
+ALTER TABLE orders ADD COLUMN cell_id VARCHAR(255) REFERENCES cells (id);
+
+-- Shelf A keeps film-wrapped orders, B packets, C boxes
+INSERT INTO cells (id, shelf, package_type, max_weight, max_orders)
+SELECT 'A-' || lpad(n::text, 2, '0'), 'A', 'film', 50, 10 FROM generate_series(1, 20) AS n;
+INSERT INTO cells (id, shelf, package_type, max_weight, max_orders)
+SELECT 'B-' || lpad(n::text, 2, '0'), 'B', 'packet', 30, 3 FROM generate_series(1, 20) AS n;
+INSERT INTO cells (id, shelf, package_type, max_weight, max_orders)
+SELECT 'C-' || lpad(n::text, 2, '0'), 'C', 'box', 60, 2 FROM generate_series(1, 20) AS n;
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE orders DROP COLUMN cell_id;
+DROP INDEX cells_package_type_asc;
+DROP TABLE IF EXISTS cells;
+-- +goose StatementEnd
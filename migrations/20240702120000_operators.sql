@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS operators (
+    login VARCHAR(255) PRIMARY KEY,
+    role VARCHAR(255) NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    api_key_hash VARCHAR(255) NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS order_audit (
+    id BIGSERIAL PRIMARY KEY,
+    order_id VARCHAR(255) NOT NULL,
+    action VARCHAR(255) NOT NULL,
+    operator VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX order_audit_order_id_asc ON order_audit (order_id, created_at ASC);
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX order_audit_order_id_asc;
+DROP TABLE IF EXISTS order_audit;
+DROP TABLE IF EXISTS operators;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS notifications (
+    order_id VARCHAR(255) NOT NULL,
+    user_id VARCHAR(255) NOT NULL,
+    event VARCHAR(255) NOT NULL,
+    channel VARCHAR(255) NOT NULL,
+    message TEXT NOT NULL,
+    status VARCHAR(255) NOT NULL,
+    attempts INT NOT NULL DEFAULT 1,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    sent_at TIMESTAMPTZ,
+    PRIMARY KEY (order_id, event, channel)
+);
+
+CREATE INDEX notifications_status_asc ON notifications (status, created_at ASC);
+CREATE INDEX orders_storage_until_asc ON orders (storage_until ASC) WHERE issued = FALSE AND returned = FALSE;
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX orders_storage_until_asc;
+DROP INDEX notifications_status_asc;
+DROP TABLE IF EXISTS notifications;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    operator VARCHAR(255) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    command VARCHAR(255) NOT NULL,
+    fingerprint VARCHAR(64) NOT NULL,
+    status VARCHAR(255) NOT NULL,
+    response JSONB,
+    error_code VARCHAR(255) NOT NULL DEFAULT '',
+    error_field VARCHAR(255) NOT NULL DEFAULT '',
+    error_message TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (operator, key)
+);
+
+CREATE INDEX idempotency_keys_expires_at_asc ON idempotency_keys (expires_at ASC);
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idempotency_keys_expires_at_asc;
+DROP TABLE IF EXISTS idempotency_keys;
+-- +goose StatementEnd
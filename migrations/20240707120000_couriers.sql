@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS couriers (
+    id VARCHAR(255) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    phone VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Orders accepted before couriers were recorded keep a NULL courier
+ALTER TABLE orders ADD COLUMN courier_id VARCHAR(255) REFERENCES couriers (id);
+
+CREATE INDEX courier_id_storage_asc ON orders (courier_id, storage_until ASC);
+
+-- The orders are a copy, they are deleted from orders by the same transaction
+CREATE TABLE IF NOT EXISTS courier_manifests (
+    id BIGSERIAL PRIMARY KEY,
+    courier_id VARCHAR(255) NOT NULL REFERENCES couriers (id),
+    operator VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    orders JSONB NOT NULL
+);
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS courier_manifests;
+DROP INDEX courier_id_storage_asc;
+ALTER TABLE orders DROP COLUMN courier_id;
+DROP TABLE IF EXISTS couriers;
+-- +goose StatementEnd
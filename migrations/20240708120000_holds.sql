@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- A hold goes with its order when the order is returned to the courier
+CREATE TABLE IF NOT EXISTS holds (
+    order_id VARCHAR(255) PRIMARY KEY REFERENCES orders (id) ON DELETE CASCADE,
+    operator VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX holds_expires_at_asc ON holds (expires_at ASC);
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX holds_expires_at_asc;
+DROP TABLE IF EXISTS holds;
+-- +goose StatementEnd
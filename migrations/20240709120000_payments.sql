@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The lines are the receipt as the customer got it, prices of the orders may not be there later
+CREATE TABLE IF NOT EXISTS payments (
+    id BIGSERIAL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL REFERENCES users (id),
+    operator VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    method VARCHAR(16) NOT NULL,
+    total FLOAT NOT NULL,
+    prepaid FLOAT NOT NULL,
+    due FLOAT NOT NULL,
+    paid FLOAT NOT NULL,
+    change_given FLOAT NOT NULL,
+    lines JSONB NOT NULL
+);
+
+-- No reference to orders, a returned order is deleted when the courier takes it and its payment stays
+CREATE TABLE IF NOT EXISTS payment_orders (
+    payment_id BIGINT NOT NULL REFERENCES payments (id),
+    order_id VARCHAR(255) NOT NULL,
+    PRIMARY KEY (payment_id, order_id)
+);
+
+CREATE INDEX payment_orders_order_id ON payment_orders (order_id);
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX payment_orders_order_id;
+DROP TABLE IF EXISTS payment_orders;
+DROP TABLE IF EXISTS payments;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Orders issued before payments were recorded are refunded without one
+CREATE TABLE IF NOT EXISTS refunds (
+    id BIGSERIAL PRIMARY KEY,
+    payment_id BIGINT REFERENCES payments (id),
+    order_id VARCHAR(255) NOT NULL,
+    user_id VARCHAR(255) NOT NULL REFERENCES users (id),
+    operator VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    method VARCHAR(16) NOT NULL,
+    goods FLOAT NOT NULL,
+    package_price FLOAT NOT NULL,
+    withheld FLOAT NOT NULL,
+    amount FLOAT NOT NULL
+);
+
+CREATE INDEX refunds_created_at_desc ON refunds (created_at DESC);
+CREATE INDEX refunds_user_id_created_at_desc ON refunds (user_id, created_at DESC);
+-- The return updates the order conditionally, this keeps a paid order from being refunded twice anyway
+CREATE UNIQUE INDEX refunds_payment_id_order_id ON refunds (payment_id, order_id);
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX refunds_payment_id_order_id;
+DROP INDEX refunds_user_id_created_at_desc;
+DROP INDEX refunds_created_at_desc;
+DROP TABLE IF EXISTS refunds;
+-- +goose StatementEnd
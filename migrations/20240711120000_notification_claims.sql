@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+-- claimed_at is when the last send started, a notification left pending long after it lost the process sending it
+ALTER TABLE notifications ADD COLUMN claimed_at TIMESTAMPTZ NOT NULL DEFAULT now();
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE notifications DROP COLUMN claimed_at;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Film has no weight limit, a NULL max_weight leaves the cell bounded by its order count only
+ALTER TABLE cells ALTER COLUMN max_weight DROP NOT NULL;
+UPDATE cells SET max_weight = NULL WHERE package_type = 'film';
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+UPDATE cells SET max_weight = 50 WHERE max_weight IS NULL;
+ALTER TABLE cells ALTER COLUMN max_weight SET NOT NULL;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- An order is paid for once, a second payment of it fails even if the issue itself raced past its checks
+DROP INDEX payment_orders_order_id;
+CREATE UNIQUE INDEX payment_orders_order_id ON payment_orders (order_id);
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX payment_orders_order_id;
+CREATE INDEX payment_orders_order_id ON payment_orders (order_id);
+-- +goose StatementEnd
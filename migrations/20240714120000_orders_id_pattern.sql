@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The search matches id prefixes with LIKE, id_asc only serves it under the C collation, a pattern_ops index does under any
+CREATE INDEX orders_id_pattern ON orders (id text_pattern_ops);
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX orders_id_pattern;
+-- +goose StatementEnd
@@ -0,0 +1,62 @@
+// Package clock lets the code that makes time based decisions be driven by something other than the wall clock
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+type Clock interface {
+	Now() time.Time
+}
+
+// Real is the wall clock
+type Real struct{}
+
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+// Offset runs at the speed of the wall clock but starts at another instant, it's what the -now override uses
+// so a session can be replayed as it was on the day a customer complained
+type Offset struct {
+	offset time.Duration
+}
+
+func NewOffset(now time.Time) *Offset {
+	return &Offset{offset: time.Until(now)}
+}
+
+func (o *Offset) Now() time.Time {
+	return time.Now().Add(o.offset)
+}
+
+// Fake only moves when it's told to, it's safe for concurrent use
+type Fake struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) Set(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = now
+}
+
+// Advance moves the clock forward by d and returns the new time
+func (f *Fake) Advance(d time.Duration) time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	return f.now
+}
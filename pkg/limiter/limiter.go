@@ -0,0 +1,106 @@
+package limiter
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// Limiter bounds the number of goroutines doing work at once, unlike a buffered channel
+// its limit can be changed while slots are held: growing admits waiters right away,
+// shrinking lets the holders finish and admits nobody until the count falls below the new limit
+type Limiter struct {
+	mu      sync.Mutex
+	limit   int
+	active  int
+	waiters list.List
+}
+
+func New(limit int) *Limiter {
+	return &Limiter{limit: limit}
+}
+
+// Acquire blocks until a slot is free or ctx is done, waiters are admitted in FIFO order
+func (l *Limiter) Acquire(ctx context.Context) error {
+	l.mu.Lock()
+	if l.active < l.limit && l.waiters.Len() == 0 {
+		l.active++
+		l.mu.Unlock()
+		return nil
+	}
+
+	ready := make(chan struct{})
+	elem := l.waiters.PushBack(ready)
+	l.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		select {
+		case <-ready:
+			// Admitted at the same moment ctx was canceled, hand the slot back
+			l.active--
+			l.notify()
+		default:
+			l.waiters.Remove(elem)
+			// Removing the head may let the next waiter in
+			l.notify()
+		}
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (l *Limiter) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.active == 0 {
+		panic("limiter: release without acquire")
+	}
+	l.active--
+	l.notify()
+}
+
+// SetLimit changes the limit, it's safe to call while slots are held
+func (l *Limiter) SetLimit(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.limit = n
+	l.notify()
+}
+
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// Active is the number of slots held right now, it may exceed Limit for a while after shrinking
+func (l *Limiter) Active() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.active
+}
+
+func (l *Limiter) Waiting() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.waiters.Len()
+}
+
+// notify admits waiters while there is room, l.mu must be held
+func (l *Limiter) notify() {
+	for l.active < l.limit {
+		front := l.waiters.Front()
+		if front == nil {
+			return
+		}
+		l.waiters.Remove(front)
+		l.active++
+		close(front.Value.(chan struct{}))
+	}
+}
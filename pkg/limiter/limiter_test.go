@@ -0,0 +1,157 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// acquire starts an Acquire in its own goroutine, the channel gets its result
+func acquire(ctx context.Context, l *Limiter) <-chan error {
+	done := make(chan error, 1)
+	go func() { done <- l.Acquire(ctx) }()
+	return done
+}
+
+// eventually fails the test when cond doesn't hold within a second
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func blocked(t *testing.T, done <-chan error) {
+	t.Helper()
+	select {
+	case err := <-done:
+		t.Fatalf("Acquire returned %v, want it blocked", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func admitted(t *testing.T, done <-chan error) {
+	t.Helper()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Acquire = %v, want a slot", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Acquire still blocked, want a slot")
+	}
+}
+
+func TestShrinkWhileHeld(t *testing.T) {
+	ctx := context.Background()
+	l := New(3)
+	for i := 0; i < 3; i++ {
+		if err := l.Acquire(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l.SetLimit(1)
+	if l.Active() != 3 {
+		t.Fatalf("active = %d, want the 3 holders to keep their slots", l.Active())
+	}
+	waiter := acquire(ctx, l)
+	eventually(t, "the waiter to queue", func() bool { return l.Waiting() == 1 })
+
+	// The holders finish one by one, nobody is admitted until the count falls below the new limit
+	l.Release()
+	blocked(t, waiter)
+	l.Release()
+	blocked(t, waiter)
+	l.Release()
+	admitted(t, waiter)
+	if l.Active() != 1 || l.Waiting() != 0 {
+		t.Errorf("active %d, waiting %d, want the waiter alone in its slot", l.Active(), l.Waiting())
+	}
+}
+
+func TestGrowWhileBlocked(t *testing.T) {
+	ctx := context.Background()
+	l := New(1)
+	if err := l.Acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Queued one after the other so the FIFO order is known
+	waiters := make([]<-chan error, 3)
+	for i := range waiters {
+		waiters[i] = acquire(ctx, l)
+		eventually(t, "the waiter to queue", func() bool { return l.Waiting() == i+1 })
+	}
+
+	l.SetLimit(3)
+	admitted(t, waiters[0])
+	admitted(t, waiters[1])
+	blocked(t, waiters[2])
+	if l.Active() != 3 || l.Waiting() != 1 {
+		t.Fatalf("active %d, waiting %d, want 3 and the last waiter", l.Active(), l.Waiting())
+	}
+
+	l.SetLimit(4)
+	admitted(t, waiters[2])
+	if l.Active() != 4 || l.Waiting() != 0 {
+		t.Errorf("active %d, waiting %d, want every waiter admitted", l.Active(), l.Waiting())
+	}
+}
+
+func TestAcquireCanceled(t *testing.T) {
+	l := New(1)
+	if err := l.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	waiter := acquire(ctx, l)
+	eventually(t, "the waiter to queue", func() bool { return l.Waiting() == 1 })
+	cancel()
+	if err := <-waiter; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Acquire = %v, want %v", err, context.Canceled)
+	}
+	if l.Active() != 1 || l.Waiting() != 0 {
+		t.Fatalf("active %d, waiting %d, want the canceled waiter gone without a slot", l.Active(), l.Waiting())
+	}
+
+	l.Release()
+	if err := l.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if l.Active() != 1 {
+		t.Errorf("active = %d, want the freed slot taken once", l.Active())
+	}
+}
+
+// TestCancelRacesRelease cancels a waiter while the slot it waits for is released,
+// whichever wins the slot must not leak
+func TestCancelRacesRelease(t *testing.T) {
+	for i := 0; i < 500; i++ {
+		l := New(1)
+		if err := l.Acquire(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		waiter := acquire(ctx, l)
+		eventually(t, "the waiter to queue", func() bool { return l.Waiting() == 1 })
+
+		go cancel()
+		l.Release()
+		if err := <-waiter; err == nil {
+			l.Release()
+		}
+		cancel()
+
+		if l.Active() != 0 || l.Waiting() != 0 {
+			t.Fatalf("run %d: active %d, waiting %d, want the limiter empty", i, l.Active(), l.Waiting())
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errFailed = errors.New("failed")
+
+func TestDo(t *testing.T) {
+	permanent := errors.New("permanent")
+
+	tests := []struct {
+		name      string
+		attempts  int
+		failures  int
+		err       error
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "first try", attempts: 3, failures: 0, wantCalls: 1},
+		{name: "succeeds on a retry", attempts: 3, failures: 2, err: errFailed, wantCalls: 3},
+		{name: "attempts spent", attempts: 3, failures: 10, err: errFailed, wantCalls: 3, wantErr: errFailed},
+		{name: "no attempts still tries once", attempts: 0, failures: 10, err: errFailed, wantCalls: 1, wantErr: errFailed},
+		{name: "permanent error", attempts: 3, failures: 10, err: permanent, wantCalls: 1, wantErr: permanent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls, retries int
+			policy := Policy{
+				Attempts:     tt.attempts,
+				InitialDelay: time.Millisecond,
+				Multiplier:   2,
+				Retryable:    func(err error) bool { return !errors.Is(err, permanent) },
+				OnRetry:      func(attempt int, delay time.Duration, err error) { retries++ },
+			}
+
+			err := Do(context.Background(), policy, func(ctx context.Context) error {
+				calls++
+				if calls <= tt.failures {
+					return tt.err
+				}
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls || retries != tt.wantCalls-1 {
+				t.Errorf("%d calls and %d retries, want %d calls", calls, retries, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestDoCanceledWhileWaiting(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := Policy{Attempts: 5, InitialDelay: time.Hour, OnRetry: func(int, time.Duration, error) { cancel() }}
+
+	var calls int
+	err := Do(ctx, policy, func(ctx context.Context) error {
+		calls++
+		return errFailed
+	})
+	if !errors.Is(err, context.Canceled) || !errors.Is(err, errFailed) {
+		t.Errorf("error = %v, want the last error and the cancellation", err)
+	}
+	if calls != 1 {
+		t.Errorf("%d calls, want no retry after the cancellation", calls)
+	}
+}
+
+func TestDelay(t *testing.T) {
+	policy := Policy{InitialDelay: 100 * time.Millisecond, MaxDelay: time.Second, Multiplier: 2}
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{100, time.Second},
+	}
+	for _, tt := range tests {
+		if got := policy.Delay(tt.attempt); got != tt.want {
+			t.Errorf("Delay(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+
+	// Jitter spreads the delay around the backoff, never past the cap
+	policy.Jitter = 0.2
+	for i := 0; i < 1000; i++ {
+		if got := policy.Delay(2); got < 160*time.Millisecond || got > 240*time.Millisecond {
+			t.Fatalf("Delay(2) with jitter = %s, want 200ms ± 20%%", got)
+		}
+		if got := policy.Delay(10); got > time.Second || got < 800*time.Millisecond {
+			t.Fatalf("Delay(10) with jitter = %s, want at most the 1s cap", got)
+		}
+	}
+}